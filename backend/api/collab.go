@@ -0,0 +1,69 @@
+package api
+
+import (
+    "encoding/json"
+    "io"
+    "log"
+    "net/http"
+    "golang.org/x/net/websocket"
+    "breadboard-simulator/collab"
+)
+
+// maxOpSize bounds one operation sent over the live editing socket.
+const maxOpSize = 1 << 20
+
+// LiveEditHandler serves the WebSocket at /api/projects/{id}/live for
+// editing a project together. The server first sends a snapshot message,
+// then every operation applied by any client; clients send operations as
+// JSON text frames. See package collab for the protocol.
+func LiveEditHandler(hub *collab.Hub) http.HandlerFunc {
+    server := websocket.Server{
+        // the API is open to any origin, as with withCORS
+        Handshake: func(*websocket.Config, *http.Request) error { return nil },
+        Handler: func(ws *websocket.Conn) {
+            defer ws.Close()
+            ws.MaxPayloadBytes = maxOpSize
+
+            id := ws.Request().PathValue("id")
+            room, client, err := hub.Join(id)
+            if err != nil {
+                websocket.JSON.Send(ws, collab.Message{Type: collab.ErrorMessage, Error: err.Error()})
+                return
+            }
+
+            done := make(chan struct{})
+            go func() {
+                defer close(done)
+                for data := range client.Out {
+                    if err := websocket.Message.Send(ws, string(data)); err != nil {
+                        ws.Close()
+                        for range client.Out {
+                        }
+                        return
+                    }
+                }
+                // the room dropped this client for falling behind
+                ws.Close()
+            }()
+
+            for {
+                var data string
+                if err := websocket.Message.Receive(ws, &data); err != nil {
+                    if err != io.EOF {
+                        log.Printf("Live editing of project %s: %v\n", id, err)
+                    }
+                    break
+                }
+                var op collab.Op
+                if err := json.Unmarshal([]byte(data), &op); err != nil {
+                    room.Reject(client, err)
+                    continue
+                }
+                room.Submit(client, op)
+            }
+            hub.Leave(room, client)
+            <-done
+        },
+    }
+    return server.ServeHTTP
+}
@@ -1,15 +1,308 @@
 package api
 
 import (
+    "bytes"
     "encoding/json"
+    "errors"
+    "io"
     "net/http"
+    "strings"
+    "breadboard-simulator/breadboard"
     "breadboard-simulator/circuit"
+    "breadboard-simulator/fritzing"
+    "breadboard-simulator/kicad"
+    "breadboard-simulator/parts"
+    "breadboard-simulator/schematic"
+    "breadboard-simulator/spice"
+    "breadboard-simulator/waveform"
 )
 
+// circuitInput is the circuit a request works on: components wired by
+// connections or by net name, or a breadboard layout, which is run
+// through the netlister instead.
+type circuitInput struct {
+    Components  []circuit.Component    `json:"components"`
+    Connections []circuit.Connection   `json:"connections"`
+    Subcircuits []circuit.Subcircuit   `json:"subcircuits"`
+    Params      map[string]interface{} `json:"params"`
+    Breadboard  *breadboard.State      `json:"breadboard"`
+}
+
+func (in circuitInput) circuit() (*circuit.Circuit, error) {
+    c := &circuit.Circuit{Components: in.Components, Connections: in.Connections, Subcircuits: in.Subcircuits, Params: in.Params}
+    if in.Breadboard != nil {
+        var err error
+        if c, _, err = in.Breadboard.Netlist(); err != nil {
+            return nil, err
+        }
+    }
+    return prepare(c)
+}
+
+// prepare readies a circuit for analysis: its subcircuit instances are
+// flattened, the components naming a part are filled in from the parts
+// library and the values written as expressions are evaluated.
+func prepare(c *circuit.Circuit) (*circuit.Circuit, error) {
+    c, err := circuit.Flatten(c)
+    if err != nil {
+        return nil, err
+    }
+    if c, err = parts.Default.Resolve(c); err != nil {
+        return nil, err
+    }
+    return circuit.Evaluate(c)
+}
+
+// SimulateHandler solves a circuit's operating point and returns the node
+// voltages and branch currents, flattened in results and formatted with
+// units in formatted, along with the stress findings and power budget. A
+// shorted source is answered with 422 and the shorts found, as are parts
+// the solver does not model.
 func SimulateHandler(w http.ResponseWriter, r *http.Request) {
+    var input circuitInput
+    if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    c, err := input.circuit()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+
+    sol, err := circuit.Solve(c)
+    if err != nil {
+        analysisError(w, err, http.StatusInternalServerError)
+        return
+    }
+
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "results":   sol.Results(),
+        "formatted": sol.FormattedResults(),
+        "solution":  sol,
+        "stress":    circuit.AnalyzeStress(c, sol),
+        "power":     circuit.BuildPowerBudget(c, sol),
+    })
+}
+
+// analysisError reports an error from analysing a circuit with status. A
+// shorted source is answered with 422 and the shorts found, and parts the
+// solver does not model with 422, whichever analysis ran into them.
+func analysisError(w http.ResponseWriter, err error, status int) {
+    var short *circuit.ShortCircuitError
+    if errors.As(err, &short) {
+        writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+            "error":  err.Error(),
+            "shorts": short.Shorts,
+        })
+        return
+    }
+    var unsupported *circuit.UnsupportedError
+    if errors.As(err, &unsupported) {
+        status = http.StatusUnprocessableEntity
+    }
+    http.Error(w, err.Error(), status)
+}
+
+// PowerBudgetHandler returns the power each source delivers and each part
+// dissipates.
+func PowerBudgetHandler(w http.ResponseWriter, r *http.Request) {
+    var input circuitInput
+    if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    c, err := input.circuit()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+
+    sol, err := circuit.Solve(c)
+    if err != nil {
+        analysisError(w, err, http.StatusUnprocessableEntity)
+        return
+    }
+    writeJSON(w, http.StatusOK, circuit.BuildPowerBudget(c, sol))
+}
+
+// DrainHandler simulates the circuit's batteries discharging.
+func DrainHandler(w http.ResponseWriter, r *http.Request) {
+    var input struct {
+        circuitInput
+        Options circuit.DrainOptions `json:"options"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    c, err := input.circuit()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+
+    result, err := circuit.SimulateDrainContext(r.Context(), c, input.Options)
+    if err != nil {
+        analysisError(w, err, http.StatusUnprocessableEntity)
+        return
+    }
+    writeJSON(w, http.StatusOK, result)
+}
+
+// MonteCarloHandler runs a Monte Carlo tolerance analysis of a circuit
+// with the given options and returns the statistics of each net and the
+// yield against the limits.
+func MonteCarloHandler(w http.ResponseWriter, r *http.Request) {
+    var input struct {
+        circuitInput
+        Options circuit.MonteCarloOptions `json:"options"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    c, err := input.circuit()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+
+    result, err := circuit.MonteCarloContext(r.Context(), c, input.Options)
+    if err != nil {
+        analysisError(w, err, http.StatusUnprocessableEntity)
+        return
+    }
+    writeJSON(w, http.StatusOK, result)
+}
+
+// ImportSpiceHandler parses the SPICE deck in the request body and returns
+// its circuit, models, parameters and directives. Uploaded decks may not
+// use .include.
+func ImportSpiceHandler(w http.ResponseWriter, r *http.Request) {
+    r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+    // never let an uploaded deck pull in files from the server
+    p := &spice.Parser{Open: func(name string) (io.ReadCloser, error) {
+        return nil, errors.New(".include is not allowed in uploaded decks")
+    }}
+    deck, err := p.Parse(r.Body, "")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    writeJSON(w, http.StatusOK, deck)
+}
+
+// maxUpload bounds uploaded project files.
+const maxUpload = 32 << 20
+
+// ImportFritzingHandler imports a .fzz archive, sent either as the "file"
+// field of a multipart form or as the raw request body.
+func ImportFritzingHandler(w http.ResponseWriter, r *http.Request) {
+    r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+    var body io.Reader = r.Body
+    // any other body would be consumed by parsing it as a form
+    if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+        file, _, err := r.FormFile("file")
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        defer file.Close()
+        body = file
+    }
+    data, err := io.ReadAll(body)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    project, err := fritzing.Read(bytes.NewReader(data), int64(len(data)))
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(project)
+}
+
+// ImportKicadHandler imports a KiCad netlist. A multipart form carries it
+// in "file" and may add a JSON symbol mapping in "mapping"; otherwise the
+// request body is the netlist.
+func ImportKicadHandler(w http.ResponseWriter, r *http.Request) {
+    r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+    var body io.Reader = r.Body
+    mapping := kicad.DefaultMapping
+    // any other body would be consumed by parsing it as a form
+    if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+        file, _, err := r.FormFile("file")
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        defer file.Close()
+        body = file
+        if extra := r.FormValue("mapping"); extra != "" {
+            mapping, err = kicad.LoadMapping(strings.NewReader(extra))
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+        }
+    }
+
+    netlist, err := mapping.Parse(body)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(netlist)
+}
+
+// ExportSpiceHandler returns a circuit, or a breadboard layout run through
+// the netlister, as a SPICE deck for cross-checking in ngspice.
+func ExportSpiceHandler(w http.ResponseWriter, r *http.Request) {
+    var input struct {
+        circuitInput
+        Title    string         `json:"title"`
+        Analysis spice.Analysis `json:"analysis"`
+    }
+
+    if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    c, err := input.circuit()
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+
+    var deck bytes.Buffer
+    if err := spice.Export(&deck, input.Title, c, input.Analysis); err != nil {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+
+    w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+    w.Header().Set("Content-Disposition", "attachment; filename=circuit.cir")
+    w.Write(deck.Bytes())
+}
+
+// SchematicHandler draws a circuit, or a breadboard layout, as an SVG
+// schematic. With annotate set the circuit is solved first and the
+// drawing labelled with node voltages and branch currents; if it cannot
+// be solved the plain schematic is returned with the reason in the
+// X-Simulation-Error header.
+func SchematicHandler(w http.ResponseWriter, r *http.Request) {
     var input struct {
-        Components  []circuit.Component
-        Connections []circuit.Connection
+        circuitInput
+        Title    string `json:"title"`
+        Annotate bool   `json:"annotate"`
     }
 
     if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -17,11 +310,87 @@ func SimulateHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    results, err := circuit.SimulateCircuit(input.Components, input.Connections)
+    c, err := input.circuit()
     if err != nil {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
+
+    opts := schematic.Options{Title: input.Title}
+    if input.Annotate {
+        sol, err := circuit.Solve(c)
+        if err != nil {
+            w.Header().Set("X-Simulation-Error", err.Error())
+        }
+        opts.Solution = sol
+    }
+
+    var svg bytes.Buffer
+    if err := schematic.Render(&svg, c, opts); err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
     }
+    w.Header().Set("Content-Type", "image/svg+xml")
+    w.Write(svg.Bytes())
+}
+
+// waveformTypes gives the Content-Type for each waveform format.
+var waveformTypes = map[string]string{
+    "csv":       "text/csv; charset=utf-8",
+    "raw":       "application/octet-stream",
+    "raw-ascii": "text/plain; charset=utf-8",
+    "vcd":       "text/plain; charset=utf-8",
+}
+
+// ExportWaveformHandler returns simulation results as a waveform file
+// attachment in "csv" (the default), "raw", "raw-ascii" or "vcd" format.
+// The request gives the results as a dataset, or else a circuit or
+// breadboard layout with an analysis ("dc", "tran" or "drain") to run;
+// "vcd" takes its logic thresholds and timescale from vcd.
+func ExportWaveformHandler(w http.ResponseWriter, r *http.Request) {
+    var input struct {
+        circuitInput
+        Title    string              `json:"title"`
+        Format   string              `json:"format"`
+        Analysis waveform.Analysis   `json:"analysis"`
+        Dataset  *waveform.Dataset   `json:"dataset"`
+        VCD      waveform.VCDOptions `json:"vcd"`
+    }
+
+    if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if input.Format == "" {
+        input.Format = "csv"
+    }
+    contentType, ok := waveformTypes[input.Format]
+    if !ok {
+        http.Error(w, "unknown waveform format "+input.Format, http.StatusBadRequest)
+        return
+    }
+
+    d := input.Dataset
+    if d == nil {
+        c, err := input.circuit()
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+            return
+        }
+        d, err = waveform.RunContext(r.Context(), input.Title, c, input.Analysis)
+        if err != nil {
+            analysisError(w, err, http.StatusUnprocessableEntity)
+            return
+        }
+    }
+
+    var out bytes.Buffer
+    if err := waveform.Write(&out, d, input.Format, input.VCD); err != nil {
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+        return
+    }
 
-    json.NewEncoder(w).Encode(results)
-}
\ No newline at end of file
+    w.Header().Set("Content-Type", contentType)
+    w.Header().Set("Content-Disposition", "attachment; filename="+waveform.FileName(input.Format))
+    w.Write(out.Bytes())
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+    "archive/zip"
+    "bytes"
+    "encoding/json"
+    "math"
+    "mime/multipart"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "breadboard-simulator/circuit"
+    "breadboard-simulator/parts"
+)
+
+// shorted is a circuit request for a battery shorted by a wire.
+const shorted = `{"components": [
+    {"id": "B1", "type": "battery", "value": 9, "nodes": ["vcc", "0"]},
+    {"id": "W1", "type": "wire", "nodes": ["vcc", "0"]}
+]}`
+
+func TestSimulate(t *testing.T) {
+    h := newTestRouter(t)
+    var res struct {
+        Results  map[string]float64 `json:"results"`
+        Solution circuit.Solution   `json:"solution"`
+    }
+    decode(t, do(h, "POST", "/api/simulate", divider), http.StatusOK, &res)
+    if v := res.Solution.NodeVoltages["out"]; math.Abs(v-5) > 1e-6 || math.Abs(res.Results["out"]-5) > 1e-6 {
+        t.Errorf("out = %v, results %v", v, res.Results)
+    }
+
+    var short struct {
+        Error  string                `json:"error"`
+        Shorts []circuit.ShortCircuit `json:"shorts"`
+    }
+    decode(t, do(h, "POST", "/api/simulate", shorted), http.StatusUnprocessableEntity, &short)
+    if len(short.Shorts) == 0 || short.Shorts[0].SourceID != "B1" {
+        t.Errorf("short = %+v", short)
+    }
+
+    vcvs := `{"components": [
+        {"id": "B1", "type": "battery", "value": 1, "nodes": ["in", "0"]},
+        {"id": "E1", "type": "vcvs", "value": 2, "nodes": ["out", "0", "in", "0"]},
+        {"id": "R1", "type": "resistor", "value": 1000, "nodes": ["out", "0"]}
+    ]}`
+    if w := do(h, "POST", "/api/simulate", vcvs); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "does not model E1") {
+        t.Errorf("unsupported part: %d %s", w.Code, w.Body)
+    }
+
+    if w := do(h, "POST", "/api/simulate", "{"); w.Code != http.StatusBadRequest {
+        t.Errorf("bad JSON: %d", w.Code)
+    }
+    unknown := `{"components": [{"id": "R1", "type": "resistor", "expression": "RB", "nodes": ["a", "0"]}]}`
+    if w := do(h, "POST", "/api/simulate", unknown); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "unknown name RB") {
+        t.Errorf("unknown parameter: %d %s", w.Code, w.Body)
+    }
+}
+
+func TestShortsReportedAlike(t *testing.T) {
+    h := newTestRouter(t)
+    tran := strings.Replace(shorted, `"components"`, `"analysis": {"type": "tran", "step": 1e-3, "stop": 1e-2}, "components"`, 1)
+    for path, body := range map[string]string{
+        "/api/simulate":        shorted,
+        "/api/power":           shorted,
+        "/api/drain":           shorted,
+        "/api/montecarlo":      shorted,
+        "/api/export/waveform": tran,
+    } {
+        w := do(h, "POST", path, body)
+        var short struct {
+            Error  string                 `json:"error"`
+            Shorts []circuit.ShortCircuit `json:"shorts"`
+        }
+        if w.Code != http.StatusUnprocessableEntity || json.Unmarshal(w.Body.Bytes(), &short) != nil || len(short.Shorts) != 1 {
+            t.Errorf("%s: %d %s", path, w.Code, w.Body)
+        }
+    }
+}
+
+func TestImportSpice(t *testing.T) {
+    h := newTestRouter(t)
+    w := do(h, "POST", "/api/import/spice", "divider\nV1 in 0 10\nR1 in out 1k\nR2 out 0 1k\n.end\n")
+    if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"R2"`) {
+        t.Errorf("import: %d %s", w.Code, w.Body)
+    }
+    for deck, want := range map[string]string{
+        "t\nx=5\n":               "expected an element name",
+        "t\n.include /etc/passwd\n": ".include is not allowed",
+    } {
+        if w := do(h, "POST", "/api/import/spice", deck); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), want) {
+            t.Errorf("%q: %d %s", deck, w.Code, w.Body)
+        }
+    }
+}
+
+func TestImportKicad(t *testing.T) {
+    h := newTestRouter(t)
+    netlist := `(export (version "E")
+  (components
+    (comp (ref "BT1") (value "Battery_Cell") (libsource (lib "Device") (part "Battery_Cell")))
+    (comp (ref "R1") (value "4k7") (libsource (lib "Device") (part "R"))))
+  (nets
+    (net (code "1") (name "VCC") (node (ref "BT1") (pin "1")) (node (ref "R1") (pin "1")))
+    (net (code "2") (name "GND") (node (ref "BT1") (pin "2")) (node (ref "R1") (pin "2")))))`
+    var res struct {
+        Circuit  circuit.Circuit `json:"circuit"`
+        Warnings []string        `json:"warnings"`
+    }
+    decode(t, do(h, "POST", "/api/import/kicad", netlist), http.StatusOK, &res)
+    // the cell's value is not a number, so it takes the default with a warning
+    if len(res.Circuit.Components) != 2 || len(res.Warnings) != 1 {
+        t.Errorf("imported %+v", res)
+    }
+    // as sent by curl's --data-binary
+    r := httptest.NewRequest("POST", "/api/import/kicad", strings.NewReader(netlist))
+    r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    w := httptest.NewRecorder()
+    h.ServeHTTP(w, r)
+    if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"R1"`) {
+        t.Errorf("raw body sent as a form: %d %s", w.Code, w.Body)
+    }
+
+    if w := do(h, "POST", "/api/import/kicad", "(export"); w.Code != http.StatusBadRequest {
+        t.Errorf("truncated netlist: %d", w.Code)
+    }
+}
+
+func TestImportParts(t *testing.T) {
+    // a library of its own, so the shared one is left alone
+    h := ImportPartsHandler(parts.New())
+
+    var body bytes.Buffer
+    form := multipart.NewWriter(&body)
+    file, _ := form.CreateFormFile("file", "diodes.lib")
+    file.Write([]byte(".model D1N4148 D(Is=2.52n N=1.752)\n"))
+    form.Close()
+    r := httptest.NewRequest("POST", "/api/parts/import", &body)
+    r.Header.Set("Content-Type", form.FormDataContentType())
+    w := httptest.NewRecorder()
+    h(w, r)
+    if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), "D1N4148") {
+        t.Errorf("import: %d %s", w.Code, w.Body)
+    }
+
+    for body, want := range map[string]string{
+        "* nothing but a comment\n": "no diode, transistor or MOSFET models",
+        "x=5\n":                     "expected an element name",
+    } {
+        if w := do(h, "POST", "/api/parts/import", body); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), want) {
+            t.Errorf("%q: %d %s", body, w.Code, w.Body)
+        }
+    }
+    if w := do(h, "GET", "/api/parts/import", ""); w.Code != http.StatusMethodNotAllowed {
+        t.Errorf("GET: %d", w.Code)
+    }
+}
+
+func TestImportFritzingRawBody(t *testing.T) {
+    var fzz bytes.Buffer
+    zw := zip.NewWriter(&fzz)
+    f, _ := zw.Create("divider.fz")
+    f.Write([]byte(`<module fritzingVersion="0.9.3b"><instances>
+  <instance moduleIdRef="ResistorModuleID" modelIndex="2">
+    <property name="resistance" value="220Ω"/>
+    <title>R1</title>
+    <views><breadboardView><geometry x="120" y="40"/></breadboardView></views>
+  </instance>
+</instances></module>`))
+    zw.Close()
+
+    // curl's --data-binary sends the archive as a form unless told otherwise
+    r := httptest.NewRequest("POST", "/api/import/fritzing", bytes.NewReader(fzz.Bytes()))
+    r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    w := httptest.NewRecorder()
+    newTestRouter(t).ServeHTTP(w, r)
+    if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"R1"`) {
+        t.Errorf("import: %d %s", w.Code, w.Body)
+    }
+}
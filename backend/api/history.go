@@ -0,0 +1,246 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "strconv"
+    "time"
+    "breadboard-simulator/breadboard"
+    "breadboard-simulator/history"
+    "breadboard-simulator/session"
+    "breadboard-simulator/storage"
+)
+
+// historyError reports a history error with a matching status.
+func historyError(w http.ResponseWriter, err error) {
+    switch {
+    case errors.Is(err, history.ErrNoRevision):
+        http.Error(w, err.Error(), http.StatusNotFound)
+    case errors.Is(err, history.ErrNothingToUndo), errors.Is(err, history.ErrNothingToRedo):
+        http.Error(w, err.Error(), http.StatusConflict)
+    default:
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+    }
+}
+
+// revisionNumber parses a revision number from the path or query.
+func revisionNumber(s string) (int, error) {
+    n, err := strconv.Atoi(s)
+    if err != nil || n < 1 {
+        return 0, errors.New("revision numbers are positive integers")
+    }
+    return n, nil
+}
+
+// HistoryHandler serves the session's history: GET /api/history lists
+// its revisions, GET /api/history/{n} returns one, and POST
+// /api/history/{n}/checkout or /api/history/{n}/revert moves the session
+// to it, returning the revision that is then the head.
+func HistoryHandler(sessions *session.Manager) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        action := r.PathValue("action")
+        if r.PathValue("n") == "" {
+            if r.Method != http.MethodGet {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+            }
+            h, err := sessions.History(r)
+            if err != nil {
+                historyError(w, err)
+                return
+            }
+            writeJSON(w, http.StatusOK, h.List())
+            return
+        }
+
+        n, err := revisionNumber(r.PathValue("n"))
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        var rev *history.Revision
+        switch {
+        case action == "" && r.Method == http.MethodGet:
+            var h *history.History
+            if h, err = sessions.History(r); err == nil {
+                rev, err = h.Get(n)
+            }
+        case action == "checkout" && r.Method == http.MethodPost:
+            rev, err = sessions.Move(r, func(h *history.History) (*history.Revision, error) {
+                return h.Checkout(n)
+            })
+        case action == "revert" && r.Method == http.MethodPost:
+            rev, err = sessions.Move(r, func(h *history.History) (*history.Revision, error) {
+                return h.Revert(n, time.Now().UTC())
+            })
+        case action != "" && action != "checkout" && action != "revert":
+            http.NotFound(w, r)
+            return
+        default:
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        if err != nil {
+            historyError(w, err)
+            return
+        }
+        writeJSON(w, http.StatusOK, rev)
+    }
+}
+
+// UndoHandler serves POST /api/undo, moving the session back to the
+// revision its breadboard was made from.
+func UndoHandler(sessions *session.Manager) http.HandlerFunc {
+    return moveHandler(sessions, (*history.History).Undo)
+}
+
+// RedoHandler serves POST /api/redo, reapplying the last undone revision.
+func RedoHandler(sessions *session.Manager) http.HandlerFunc {
+    return moveHandler(sessions, (*history.History).Redo)
+}
+
+func moveHandler(sessions *session.Manager, move func(*history.History) (*history.Revision, error)) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        rev, err := sessions.Move(r, move)
+        if err != nil {
+            historyError(w, err)
+            return
+        }
+        writeJSON(w, http.StatusOK, rev)
+    }
+}
+
+// DiffHandler serves /api/diff. POST compares the two states in
+// {from, to}; GET compares two revisions of the session's history given
+// as ?from=&to=, by default the head and its parent.
+func DiffHandler(sessions *session.Manager) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        switch r.Method {
+        case http.MethodPost:
+            var input struct {
+                From breadboard.State `json:"from"`
+                To   breadboard.State `json:"to"`
+            }
+            if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            writeJSON(w, http.StatusOK, breadboard.Compare(input.From, input.To))
+
+        case http.MethodGet:
+            h, err := sessions.History(r)
+            if err != nil {
+                historyError(w, err)
+                return
+            }
+            from, to, err := diffRange(r, h.Head, func(n int) (int, error) {
+                rev, err := h.Get(n)
+                if err != nil {
+                    return 0, err
+                }
+                return rev.Parent, nil
+            })
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            var a, b breadboard.State
+            if from > 0 {
+                rev, err := h.Get(from)
+                if err != nil {
+                    historyError(w, err)
+                    return
+                }
+                a = rev.State
+            }
+            rev, err := h.Get(to)
+            if err != nil {
+                historyError(w, err)
+                return
+            }
+            b = rev.State
+            writeJSON(w, http.StatusOK, breadboard.Compare(a, b))
+
+        default:
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+    }
+}
+
+// diffRange reads the ?from= and ?to= revisions of a diff. To defaults to
+// head and from to to's parent; a from of 0 means the empty breadboard.
+func diffRange(r *http.Request, head int, parent func(int) (int, error)) (from, to int, err error) {
+    to = head
+    if s := r.URL.Query().Get("to"); s != "" {
+        if to, err = revisionNumber(s); err != nil {
+            return 0, 0, err
+        }
+    }
+    if s := r.URL.Query().Get("from"); s != "" {
+        from, err = revisionNumber(s)
+        return from, to, err
+    }
+    if to == 0 {
+        return 0, 0, errors.New("there are no revisions to compare")
+    }
+    from, err = parent(to)
+    return from, to, err
+}
+
+// ProjectDiffHandler serves GET /api/projects/{id}/diff?from=&to=,
+// comparing two revisions of a project; by default the latest and the one
+// before it.
+func ProjectDiffHandler(store storage.Store) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        id := r.PathValue("id")
+        revs, err := store.Revisions(id)
+        if err != nil {
+            storageError(w, err)
+            return
+        }
+        head := 0
+        if len(revs) > 0 {
+            head = revs[len(revs)-1].Number
+        }
+        from, to, err := diffRange(r, head, func(n int) (int, error) {
+            rev, err := store.Revision(id, n)
+            if err != nil {
+                return 0, err
+            }
+            return rev.Parent, nil
+        })
+        if errors.Is(err, storage.ErrNotFound) {
+            storageError(w, err)
+            return
+        }
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+
+        var a breadboard.State
+        if from > 0 {
+            rev, err := store.Revision(id, from)
+            if err != nil {
+                storageError(w, err)
+                return
+            }
+            a = rev.State
+        }
+        rev, err := store.Revision(id, to)
+        if err != nil {
+            storageError(w, err)
+            return
+        }
+        writeJSON(w, http.StatusOK, breadboard.Compare(a, rev.State))
+    }
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "breadboard-simulator/circuit"
+    "breadboard-simulator/jobs"
+    "breadboard-simulator/waveform"
+)
+
+func jobError(w http.ResponseWriter, err error) {
+    switch {
+    case errors.Is(err, jobs.ErrNotFound):
+        http.Error(w, err.Error(), http.StatusNotFound)
+    case errors.Is(err, jobs.ErrNotFinished):
+        http.Error(w, err.Error(), http.StatusConflict)
+    case errors.Is(err, jobs.ErrQueueFull), errors.Is(err, jobs.ErrClosed):
+        http.Error(w, err.Error(), http.StatusServiceUnavailable)
+    default:
+        http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+    }
+}
+
+// JobsHandler serves /api/jobs: GET lists the jobs, POST submits a
+// simulation of {components, connections} or {breadboard}. An analysis as
+// for /api/export/waveform ("dc", "tran" or "drain") yields a dataset,
+// and "montecarlo" runs a Monte Carlo analysis with the montecarlo options;
+// without one the job finds the operating point. The new job is returned
+// with 202 Accepted.
+func JobsHandler(queue *jobs.Queue) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        switch r.Method {
+        case http.MethodGet:
+            writeJSON(w, http.StatusOK, queue.List())
+
+        case http.MethodPost:
+            var input struct {
+                circuitInput
+                Title      string                    `json:"title"`
+                Analysis   waveform.Analysis         `json:"analysis"`
+                MonteCarlo circuit.MonteCarloOptions `json:"montecarlo"`
+            }
+            if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            c, err := input.circuit()
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+                return
+            }
+
+            kind := input.Analysis.Type
+            if kind == "" {
+                kind = "op"
+            }
+            job, err := queue.Submit(kind, func(ctx context.Context, progress func(float64)) (interface{}, error) {
+                switch kind {
+                case "op":
+                    return circuit.Solve(c)
+                case "montecarlo":
+                    return circuit.MonteCarloContext(circuit.WithProgress(ctx, progress), c, input.MonteCarlo)
+                }
+                return waveform.RunContext(circuit.WithProgress(ctx, progress), input.Title, c, input.Analysis)
+            })
+            if err != nil {
+                jobError(w, err)
+                return
+            }
+            w.Header().Set("Location", "/api/jobs/"+job.ID)
+            writeJSON(w, http.StatusAccepted, job)
+
+        default:
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+    }
+}
+
+// JobHandler serves /api/jobs/{id}: GET returns the job's status and
+// progress and DELETE cancels it, as does POST .../cancel. GET
+// .../result returns the result of a finished job, a dataset in the
+// waveform format given by ?format= if asked for.
+func JobHandler(queue *jobs.Queue) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        id := r.PathValue("id")
+        switch action := r.PathValue("action"); {
+        case action == "" && r.Method == http.MethodGet:
+            job, err := queue.Get(id)
+            if err != nil {
+                jobError(w, err)
+                return
+            }
+            writeJSON(w, http.StatusOK, job)
+
+        case (action == "" && r.Method == http.MethodDelete) || (action == "cancel" && r.Method == http.MethodPost):
+            job, err := queue.Cancel(id)
+            if err != nil {
+                jobError(w, err)
+                return
+            }
+            writeJSON(w, http.StatusOK, job)
+
+        case action == "result" && r.Method == http.MethodGet:
+            result, _, err := queue.Result(id)
+            if err != nil {
+                jobError(w, err)
+                return
+            }
+            format := r.URL.Query().Get("format")
+            d, ok := result.(*waveform.Dataset)
+            if format == "" || format == "json" {
+                writeJSON(w, http.StatusOK, result)
+                return
+            }
+            contentType, known := waveformTypes[format]
+            if !ok || !known {
+                http.Error(w, "cannot write this result as "+format, http.StatusBadRequest)
+                return
+            }
+            var out bytes.Buffer
+            if err := waveform.Write(&out, d, format, waveform.VCDOptions{}); err != nil {
+                http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+                return
+            }
+            w.Header().Set("Content-Type", contentType)
+            w.Header().Set("Content-Disposition", "attachment; filename="+waveform.FileName(format))
+            w.Write(out.Bytes())
+
+        case action != "" && action != "cancel" && action != "result":
+            http.NotFound(w, r)
+
+        default:
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+    }
+}
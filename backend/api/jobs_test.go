@@ -0,0 +1,73 @@
+package api
+
+import (
+    "math"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+    "breadboard-simulator/circuit"
+    "breadboard-simulator/jobs"
+)
+
+// waitJob polls the job at path until it has finished.
+func waitJob(t *testing.T, h http.Handler, path string) jobs.Job {
+    t.Helper()
+    deadline := time.Now().Add(5 * time.Second)
+    for {
+        var job jobs.Job
+        decode(t, do(h, "GET", path, ""), http.StatusOK, &job)
+        if job.State.Finished() {
+            return job
+        }
+        if time.Now().After(deadline) {
+            t.Fatalf("job still %s", job.State)
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+}
+
+func TestJobs(t *testing.T) {
+    h := newTestRouter(t)
+    w := do(h, "POST", "/api/jobs", divider)
+    var job jobs.Job
+    decode(t, w, http.StatusAccepted, &job)
+    if job.Kind != "op" || w.Header().Get("Location") != "/api/jobs/"+job.ID {
+        t.Fatalf("submitted %+v at %s", job, w.Header().Get("Location"))
+    }
+    if job = waitJob(t, h, "/api/jobs/"+job.ID); job.State != jobs.Done {
+        t.Fatalf("job %+v", job)
+    }
+    var sol circuit.Solution
+    decode(t, do(h, "GET", "/api/jobs/"+job.ID+"/result", ""), http.StatusOK, &sol)
+    if v := sol.NodeVoltages["out"]; math.Abs(v-5) > 1e-6 {
+        t.Errorf("out = %v", v)
+    }
+
+    // a transient job's dataset can be fetched as a waveform file
+    tran := strings.Replace(divider, `"components"`, `"analysis": {"type": "tran", "step": 1e-4, "stop": 1e-3}, "components"`, 1)
+    decode(t, do(h, "POST", "/api/jobs", tran), http.StatusAccepted, &job)
+    waitJob(t, h, "/api/jobs/"+job.ID)
+    w = do(h, "GET", "/api/jobs/"+job.ID+"/result?format=csv", "")
+    if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") || strings.Count(w.Body.String(), "\n") != 12 {
+        t.Errorf("csv result: %d %s", w.Code, w.Body)
+    }
+}
+
+func TestJobErrors(t *testing.T) {
+    h := newTestRouter(t)
+    var job jobs.Job
+    decode(t, do(h, "POST", "/api/jobs", `{"analysis": {"type": "ac"}, "components": []}`), http.StatusAccepted, &job)
+    if job = waitJob(t, h, "/api/jobs/"+job.ID); job.State != jobs.Failed || !strings.Contains(job.Error, "no ac analysis") {
+        t.Errorf("ac job %+v", job)
+    }
+    if w := do(h, "GET", "/api/jobs/"+job.ID+"/result", ""); w.Code != http.StatusUnprocessableEntity {
+        t.Errorf("failed job's result: %d %s", w.Code, w.Body)
+    }
+    if w := do(h, "GET", "/api/jobs/nope", ""); w.Code != http.StatusNotFound {
+        t.Errorf("unknown job: %d", w.Code)
+    }
+    if w := do(h, "POST", "/api/jobs", "{"); w.Code != http.StatusBadRequest {
+        t.Errorf("bad JSON: %d", w.Code)
+    }
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+    "encoding/json"
+    "io"
+    "log"
+    "net/http"
+    "golang.org/x/net/websocket"
+    "breadboard-simulator/live"
+)
+
+// maxLiveMessageSize bounds one message sent over the simulation socket,
+// which may carry a whole circuit.
+const maxLiveMessageSize = 4 << 20
+
+// LiveSimulationHandler serves the WebSocket at /api/simulate/live for
+// running a transient simulation whose samples are streamed back as they
+// are computed. Clients send JSON text frames to start, pause, resume and
+// cancel it and to change parts while it runs. See package live for the
+// protocol.
+func LiveSimulationHandler() http.HandlerFunc {
+    server := websocket.Server{
+        // the API is open to any origin, as with withCORS
+        Handshake: func(*websocket.Config, *http.Request) error { return nil },
+        Handler: func(ws *websocket.Conn) {
+            defer ws.Close()
+            ws.MaxPayloadBytes = maxLiveMessageSize
+
+            session := live.NewSession(ws.Request().Context(), func(m live.Message) error {
+                return websocket.JSON.Send(ws, m)
+            })
+            defer session.Close()
+
+            for {
+                var data string
+                if err := websocket.Message.Receive(ws, &data); err != nil {
+                    if err != io.EOF {
+                        log.Printf("Live simulation: %v\n", err)
+                    }
+                    return
+                }
+                var m live.Message
+                if err := json.Unmarshal([]byte(data), &m); err != nil {
+                    session.Reject(err)
+                    continue
+                }
+                session.Handle(m)
+            }
+        },
+    }
+    return server.ServeHTTP
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+    "encoding/json"
+    "net/http"
+    "breadboard-simulator/circuit"
+    "breadboard-simulator/storage"
+)
+
+// ModulesHandler serves /api/modules, the library of subcircuits shared
+// between projects: GET lists them, POST saves a subcircuit
+// {name, description, ports, params, components, subcircuits}, replacing
+// the module of its name. To use a module, a circuit copies its definition
+// into its own subcircuits.
+func ModulesHandler(store storage.Store) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        switch r.Method {
+        case http.MethodGet:
+            modules, err := store.Modules()
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusInternalServerError)
+                return
+            }
+            writeJSON(w, http.StatusOK, modules)
+
+        case http.MethodPost:
+            var sub circuit.Subcircuit
+            if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            saveModule(w, store, sub, http.StatusCreated)
+
+        default:
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+    }
+}
+
+// ModuleHandler serves /api/modules/{name}: GET returns the module, PUT
+// replaces it with the subcircuit in the body and DELETE removes it.
+func ModuleHandler(store storage.Store) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        name := r.PathValue("name")
+        switch r.Method {
+        case http.MethodGet:
+            m, err := store.Module(name)
+            if err != nil {
+                storageError(w, err)
+                return
+            }
+            writeJSON(w, http.StatusOK, m)
+
+        case http.MethodPut:
+            var sub circuit.Subcircuit
+            if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            sub.Name = name
+            saveModule(w, store, sub, http.StatusOK)
+
+        case http.MethodDelete:
+            if err := store.DeleteModule(name); err != nil {
+                storageError(w, err)
+                return
+            }
+            w.WriteHeader(http.StatusNoContent)
+
+        default:
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+    }
+}
+
+// saveModule checks a subcircuit, including that it flattens, and stores
+// it in the library.
+func saveModule(w http.ResponseWriter, store storage.Store, sub circuit.Subcircuit, status int) {
+    if err := sub.Validate(); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    probe := &circuit.Circuit{
+        Components:  []circuit.Component{{ID: "X", Type: circuit.SubcircuitInstance, Nodes: sub.Ports, Properties: map[string]interface{}{circuit.SubcircuitProperty: sub.Name}}},
+        Subcircuits: []circuit.Subcircuit{sub},
+    }
+    if _, err := circuit.Flatten(probe); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+
+    m := &storage.Module{Subcircuit: sub}
+    if err := store.SaveModule(m); err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    writeJSON(w, status, m)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+    "errors"
+    "io"
+    "net/http"
+    "strings"
+    "breadboard-simulator/parts"
+)
+
+// PartsHandler serves /api/parts: GET searches the parts library by
+// number or description with ?q= and by ?category=, and lists the
+// categories.
+func PartsHandler(lib *parts.Library) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        query := r.URL.Query()
+        writeJSON(w, http.StatusOK, map[string]interface{}{
+            "parts":      lib.Search(query.Get("q"), query.Get("category")),
+            "categories": lib.Categories(),
+        })
+    }
+}
+
+// PartHandler serves /api/parts/{number}: GET returns the part and the
+// properties a component made from it takes.
+func PartHandler(lib *parts.Library) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        p, err := lib.Get(r.PathValue("number"))
+        if errors.Is(err, parts.ErrNotFound) {
+            http.Error(w, err.Error(), http.StatusNotFound)
+            return
+        }
+        writeJSON(w, http.StatusOK, map[string]interface{}{
+            "part":       p,
+            "properties": p.ComponentProperties(),
+        })
+    }
+}
+
+// ImportPartsHandler serves POST /api/parts/import: it adds a part for
+// every .model card of a SPICE model library, sent as the "file" field of
+// a multipart form or as the raw request body. ?category= files the new
+// parts; otherwise they are filed by element.
+func ImportPartsHandler(lib *parts.Library) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+        var body io.Reader = r.Body
+        name := r.URL.Query().Get("name")
+        // a plain text body would be consumed by parsing it as a form
+        if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+            file, header, err := r.FormFile("file")
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            defer file.Close()
+            body = file
+            if name == "" {
+                name = header.Filename
+            }
+        }
+        if name == "" {
+            name = "imported.lib"
+        }
+
+        added, err := lib.Import(body, name, r.URL.Query().Get("category"))
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        if len(added) == 0 {
+            http.Error(w, "no diode, transistor or MOSFET models found", http.StatusBadRequest)
+            return
+        }
+        writeJSON(w, http.StatusCreated, map[string]interface{}{"parts": added})
+    }
+}
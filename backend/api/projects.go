@@ -0,0 +1,194 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "io"
+    "net/http"
+    "strings"
+    "breadboard-simulator/breadboard"
+    "breadboard-simulator/project"
+    "breadboard-simulator/savefile"
+)
+
+// projectError reports a project store error with a matching status.
+func projectError(w http.ResponseWriter, err error) {
+    switch {
+    case errors.Is(err, project.ErrNotFound):
+        http.Error(w, err.Error(), http.StatusNotFound)
+    case errors.Is(err, project.ErrInvalidName):
+        http.Error(w, err.Error(), http.StatusBadRequest)
+    default:
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+    }
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(v)
+}
+
+// ProjectsHandler serves /api/projects: GET lists the projects, POST
+// creates one from {name, description, state}.
+func ProjectsHandler(store project.Store) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        switch r.Method {
+        case http.MethodGet:
+            list, err := store.List()
+            if err != nil {
+                projectError(w, err)
+                return
+            }
+            writeJSON(w, http.StatusOK, list)
+
+        case http.MethodPost:
+            var input struct {
+                Name        string           `json:"name"`
+                Description string           `json:"description"`
+                State       breadboard.State `json:"state"`
+            }
+            if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            info, err := store.Create(input.Name, input.Description, input.State)
+            if err != nil {
+                projectError(w, err)
+                return
+            }
+            writeJSON(w, http.StatusCreated, info)
+
+        default:
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+    }
+}
+
+// ImportProjectHandler serves POST /api/projects/import: it stores an
+// uploaded save file, of any schema version, as a new project. The file
+// is the request body or the multipart "file" field; the name comes from a
+// "name" field or query parameter, or else the file's metadata.
+func ImportProjectHandler(store project.Store) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+        var body io.Reader = r.Body
+        name := r.URL.Query().Get("name")
+        if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+            file, _, err := r.FormFile("file")
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            defer file.Close()
+            body = file
+            if v := r.FormValue("name"); v != "" {
+                name = v
+            }
+        }
+
+        f, err := savefile.Load(body)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        if name == "" && f.Metadata.Name == "" {
+            name = "Imported breadboard"
+        }
+        info, err := store.Import(f, name)
+        if err != nil {
+            projectError(w, err)
+            return
+        }
+        writeJSON(w, http.StatusCreated, info)
+    }
+}
+
+// ProjectHandler serves one project at /api/projects/{id}: GET returns it,
+// PUT saves {State} into it and DELETE removes it. Actions are posted to
+// /api/projects/{id}/{action}: "rename" and "duplicate" take {Name}, and
+// GET "download" returns the save file as an attachment.
+func ProjectHandler(store project.Store) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        id := r.PathValue("id")
+        switch action := r.PathValue("action"); {
+        case action == "" && r.Method == http.MethodGet:
+            p, err := store.Get(id)
+            if err != nil {
+                projectError(w, err)
+                return
+            }
+            writeJSON(w, http.StatusOK, p)
+
+        case action == "" && r.Method == http.MethodPut:
+            var input struct {
+                State breadboard.State `json:"state"`
+            }
+            if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            info, err := store.Save(id, input.State)
+            if err != nil {
+                projectError(w, err)
+                return
+            }
+            writeJSON(w, http.StatusOK, info)
+
+        case action == "" && r.Method == http.MethodDelete:
+            if err := store.Delete(id); err != nil {
+                projectError(w, err)
+                return
+            }
+            w.WriteHeader(http.StatusNoContent)
+
+        case (action == "rename" || action == "duplicate") && r.Method == http.MethodPost:
+            var input struct {
+                Name string `json:"name"`
+            }
+            if err := json.NewDecoder(r.Body).Decode(&input); err != nil && err != io.EOF {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            var info *project.Info
+            var err error
+            status := http.StatusOK
+            if action == "rename" {
+                info, err = store.Rename(id, input.Name)
+            } else {
+                info, err = store.Duplicate(id, input.Name)
+                status = http.StatusCreated
+            }
+            if err != nil {
+                projectError(w, err)
+                return
+            }
+            writeJSON(w, status, info)
+
+        case action == "download" && r.Method == http.MethodGet:
+            p, err := store.Get(id)
+            if err != nil {
+                projectError(w, err)
+                return
+            }
+            data, err := json.MarshalIndent(p.File, "", "  ")
+            if err != nil {
+                projectError(w, err)
+                return
+            }
+            w.Header().Set("Content-Type", "application/json")
+            w.Header().Set("Content-Disposition", "attachment; filename="+id+".json")
+            w.Write(data)
+
+        case action != "" && action != "rename" && action != "duplicate" && action != "download":
+            http.NotFound(w, r)
+
+        default:
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+        }
+    }
+}
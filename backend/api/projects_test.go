@@ -0,0 +1,74 @@
+package api
+
+import (
+    "net/http"
+    "strings"
+    "testing"
+    "breadboard-simulator/project"
+)
+
+func TestProjects(t *testing.T) {
+    h := newTestRouter(t)
+    var info project.Info
+    decode(t, do(h, "POST", "/api/projects", `{"name": "Torch", "state": {"components": [{"id": "r1", "type": "resistor"}]}}`), http.StatusCreated, &info)
+    if info.ID == "" || info.Name != "Torch" {
+        t.Fatalf("created %+v", info)
+    }
+    path := "/api/projects/" + info.ID
+
+    var list []project.Info
+    decode(t, do(h, "GET", "/api/projects", ""), http.StatusOK, &list)
+    if len(list) != 1 || list[0].ID != info.ID {
+        t.Errorf("list = %+v", list)
+    }
+
+    decode(t, do(h, "PUT", path, `{"state": {"components": [{"id": "r1", "type": "resistor"}, {"id": "led1", "type": "led"}]}}`), http.StatusOK, &info)
+    var p project.Project
+    decode(t, do(h, "GET", path, ""), http.StatusOK, &p)
+    if len(p.File.State.Components) != 2 {
+        t.Errorf("saved state = %+v", p.File.State)
+    }
+
+    decode(t, do(h, "POST", path+"/rename", `{"name": "Lamp"}`), http.StatusOK, &info)
+    if info.Name != "Lamp" {
+        t.Errorf("renamed to %q", info.Name)
+    }
+    w := do(h, "GET", path+"/download", "")
+    if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Disposition"), info.ID+".json") {
+        t.Errorf("download: %d %v", w.Code, w.Header())
+    }
+
+    // the download imports as a project of its own
+    var imported project.Info
+    decode(t, do(h, "POST", "/api/projects/import", w.Body.String()), http.StatusCreated, &imported)
+    if imported.ID == info.ID || imported.Name != "Lamp" {
+        t.Errorf("imported %+v", imported)
+    }
+
+    if w := do(h, "DELETE", path, ""); w.Code != http.StatusNoContent {
+        t.Fatalf("delete: %d %s", w.Code, w.Body)
+    }
+    if w := do(h, "GET", path, ""); w.Code != http.StatusNotFound {
+        t.Errorf("deleted project: %d", w.Code)
+    }
+}
+
+func TestProjectErrors(t *testing.T) {
+    h := newTestRouter(t)
+    for _, tt := range []struct {
+        method, path, body string
+        want               int
+    }{
+        {"POST", "/api/projects", `{"name": "  "}`, http.StatusBadRequest},
+        {"POST", "/api/projects", `{`, http.StatusBadRequest},
+        {"PATCH", "/api/projects", ``, http.StatusMethodNotAllowed},
+        {"GET", "/api/projects/0123456789abcdef", ``, http.StatusNotFound},
+        {"PUT", "/api/projects/0123456789abcdef", `{"state": {}}`, http.StatusNotFound},
+        {"POST", "/api/projects/import", `not a save file`, http.StatusBadRequest},
+        {"GET", "/api/projects/import", ``, http.StatusMethodNotAllowed},
+    } {
+        if w := do(h, tt.method, tt.path, tt.body); w.Code != tt.want {
+            t.Errorf("%s %s: %d %s, want %d", tt.method, tt.path, w.Code, w.Body, tt.want)
+        }
+    }
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "breadboard-simulator/circuit"
+    "breadboard-simulator/storage"
+)
+
+// storageError reports a storage error with a matching status.
+func storageError(w http.ResponseWriter, err error) {
+    if errors.Is(err, storage.ErrNotFound) {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    projectError(w, err)
+}
+
+// RevisionsHandler serves GET /api/projects/{id}/revisions, listing a
+// project's revisions, and GET /api/projects/{id}/revisions/{n}, returning
+// one with its breadboard. POST /api/projects/{id}/revisions/{n}/revert
+// saves revision n's breadboard as the project's newest revision.
+func RevisionsHandler(store storage.Store) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        revert := r.PathValue("action") == "revert"
+        if r.PathValue("action") != "" && !revert {
+            http.NotFound(w, r)
+            return
+        }
+        if revert && r.Method != http.MethodPost || !revert && r.Method != http.MethodGet {
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        id := r.PathValue("id")
+        if r.PathValue("n") == "" {
+            revs, err := store.Revisions(id)
+            if err != nil {
+                storageError(w, err)
+                return
+            }
+            writeJSON(w, http.StatusOK, revs)
+            return
+        }
+
+        n, err := revisionNumber(r.PathValue("n"))
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        rev, err := store.Revision(id, n)
+        if err != nil {
+            storageError(w, err)
+            return
+        }
+        if !revert {
+            writeJSON(w, http.StatusOK, rev)
+            return
+        }
+        info, err := store.Save(id, rev.State)
+        if err != nil {
+            storageError(w, err)
+            return
+        }
+        writeJSON(w, http.StatusOK, info)
+    }
+}
+
+// ResultsHandler serves GET /api/projects/{id}/results, listing the
+// results stored for a project, and GET /api/projects/{id}/results/{rid},
+// returning one with its data.
+func ResultsHandler(store storage.Store) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        id := r.PathValue("id")
+        if rid := r.PathValue("rid"); rid != "" {
+            res, err := store.Result(id, rid)
+            if err != nil {
+                storageError(w, err)
+                return
+            }
+            writeJSON(w, http.StatusOK, res)
+            return
+        }
+        list, err := store.Results(id)
+        if err != nil {
+            storageError(w, err)
+            return
+        }
+        writeJSON(w, http.StatusOK, list)
+    }
+}
+
+// SimulateProjectHandler serves POST /api/projects/{id}/simulate: it
+// solves the project's latest revision, stores the result and returns it.
+func SimulateProjectHandler(store storage.Store) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        id := r.PathValue("id")
+        p, err := store.Get(id)
+        if err != nil {
+            storageError(w, err)
+            return
+        }
+        revs, err := store.Revisions(id)
+        if err != nil {
+            storageError(w, err)
+            return
+        }
+
+        c, warnings, err := p.File.State.Netlist()
+        if err == nil {
+            c, err = prepare(c)
+        }
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+            return
+        }
+        sol, err := circuit.Solve(c)
+        if err != nil {
+            analysisError(w, err, http.StatusUnprocessableEntity)
+            return
+        }
+        data, err := json.Marshal(map[string]interface{}{
+            "results":   sol.Results(),
+            "formatted": sol.FormattedResults(),
+            "warnings":  warnings,
+        })
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+
+        res := &storage.Result{ProjectID: id, Kind: "op", Data: data}
+        if len(revs) > 0 {
+            res.Revision = revs[len(revs)-1].Number
+        }
+        if err := store.AddResult(res); err != nil {
+            storageError(w, err)
+            return
+        }
+        writeJSON(w, http.StatusCreated, res)
+    }
+}
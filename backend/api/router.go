@@ -0,0 +1,125 @@
+package api
+
+import (
+    "fmt"
+    "net/http"
+    "breadboard-simulator/breadboard"
+    "breadboard-simulator/circuit"
+    "breadboard-simulator/collab"
+    "breadboard-simulator/jobs"
+    "breadboard-simulator/parts"
+    "breadboard-simulator/session"
+    "breadboard-simulator/storage"
+)
+
+// Services are what the API is built on.
+type Services struct {
+    Store    storage.Store
+    Sessions *session.Manager
+    Hub      *collab.Hub
+    Jobs     *jobs.Queue
+}
+
+// NewRouter returns the handler for every API endpoint. All of them but
+// the WebSockets answer CORS requests from any origin.
+func NewRouter(s Services) http.Handler {
+    mux := http.NewServeMux()
+    handle := func(pattern string, h http.HandlerFunc) {
+        mux.HandleFunc(pattern, withCORS(h))
+    }
+
+    handle("/", rootHandler)
+    handle("/api/components", ComponentsHandler)
+
+    // the session's own breadboard and its history
+    handle("/api/save", s.Sessions.Handler(SaveHandler(s.Sessions)))
+    handle("/api/load", s.Sessions.Handler(LoadHandler(s.Sessions)))
+    handle("/api/download", s.Sessions.Handler(DownloadHandler(s.Sessions)))
+    handle("/api/upload", s.Sessions.Handler(UploadHandler(s.Sessions)))
+    handle("/api/history", s.Sessions.Handler(HistoryHandler(s.Sessions)))
+    handle("/api/history/{n}", s.Sessions.Handler(HistoryHandler(s.Sessions)))
+    handle("/api/history/{n}/{action}", s.Sessions.Handler(HistoryHandler(s.Sessions)))
+    handle("/api/undo", s.Sessions.Handler(UndoHandler(s.Sessions)))
+    handle("/api/redo", s.Sessions.Handler(RedoHandler(s.Sessions)))
+    handle("/api/diff", s.Sessions.Handler(DiffHandler(s.Sessions)))
+    handle("/api/schema/savefile", SaveFileSchemaHandler)
+
+    // stored projects
+    handle("/api/projects", ProjectsHandler(s.Store))
+    handle("/api/projects/import", ImportProjectHandler(s.Store))
+    handle("/api/projects/{id}", ProjectHandler(s.Store))
+    handle("/api/projects/{id}/{action}", ProjectHandler(s.Store))
+    handle("/api/projects/{id}/revisions", RevisionsHandler(s.Store))
+    handle("/api/projects/{id}/revisions/{n}", RevisionsHandler(s.Store))
+    handle("/api/projects/{id}/revisions/{n}/{action}", RevisionsHandler(s.Store))
+    handle("/api/projects/{id}/diff", ProjectDiffHandler(s.Store))
+    handle("/api/projects/{id}/results", ResultsHandler(s.Store))
+    handle("/api/projects/{id}/results/{rid}", ResultsHandler(s.Store))
+    handle("/api/projects/{id}/simulate", SimulateProjectHandler(s.Store))
+    mux.HandleFunc("/api/projects/{id}/live", LiveEditHandler(s.Hub))
+
+    // simulation
+    handle("/api/simulate", SimulateHandler)
+    handle("/api/power", PowerBudgetHandler)
+    handle("/api/drain", DrainHandler)
+    handle("/api/montecarlo", MonteCarloHandler)
+    mux.HandleFunc("/api/simulate/live", LiveSimulationHandler())
+    handle("/api/jobs", JobsHandler(s.Jobs))
+    handle("/api/jobs/{id}", JobHandler(s.Jobs))
+    handle("/api/jobs/{id}/{action}", JobHandler(s.Jobs))
+
+    // module library
+    handle("/api/modules", ModulesHandler(s.Store))
+    handle("/api/modules/{name}", ModuleHandler(s.Store))
+
+    // parts library
+    handle("/api/parts", PartsHandler(parts.Default))
+    handle("/api/parts/import", ImportPartsHandler(parts.Default))
+    handle("/api/parts/{number}", PartHandler(parts.Default))
+
+    // import and export
+    handle("/api/import/spice", ImportSpiceHandler)
+    handle("/api/import/fritzing", ImportFritzingHandler)
+    handle("/api/import/kicad", ImportKicadHandler)
+    handle("/api/export/spice", ExportSpiceHandler)
+    handle("/api/export/waveform", ExportWaveformHandler)
+    handle("/api/schematic", SchematicHandler)
+
+    return mux
+}
+
+// withCORS lets the frontend call the API from another origin.
+func withCORS(next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Access-Control-Allow-Origin", "*")
+        w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+        w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+session.HeaderName)
+        w.Header().Set("Access-Control-Expose-Headers", session.HeaderName)
+
+        if r.Method == http.MethodOptions {
+            w.WriteHeader(http.StatusOK)
+            return
+        }
+
+        next.ServeHTTP(w, r)
+    }
+}
+
+func rootHandler(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Path != "/" {
+        http.NotFound(w, r)
+        return
+    }
+    fmt.Fprintf(w, "Breadboard Simulator API is running")
+}
+
+// ComponentsHandler serves the component catalog: the parts the frontend
+// offers, with the parameters their property panels edit, and the elements
+// the simulator models them as.
+func ComponentsHandler(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, http.StatusOK, map[string]interface{}{
+        "status": "success",
+        "components": breadboard.Parts(),
+        "elements": circuit.Elements(),
+    })
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+    "breadboard-simulator/collab"
+    "breadboard-simulator/jobs"
+    "breadboard-simulator/session"
+    "breadboard-simulator/storage"
+)
+
+// divider is a circuit request for a 10 V supply split by two 1k
+// resistors.
+const divider = `{"components": [
+    {"id": "B1", "type": "battery", "value": 10, "nodes": ["vcc", "0"]},
+    {"id": "R1", "type": "resistor", "value": 1000, "nodes": ["vcc", "out"]},
+    {"id": "R2", "type": "resistor", "value": 1000, "nodes": ["out", "0"]}
+]}`
+
+func newTestRouter(t *testing.T) http.Handler {
+    t.Helper()
+    db, err := storage.Open(filepath.Join(t.TempDir(), "api.db"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { db.Close() })
+    queue := jobs.NewQueue(jobs.Options{})
+    t.Cleanup(queue.Close)
+    return NewRouter(Services{
+        Store:    db,
+        Sessions: session.NewManager(db, time.Hour),
+        Hub:      collab.NewHub(db),
+        Jobs:     queue,
+    })
+}
+
+func do(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+    r := httptest.NewRequest(method, path, strings.NewReader(body))
+    w := httptest.NewRecorder()
+    h.ServeHTTP(w, r)
+    return w
+}
+
+// decode unmarshals a JSON response into v, failing the test on a status
+// other than want.
+func decode(t *testing.T, w *httptest.ResponseRecorder, want int, v interface{}) {
+    t.Helper()
+    if w.Code != want {
+        t.Fatalf("status %d, want %d: %s", w.Code, want, w.Body)
+    }
+    if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+        t.Fatalf("%v: %s", err, w.Body)
+    }
+}
+
+func TestRoot(t *testing.T) {
+    h := newTestRouter(t)
+    if w := do(h, "GET", "/", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "running") {
+        t.Errorf("root: %d %s", w.Code, w.Body)
+    }
+    if w := do(h, "GET", "/nowhere", ""); w.Code != http.StatusNotFound {
+        t.Errorf("unknown path: %d", w.Code)
+    }
+    if w := do(h, "GET", "/", ""); w.Header().Get("Access-Control-Allow-Origin") != "*" {
+        t.Error("no CORS headers")
+    }
+}
+
+func TestCORSPreflight(t *testing.T) {
+    h := newTestRouter(t)
+    // the project routes save with PUT and delete with DELETE
+    for _, method := range []string{"PUT", "DELETE"} {
+        r := httptest.NewRequest("OPTIONS", "/api/projects/0123456789abcdef", nil)
+        r.Header.Set("Origin", "http://localhost:3000")
+        r.Header.Set("Access-Control-Request-Method", method)
+        w := httptest.NewRecorder()
+        h.ServeHTTP(w, r)
+        allowed := w.Header().Get("Access-Control-Allow-Methods")
+        if w.Code != http.StatusOK || !strings.Contains(allowed, method) {
+            t.Errorf("preflight for %s: %d, allowed %q", method, w.Code, allowed)
+        }
+    }
+}
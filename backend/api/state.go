@@ -0,0 +1,107 @@
+package api
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "breadboard-simulator/breadboard"
+    "breadboard-simulator/savefile"
+    "breadboard-simulator/session"
+)
+
+// SaveHandler serves POST /api/save, storing the posted breadboard as the
+// session's state and committing it to the session's history.
+func SaveHandler(sessions *session.Manager) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+
+        var state breadboard.State
+        if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+
+        if err := sessions.SetState(r, state); err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+
+        w.WriteHeader(http.StatusOK)
+    }
+}
+
+// LoadHandler serves /api/load, returning the session's breadboard.
+func LoadHandler(sessions *session.Manager) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        state, err := sessions.State(r)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        writeJSON(w, http.StatusOK, state)
+    }
+}
+
+// DownloadHandler serves /api/download, returning the session's
+// breadboard as a save file.
+func DownloadHandler(sessions *session.Manager) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        state, err := sessions.State(r)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        f := savefile.New(state, savefile.Metadata{})
+
+        var buf bytes.Buffer
+        if err := savefile.Save(&buf, f); err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+
+        w.Header().Set("Content-Disposition", "attachment; filename=breadboard_state.json")
+        w.Header().Set("Content-Type", "application/json")
+        w.Write(buf.Bytes())
+    }
+}
+
+// UploadHandler serves POST /api/upload, replacing the session's
+// breadboard with the save file in the "file" field of a multipart form.
+func UploadHandler(sessions *session.Manager) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+
+        file, _, err := r.FormFile("file")
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        defer file.Close()
+
+        f, err := savefile.Load(file)
+        if err != nil {
+            http.Error(w, "Invalid save file: "+err.Error(), http.StatusBadRequest)
+            return
+        }
+
+        if err := sessions.SetState(r, f.State); err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+
+        fmt.Fprintf(w, "File uploaded and state updated successfully")
+    }
+}
+
+// SaveFileSchemaHandler returns the JSON Schema of save files.
+func SaveFileSchemaHandler(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/schema+json")
+    w.Write(savefile.Schema)
+}
@@ -0,0 +1,105 @@
+package breadboard
+
+import (
+	"sort"
+
+	"breadboard-simulator/circuit"
+)
+
+// Part describes a part the frontend offers: the element it is simulated
+// as, if any, and the parameters its property panel edits, in the panel's
+// units.
+type Part struct {
+	Type     string                `json:"type"`
+	Element  circuit.ComponentType `json:"element,omitempty"`
+	Label    string                `json:"label"`
+	Pins     []string              `json:"pins"`
+	Params   []circuit.Param       `json:"params"`
+	Analyses []circuit.Analysis    `json:"analyses"`
+}
+
+// unmodelled lists the parts the board holds without simulating them.
+var unmodelled = []Part{
+	{Type: GroundType, Label: "Ground", Pins: []string{"gnd"}},
+	{
+		Type: "ic", Label: "IC", Pins: []string{},
+		Params: []circuit.Param{
+			{Name: "icType", Label: "IC Type", Kind: "text"},
+			{Name: "description", Label: "Description", Kind: "textarea"},
+		},
+	},
+}
+
+// labels names the parts whose label is not their element's.
+var labels = map[string]string{
+	"power_supply": "Power Supply",
+}
+
+// scalePrefixes names the panel unit of a scaled value.
+var scalePrefixes = map[float64]string{
+	1e-12: "p",
+	1e-9:  "n",
+	1e-6:  "µ",
+	1e-3:  "m",
+	1e3:   "k",
+	1e6:   "M",
+}
+
+// Parts returns the catalog of parts, ordered by type, built from the
+// element registry.
+func Parts() []Part {
+	var parts []Part
+	for typ, el := range elements {
+		info, ok := circuit.Lookup(el.kind)
+		if !ok {
+			continue
+		}
+		part := Part{
+			Type:     typ,
+			Element:  el.kind,
+			Label:    info.Label,
+			Pins:     info.Pins,
+			Params:   make([]circuit.Param, len(info.Params)),
+			Analyses: info.Analyses,
+		}
+		if label, ok := labels[typ]; ok {
+			part.Label = label
+		}
+		copy(part.Params, info.Params)
+		if el.scale != 1 {
+			for k, p := range part.Params {
+				if p.Name == info.Value {
+					part.Params[k] = scaleParam(p, el.scale)
+				}
+			}
+		}
+		parts = append(parts, part)
+	}
+	for _, part := range unmodelled {
+		if part.Params == nil {
+			part.Params = []circuit.Param{}
+		}
+		part.Analyses = []circuit.Analysis{}
+		parts = append(parts, part)
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Type < parts[j].Type })
+	return parts
+}
+
+// scaleParam converts a parameter from SI to a panel unit scale times
+// larger.
+func scaleParam(p circuit.Param, scale float64) circuit.Param {
+	p.Unit = scalePrefixes[scale] + p.Unit
+	if v, ok := p.Default.(float64); ok {
+		p.Default = v / scale
+	}
+	if p.Min != nil {
+		min := *p.Min / scale
+		p.Min = &min
+	}
+	if p.Max != nil {
+		max := *p.Max / scale
+		p.Max = &max
+	}
+	return p
+}
@@ -0,0 +1,26 @@
+package breadboard
+
+import "testing"
+
+func TestParts(t *testing.T) {
+	parts := make(map[string]Part)
+	for _, p := range Parts() {
+		parts[p.Type] = p
+	}
+	for typ := range elements {
+		if _, ok := parts[typ]; !ok {
+			t.Errorf("no %s in the catalog", typ)
+		}
+	}
+
+	c := parts["capacitor"]
+	if c.Params[0].Name != "capacitance" || c.Params[0].Unit != "µF" || c.Params[0].Default != 1.0 {
+		t.Errorf("capacitance = %+v", c.Params[0])
+	}
+	if ps := parts["power_supply"]; ps.Element != "battery" || ps.Label != "Power Supply" {
+		t.Errorf("power supply = %+v", ps)
+	}
+	if ic, ok := parts["ic"]; !ok || ic.Element != "" || len(ic.Analyses) != 0 {
+		t.Errorf("ic = %+v", ic)
+	}
+}
@@ -0,0 +1,195 @@
+package breadboard
+
+import (
+	"reflect"
+	"sort"
+
+	"breadboard-simulator/circuit"
+)
+
+// Move is a component or custom point that changed position.
+type Move struct {
+	ID   string   `json:"id"`
+	From Position `json:"from"`
+	To   Position `json:"to"`
+}
+
+// PropertyChange is a component property that changed value. Old is nil
+// for an added property and New is nil for a removed one.
+type PropertyChange struct {
+	ID       string      `json:"id"`
+	Property string      `json:"property"`
+	Old      interface{} `json:"old"`
+	New      interface{} `json:"new"`
+}
+
+// ParamChange is a circuit parameter that changed value. Old is nil for an
+// added parameter and New is nil for a removed one.
+type ParamChange struct {
+	Name string      `json:"name"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// Diff is the structural difference between two breadboard states. A
+// component whose type changed counts as removed and added again.
+// Connections are compared regardless of direction, and subcircuits by
+// name.
+type Diff struct {
+	Added              []Component      `json:"added,omitempty"`
+	Removed            []Component      `json:"removed,omitempty"`
+	Moved              []Move           `json:"moved,omitempty"`
+	Changed            []PropertyChange `json:"changed,omitempty"`
+	ConnectionsAdded   []Connection     `json:"connectionsAdded,omitempty"`
+	ConnectionsRemoved []Connection     `json:"connectionsRemoved,omitempty"`
+	PointsAdded        []CustomPoint    `json:"pointsAdded,omitempty"`
+	PointsRemoved      []CustomPoint    `json:"pointsRemoved,omitempty"`
+	PointsMoved        []Move           `json:"pointsMoved,omitempty"`
+	// PinsChanged lists the components whose connection points changed.
+	PinsChanged        []string      `json:"pinsChanged,omitempty"`
+	ParamsChanged      []ParamChange `json:"paramsChanged,omitempty"`
+	SubcircuitsAdded   []string      `json:"subcircuitsAdded,omitempty"`
+	SubcircuitsRemoved []string      `json:"subcircuitsRemoved,omitempty"`
+	SubcircuitsChanged []string      `json:"subcircuitsChanged,omitempty"`
+}
+
+// Empty reports whether the states were the same.
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Moved) == 0 && len(d.Changed) == 0 &&
+		len(d.ConnectionsAdded) == 0 && len(d.ConnectionsRemoved) == 0 &&
+		len(d.PointsAdded) == 0 && len(d.PointsRemoved) == 0 && len(d.PointsMoved) == 0 &&
+		len(d.PinsChanged) == 0 && len(d.ParamsChanged) == 0 &&
+		len(d.SubcircuitsAdded) == 0 && len(d.SubcircuitsRemoved) == 0 && len(d.SubcircuitsChanged) == 0
+}
+
+// Compare returns what changed from a to b.
+func Compare(a, b State) *Diff {
+	d := &Diff{}
+
+	old := make(map[string]Component, len(a.Components))
+	for _, c := range a.Components {
+		old[c.ID] = c
+	}
+	seen := make(map[string]bool, len(b.Components))
+	for _, c := range b.Components {
+		seen[c.ID] = true
+		prev, ok := old[c.ID]
+		if !ok || prev.Type != c.Type {
+			if ok {
+				d.Removed = append(d.Removed, prev)
+			}
+			d.Added = append(d.Added, c)
+			continue
+		}
+		if prev.Position != c.Position {
+			d.Moved = append(d.Moved, Move{c.ID, prev.Position, c.Position})
+		}
+		d.Changed = append(d.Changed, propertyChanges(c.ID, prev.Properties, c.Properties)...)
+		if !reflect.DeepEqual(prev.ConnectionPoints, c.ConnectionPoints) {
+			d.PinsChanged = append(d.PinsChanged, c.ID)
+		}
+	}
+	for _, c := range a.Components {
+		if !seen[c.ID] {
+			d.Removed = append(d.Removed, c)
+		}
+	}
+
+	before := connectionSet(a.Connections)
+	after := connectionSet(b.Connections)
+	for _, c := range b.Connections {
+		if !before[connectionKey(c)] {
+			d.ConnectionsAdded = append(d.ConnectionsAdded, c)
+		}
+	}
+	for _, c := range a.Connections {
+		if !after[connectionKey(c)] {
+			d.ConnectionsRemoved = append(d.ConnectionsRemoved, c)
+		}
+	}
+
+	points := make(map[string]CustomPoint, len(a.CustomConnectionPoints))
+	for _, p := range a.CustomConnectionPoints {
+		points[p.ID] = p
+	}
+	kept := make(map[string]bool, len(b.CustomConnectionPoints))
+	for _, p := range b.CustomConnectionPoints {
+		kept[p.ID] = true
+		prev, ok := points[p.ID]
+		switch {
+		case !ok:
+			d.PointsAdded = append(d.PointsAdded, p)
+		case prev.X != p.X || prev.Y != p.Y:
+			d.PointsMoved = append(d.PointsMoved, Move{p.ID, Position{prev.X, prev.Y}, Position{p.X, p.Y}})
+		}
+	}
+	for _, p := range a.CustomConnectionPoints {
+		if !kept[p.ID] {
+			d.PointsRemoved = append(d.PointsRemoved, p)
+		}
+	}
+
+	for _, c := range propertyChanges("", a.Params, b.Params) {
+		d.ParamsChanged = append(d.ParamsChanged, ParamChange{c.Property, c.Old, c.New})
+	}
+
+	defs := make(map[string]circuit.Subcircuit, len(a.Subcircuits))
+	for _, s := range a.Subcircuits {
+		defs[s.Name] = s
+	}
+	defined := make(map[string]bool, len(b.Subcircuits))
+	for _, s := range b.Subcircuits {
+		defined[s.Name] = true
+		prev, ok := defs[s.Name]
+		switch {
+		case !ok:
+			d.SubcircuitsAdded = append(d.SubcircuitsAdded, s.Name)
+		case !reflect.DeepEqual(prev, s):
+			d.SubcircuitsChanged = append(d.SubcircuitsChanged, s.Name)
+		}
+	}
+	for _, s := range a.Subcircuits {
+		if !defined[s.Name] {
+			d.SubcircuitsRemoved = append(d.SubcircuitsRemoved, s.Name)
+		}
+	}
+	return d
+}
+
+func propertyChanges(id string, a, b map[string]interface{}) []PropertyChange {
+	var names []string
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []PropertyChange
+	for _, name := range names {
+		if !reflect.DeepEqual(a[name], b[name]) {
+			changes = append(changes, PropertyChange{id, name, a[name], b[name]})
+		}
+	}
+	return changes
+}
+
+// connectionKey identifies a connection by its two ends, in either order.
+func connectionKey(c Connection) [2]string {
+	from, to := c.From.key(), c.To.key()
+	if to < from {
+		from, to = to, from
+	}
+	return [2]string{from, to}
+}
+
+func connectionSet(conns []Connection) map[[2]string]bool {
+	set := make(map[[2]string]bool, len(conns))
+	for _, c := range conns {
+		set[connectionKey(c)] = true
+	}
+	return set
+}
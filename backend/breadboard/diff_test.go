@@ -0,0 +1,88 @@
+package breadboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"breadboard-simulator/circuit"
+)
+
+func TestCompare(t *testing.T) {
+	var a, b State
+	if err := json.Unmarshal([]byte(board), &a); err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal([]byte(board), &b)
+
+	if d := Compare(a, b); !d.Empty() {
+		t.Fatalf("identical states differ: %+v", d)
+	}
+
+	b.Components[0].Position = Position{X: 2, Y: 1}   // battery moved
+	b.Components[1].Properties["resistance"] = "2.2k" // value changed
+	delete(b.Components[2].Properties, "maxCurrent")  // property removed
+	b.Components[3].Type = "resistor"                 // ic replaced
+	b.Components = append(b.Components, Component{ID: "c1", Type: "capacitor"})
+	b.Connections[3] = Connection{From: Endpoint{ComponentID: "battery-1", PointIndex: 1}, To: Endpoint{ComponentID: "led-1", PointIndex: 1}} // reversed, same wire
+	b.Connections = append(b.Connections[:1], b.Connections[2:]...)
+	b.CustomConnectionPoints[0].X = 220
+
+	d := Compare(a, b)
+	if len(d.Moved) != 1 || d.Moved[0].ID != "battery-1" || d.Moved[0].To.X != 2 {
+		t.Errorf("moved = %+v", d.Moved)
+	}
+	want := []PropertyChange{
+		{"resistor-1", "resistance", "1k", "2.2k"},
+		{"led-1", "maxCurrent", 20.0, nil},
+	}
+	if len(d.Changed) != len(want) {
+		t.Fatalf("changed = %+v", d.Changed)
+	}
+	for k, c := range want {
+		if d.Changed[k] != c {
+			t.Errorf("change %d = %+v, want %+v", k, d.Changed[k], c)
+		}
+	}
+	if len(d.Added) != 2 || d.Added[0].ID != "ic-1" || d.Added[1].ID != "c1" {
+		t.Errorf("added = %+v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].ID != "ic-1" {
+		t.Errorf("removed = %+v", d.Removed)
+	}
+	if len(d.ConnectionsAdded) != 0 || len(d.ConnectionsRemoved) != 1 || d.ConnectionsRemoved[0].From.ComponentID != "resistor-1" {
+		t.Errorf("connections +%+v -%+v", d.ConnectionsAdded, d.ConnectionsRemoved)
+	}
+	if len(d.PointsMoved) != 1 || d.PointsMoved[0].From.X != 200 {
+		t.Errorf("points moved = %+v", d.PointsMoved)
+	}
+}
+
+func TestCompareParamsAndSubcircuits(t *testing.T) {
+	divider := circuit.Subcircuit{Name: "divider", Ports: []string{"in", "out"}}
+	a := State{
+		Components:  []Component{{ID: "r1", Type: "resistor", ConnectionPoints: DefaultConnectionPoints(2)}},
+		Params:      map[string]interface{}{"VCC": 5.0},
+		Subcircuits: []circuit.Subcircuit{divider, {Name: "filter"}},
+	}
+	b := a
+	b.Components = []Component{{ID: "r1", Type: "resistor", ConnectionPoints: DefaultConnectionPoints(3)}}
+	b.Params = map[string]interface{}{"VCC": 9.0, "RB": "4.7k"}
+	divider.Ports = []string{"in", "out", "gnd"}
+	b.Subcircuits = []circuit.Subcircuit{divider, {Name: "buffer"}}
+
+	d := Compare(a, b)
+	if d.Empty() {
+		t.Fatal("states differing in pins, parameters and subcircuits compare equal")
+	}
+	if len(d.PinsChanged) != 1 || d.PinsChanged[0] != "r1" {
+		t.Errorf("pins changed = %v", d.PinsChanged)
+	}
+	want := []ParamChange{{"RB", nil, "4.7k"}, {"VCC", 5.0, 9.0}}
+	if len(d.ParamsChanged) != len(want) || d.ParamsChanged[0] != want[0] || d.ParamsChanged[1] != want[1] {
+		t.Errorf("params changed = %+v", d.ParamsChanged)
+	}
+	if fmt.Sprint(d.SubcircuitsAdded, d.SubcircuitsRemoved, d.SubcircuitsChanged) != "[buffer] [filter] [divider]" {
+		t.Errorf("subcircuits +%v -%v ~%v", d.SubcircuitsAdded, d.SubcircuitsRemoved, d.SubcircuitsChanged)
+	}
+}
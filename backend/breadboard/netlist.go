@@ -0,0 +1,176 @@
+package breadboard
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"breadboard-simulator/circuit"
+)
+
+// element describes how a frontend part maps onto a simulator component:
+// the element it becomes and the factor from the property panel's unit for
+// the element's value to SI.
+type element struct {
+	kind  circuit.ComponentType
+	scale float64
+}
+
+var elements = map[string]element{
+	"resistor":       {circuit.Resistor, 1},
+	"capacitor":      {circuit.Capacitor, 1e-6},
+	"inductor":       {circuit.Inductor, 1},
+	"diode":          {circuit.Diode, 1},
+	"led":            {circuit.LED, 1},
+	"battery":        {circuit.Battery, 1},
+	"power_supply":   {circuit.Battery, 1},
+	"current_source": {circuit.CurrentSource, 1},
+	"wire":           {circuit.Wire, 1},
+	"transistor":     {circuit.Transistor, 1},
+	"switch":         {circuit.Switch, 1},
+	"potentiometer":  {circuit.Potentiometer, 1},
+	"subcircuit":     {circuit.SubcircuitInstance, 1},
+}
+
+// property is the part property holding the element's value, from the
+// element registry, or "" for an element without one.
+func (el element) property() string {
+	info, _ := circuit.Lookup(el.kind)
+	return info.Value
+}
+
+// GroundType is the part that marks the reference node. Without one, the
+// negative pin of the first battery or power supply is ground.
+const GroundType = "ground"
+
+// Netlist converts the board into a circuit wired by net name. Pins joined
+// by wires, directly or through custom points, share a net; pin 0 of a part
+// is its positive terminal. Parts with no simulation model are left out
+// and their IDs returned.
+func (s *State) Netlist() (*circuit.Circuit, []string, error) {
+	nets := newUnionFind()
+	for _, conn := range s.Connections {
+		nets.union(conn.From.key(), conn.To.key())
+	}
+
+	ground := ""
+	for _, comp := range s.Components {
+		if comp.Type == GroundType {
+			ground = nets.find(pinKey(comp.ID, 0))
+			break
+		}
+	}
+	if ground == "" {
+		for _, comp := range s.Components {
+			if elements[comp.Type].kind == circuit.Battery {
+				ground = nets.find(pinKey(comp.ID, 1))
+				break
+			}
+		}
+	}
+
+	names := make(map[string]string)
+	netName := func(pin string) string {
+		root := nets.find(pin)
+		if root == ground {
+			return "0"
+		}
+		if name, ok := names[root]; ok {
+			return name
+		}
+		names[root] = "n" + strconv.Itoa(len(names)+1)
+		return names[root]
+	}
+
+	c := &circuit.Circuit{Subcircuits: s.Subcircuits, Params: s.Params}
+	var unsupported []string
+	for _, comp := range s.Components {
+		if comp.Type == GroundType {
+			continue
+		}
+		el, ok := elements[comp.Type]
+		if !ok {
+			unsupported = append(unsupported, comp.ID)
+			continue
+		}
+
+		simComp := circuit.Component{
+			ID:         comp.ID,
+			Type:       el.kind,
+			Properties: comp.Properties,
+		}
+		if property := el.property(); property != "" {
+			v, ok := el.value(comp.Properties[property])
+			text, _ := comp.Properties[property].(string)
+			switch {
+			case ok:
+				simComp.Value = v
+			case strings.TrimSpace(text) != "":
+				// an expression, in the panel's units like a number
+				simComp.Expression = text
+				if el.scale != 1 {
+					simComp.Expression = fmt.Sprintf("(%s)*%g", text, el.scale)
+				}
+			case simComp.Text("part") == "":
+				// a part from the library brings its own value
+				return nil, nil, fmt.Errorf("%s %s has no %s", comp.Type, comp.ID, property)
+			}
+		}
+
+		pins := len(comp.ConnectionPoints)
+		if pins < 2 {
+			pins = 2
+		}
+		for pin := 0; pin < pins; pin++ {
+			simComp.Nodes = append(simComp.Nodes, netName(pinKey(comp.ID, pin)))
+		}
+		c.Components = append(c.Components, simComp)
+	}
+	return c, unsupported, nil
+}
+
+// value converts a panel value to SI. Bare numbers are in the panel's
+// unit; a value written with a prefix or unit, such as "10uF", is already
+// SI and is not scaled again.
+func (el element) value(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v * el.scale, true
+	case int:
+		return float64(v) * el.scale, true
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return f * el.scale, true
+		}
+		f, err := circuit.ParseValue(v)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func pinKey(compID string, pin int) string {
+	return Endpoint{ComponentID: compID, PointIndex: pin}.key()
+}
+
+type unionFind map[string]string
+
+func newUnionFind() unionFind {
+	return make(unionFind)
+}
+
+func (u unionFind) find(x string) string {
+	parent, ok := u[x]
+	if !ok || parent == x {
+		return x
+	}
+	root := u.find(parent)
+	u[x] = root
+	return root
+}
+
+func (u unionFind) union(a, b string) {
+	ra, rb := u.find(a), u.find(b)
+	if ra != rb {
+		u[ra] = rb
+	}
+}
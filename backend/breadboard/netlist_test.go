@@ -0,0 +1,84 @@
+package breadboard
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"breadboard-simulator/circuit"
+)
+
+const board = `{
+  "components": [
+    {"id": "battery-1", "type": "battery", "position": {"x": 1, "y": 1},
+     "properties": {"voltage": 9, "capacity": 500},
+     "connectionPoints": [{"x": 0, "y": 12.5}, {"x": 50, "y": 12.5}]},
+    {"id": "resistor-1", "type": "resistor", "position": {"x": 4, "y": 1},
+     "properties": {"resistance": "1k", "powerRating": 0.25}},
+    {"id": "led-1", "type": "led", "position": {"x": 8, "y": 1},
+     "properties": {"forwardVoltage": 2, "maxCurrent": 20}},
+    {"id": "ic-1", "type": "ic", "position": {"x": 8, "y": 4}}
+  ],
+  "connections": [
+    {"from": {"componentId": "battery-1", "pointIndex": 0}, "to": {"componentId": "resistor-1", "pointIndex": 0}},
+    {"from": {"componentId": "resistor-1", "pointIndex": 1}, "to": {"customPointId": 1712345678901}},
+    {"from": {"customPointId": 1712345678901}, "to": {"componentId": "led-1", "pointIndex": 0}},
+    {"from": "led-1:1", "to": "battery-1:1"}
+  ],
+  "customConnectionPoints": [{"x": 200, "y": 100, "id": 1712345678901}]
+}`
+
+func TestNetlist(t *testing.T) {
+	var s State
+	if err := json.Unmarshal([]byte(board), &s); err != nil {
+		t.Fatal(err)
+	}
+	c, unsupported, err := s.Netlist()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unsupported) != 1 || unsupported[0] != "ic-1" {
+		t.Errorf("unsupported = %v, want [ic-1]", unsupported)
+	}
+
+	nodes := make(map[string][]string)
+	for _, comp := range c.Components {
+		nodes[comp.ID] = comp.Nodes
+	}
+	if n := nodes["battery-1"]; n[1] != "0" || n[0] != nodes["resistor-1"][0] {
+		t.Errorf("battery nodes = %v, resistor nodes = %v", n, nodes["resistor-1"])
+	}
+	if nodes["resistor-1"][1] != nodes["led-1"][0] {
+		t.Errorf("custom point did not join resistor %v and LED %v", nodes["resistor-1"], nodes["led-1"])
+	}
+
+	sol, err := circuit.Solve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 9V across 1k, the LED's 2V and its 10 ohm series resistance
+	if i := sol.Currents["resistor-1"]; i < 6.8e-3 || i > 7e-3 {
+		t.Errorf("resistor current = %v, want about 6.9mA", i)
+	}
+}
+
+func TestNetlistPanelUnits(t *testing.T) {
+	// capacitance is edited in µF: a bare number is scaled, "10uF" is not
+	for _, v := range []interface{}{10.0, "10", "10uF", "10µ"} {
+		s := State{Components: []Component{{ID: "c", Type: "capacitor", Properties: map[string]interface{}{"capacitance": v}}}}
+		c, _, err := s.Netlist()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Components[0].Value; math.Abs(got-10e-6) > 1e-18 {
+			t.Errorf("capacitance %#v = %v F, want 1e-5", v, got)
+		}
+	}
+}
+
+func TestNetlistMissingValue(t *testing.T) {
+	s := State{Components: []Component{{ID: "r", Type: "resistor"}}}
+	if _, _, err := s.Netlist(); err == nil {
+		t.Error("Netlist accepted a resistor without a resistance")
+	}
+}
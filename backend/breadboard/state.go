@@ -0,0 +1,130 @@
+// Package breadboard holds the breadboard layout edited in the frontend and
+// turns it into a circuit the simulator can solve.
+package breadboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"breadboard-simulator/circuit"
+)
+
+// Frontend geometry from constants.js: component positions are in grid
+// cells, connection points and custom points in pixels.
+const (
+	GridSize        = 20
+	ComponentWidth  = 66
+	ComponentHeight = 50
+)
+
+// DefaultConnectionPoints returns the pin offsets the frontend gives a new
+// part: one pin either side for two-lead parts, a third underneath for
+// three-lead ones.
+func DefaultConnectionPoints(pins int) []Position {
+	points := []Position{
+		{X: 0, Y: ComponentHeight / 2},
+		{X: ComponentWidth, Y: ComponentHeight / 2},
+	}
+	if pins > 2 {
+		points = append(points, Position{X: ComponentWidth / 2, Y: ComponentHeight})
+	}
+	return points
+}
+
+type State struct {
+	Components             []Component   `json:"components"`
+	Connections            []Connection  `json:"connections"`
+	CustomConnectionPoints []CustomPoint `json:"customConnectionPoints,omitempty"`
+	// Subcircuits defines the modules the board's subcircuit parts
+	// instantiate, copied from the library when they were placed.
+	Subcircuits []circuit.Subcircuit `json:"subcircuits,omitempty"`
+	// Params are the circuit parameters the parts' values may be written
+	// in terms of, such as "VCC" or "2*RB".
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type Component struct {
+	ID         string                 `json:"id"`
+	Type       string                 `json:"type"`
+	Position   Position               `json:"position"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	// ConnectionPoints are the pin offsets drawn by the frontend; only
+	// their count matters to the simulator.
+	ConnectionPoints []Position `json:"connectionPoints,omitempty"`
+}
+
+// CustomPoint is a free-standing junction placed on the board.
+type CustomPoint struct {
+	ID string  `json:"id"`
+	X  float64 `json:"x"`
+	Y  float64 `json:"y"`
+}
+
+type Connection struct {
+	From Endpoint `json:"from"`
+	To   Endpoint `json:"to"`
+}
+
+// Endpoint is one end of a wire: a component pin or a custom point.
+type Endpoint struct {
+	ComponentID   string `json:"componentId,omitempty"`
+	PointIndex    int    `json:"pointIndex"`
+	CustomPointID string `json:"customPointId,omitempty"`
+}
+
+// UnmarshalJSON accepts the frontend's endpoint objects as well as the
+// older plain "componentId" or "componentId:pin" strings.
+func (e *Endpoint) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*e = Endpoint{ComponentID: text}
+		if k := strings.LastIndex(text, ":"); k >= 0 {
+			if pin, err := strconv.Atoi(text[k+1:]); err == nil {
+				*e = Endpoint{ComponentID: text[:k], PointIndex: pin}
+			}
+		}
+		return nil
+	}
+
+	var raw struct {
+		ComponentID   string          `json:"componentId"`
+		PointIndex    int             `json:"pointIndex"`
+		CustomPointID json.RawMessage `json:"customPointId"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid connection endpoint: %s", data)
+	}
+	*e = Endpoint{ComponentID: raw.ComponentID, PointIndex: raw.PointIndex}
+	if len(raw.CustomPointID) > 0 && string(raw.CustomPointID) != "null" {
+		e.CustomPointID = strings.Trim(string(raw.CustomPointID), `"`)
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts the numeric IDs the frontend generates.
+func (p *CustomPoint) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ID json.RawMessage `json:"id"`
+		X  float64         `json:"x"`
+		Y  float64         `json:"y"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = CustomPoint{ID: strings.Trim(string(raw.ID), `"`), X: raw.X, Y: raw.Y}
+	return nil
+}
+
+func (e Endpoint) key() string {
+	if e.CustomPointID != "" {
+		return "point:" + e.CustomPointID
+	}
+	return e.ComponentID + ":" + strconv.Itoa(e.PointIndex)
+}
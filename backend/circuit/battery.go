@@ -0,0 +1,297 @@
+package circuit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+type Chemistry string
+
+const (
+	Alkaline Chemistry = "alkaline"
+	NiMH     Chemistry = "nimh"
+	LiIon    Chemistry = "li-ion"
+)
+
+// DefaultBatteryCapacity is used for batteries without a capacity (mAh).
+const DefaultBatteryCapacity = 500.0
+
+// socPoint is one point of a per-cell open-circuit voltage curve.
+type socPoint struct {
+	soc     float64
+	voltage float64
+}
+
+// chemistryPreset describes a single cell of a battery chemistry.
+type chemistryPreset struct {
+	nominal    float64
+	cutoff     float64
+	resistance float64
+	curve      []socPoint // ascending state of charge
+}
+
+var chemistryPresets = map[Chemistry]chemistryPreset{
+	Alkaline: {
+		nominal:    1.5,
+		cutoff:     0.9,
+		resistance: 0.25,
+		curve: []socPoint{
+			{0, 0.90}, {0.1, 1.05}, {0.2, 1.12}, {0.3, 1.17}, {0.4, 1.21}, {0.5, 1.25},
+			{0.6, 1.29}, {0.7, 1.33}, {0.8, 1.38}, {0.9, 1.45}, {1, 1.58},
+		},
+	},
+	NiMH: {
+		nominal:    1.2,
+		cutoff:     1.0,
+		resistance: 0.05,
+		curve: []socPoint{
+			{0, 1.00}, {0.1, 1.12}, {0.2, 1.18}, {0.3, 1.20}, {0.5, 1.23},
+			{0.7, 1.25}, {0.8, 1.27}, {0.9, 1.30}, {1, 1.40},
+		},
+	},
+	LiIon: {
+		nominal:    3.7,
+		cutoff:     3.0,
+		resistance: 0.08,
+		curve: []socPoint{
+			{0, 3.00}, {0.05, 3.50}, {0.1, 3.68}, {0.2, 3.73}, {0.3, 3.77}, {0.4, 3.79},
+			{0.5, 3.82}, {0.6, 3.87}, {0.7, 3.92}, {0.8, 3.98}, {0.9, 4.06}, {1, 4.20},
+		},
+	},
+}
+
+// batteryChemistry returns the preset named by the chemistry property.
+func batteryChemistry(comp Component) (chemistryPreset, bool) {
+	name := Chemistry(strings.ReplaceAll(comp.Text("chemistry"), "_", "-"))
+	if name == "liion" || name == "lithium" {
+		name = LiIon
+	}
+	preset, ok := chemistryPresets[name]
+	return preset, ok
+}
+
+// batteryCells is the number of series cells needed for the nominal voltage.
+func batteryCells(comp Component, preset chemistryPreset) float64 {
+	return math.Max(1, math.Round(math.Abs(comp.Value)/preset.nominal))
+}
+
+// stateOfCharge returns the stateOfCharge property clamped to [0, 1].
+func stateOfCharge(comp Component) float64 {
+	soc, ok := comp.Float("stateOfCharge")
+	if !ok {
+		return 1
+	}
+	return math.Min(1, math.Max(0, soc))
+}
+
+// openCircuitVoltage is the battery's EMF. Batteries with a chemistry follow
+// its discharge curve for their state of charge; others hold their Value.
+func openCircuitVoltage(comp Component) float64 {
+	preset, ok := batteryChemistry(comp)
+	if !ok {
+		return comp.Value
+	}
+	v := batteryCells(comp, preset) * interpolateCurve(preset.curve, stateOfCharge(comp))
+	if comp.Value < 0 {
+		return -v
+	}
+	return v
+}
+
+// internalResistance is the battery's series resistance. Batteries without
+// an internalResistance property or chemistry are ideal sources.
+func internalResistance(comp Component) float64 {
+	if r, ok := comp.Float("internalResistance"); ok && r >= 0 {
+		return r
+	}
+	if preset, ok := batteryChemistry(comp); ok {
+		return batteryCells(comp, preset) * preset.resistance
+	}
+	return 0
+}
+
+// cutoffVoltage is the terminal voltage at which the battery counts as flat.
+func cutoffVoltage(comp Component) float64 {
+	if v, ok := comp.Float("cutoffVoltage"); ok {
+		return v
+	}
+	if preset, ok := batteryChemistry(comp); ok {
+		return batteryCells(comp, preset) * preset.cutoff
+	}
+	return 0
+}
+
+// batteryCapacity returns the capacity in coulombs from the mAh property.
+func batteryCapacity(comp Component) float64 {
+	mAh, ok := comp.Float("capacity")
+	if !ok || mAh <= 0 {
+		mAh = DefaultBatteryCapacity
+	}
+	return mAh * 3.6
+}
+
+func interpolateCurve(curve []socPoint, soc float64) float64 {
+	k := sort.Search(len(curve), func(i int) bool { return curve[i].soc >= soc })
+	switch {
+	case k == 0:
+		return curve[0].voltage
+	case k == len(curve):
+		return curve[len(curve)-1].voltage
+	}
+	lo, hi := curve[k-1], curve[k]
+	return lo.voltage + (hi.voltage-lo.voltage)*(soc-lo.soc)/(hi.soc-lo.soc)
+}
+
+// DrainOptions bounds a capacity drain simulation. Zero values pick
+// defaults: steps of one percent of charge and a one-year horizon.
+type DrainOptions struct {
+	MaxStepCharge float64 `json:"maxStepCharge"`
+	MaxDuration   float64 `json:"maxDuration"`
+}
+
+// BatteryState is a battery's condition at one point of a drain simulation.
+type BatteryState struct {
+	StateOfCharge   float64 `json:"stateOfCharge"`
+	OpenCircuit     float64 `json:"openCircuitVoltage"`
+	TerminalVoltage float64 `json:"terminalVoltage"`
+	Current         float64 `json:"current"`
+}
+
+type DrainSample struct {
+	Time      float64                 `json:"time"`
+	Batteries map[string]BatteryState `json:"batteries"`
+}
+
+// DrainResult is the outcome of SimulateDrain. Runtime is the time in
+// seconds until Depleted went flat, or the horizon if none did.
+type DrainResult struct {
+	Samples  []DrainSample   `json:"samples"`
+	Runtime  float64         `json:"runtime"`
+	Depleted string          `json:"depleted,omitempty"`
+	Stress   []StressFinding `json:"stress,omitempty"`
+}
+
+// SimulateDrain steps the circuit through time, discharging every battery
+// by the current it delivers, until one falls to its cutoff voltage or runs
+// out of charge. Each step is a DC solve at the batteries' present state of
+// charge, which is accurate while the load changes slowly compared to the
+// discharge.
+func SimulateDrain(c *Circuit, opts DrainOptions) (*DrainResult, error) {
+	return SimulateDrainContext(context.Background(), c, opts)
+}
+
+// SimulateDrainContext is SimulateDrain stopping early with ctx's error
+// once ctx is done. It reports its progress to ctx as the larger of the
+// share of MaxDuration simulated and the share of charge drawn from the
+// fullest-drained battery.
+func SimulateDrainContext(ctx context.Context, c *Circuit, opts DrainOptions) (*DrainResult, error) {
+	if opts.MaxStepCharge <= 0 {
+		opts.MaxStepCharge = 0.01
+	}
+	if opts.MaxDuration <= 0 {
+		opts.MaxDuration = 365 * 24 * 3600
+	}
+	c, err := Flatten(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSupported(c, AnalysisDrain); err != nil {
+		return nil, err
+	}
+	if c, err = fixValues(c); err != nil {
+		return nil, err
+	}
+
+	work := *c
+	work.Components = make([]Component, len(c.Components))
+	soc := make(map[string]float64)
+	for k, comp := range c.Components {
+		if comp.Type == Battery {
+			soc[comp.ID] = stateOfCharge(comp)
+		}
+		work.Components[k] = comp
+	}
+	if len(soc) == 0 {
+		return nil, fmt.Errorf("circuit has no battery to drain")
+	}
+	start := make(map[string]float64, len(soc))
+	for id, s := range soc {
+		start[id] = s
+	}
+
+	result := &DrainResult{}
+	monitor := NewStressMonitor(&work)
+	t := 0.0
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		done := t / opts.MaxDuration
+		for id, initial := range start {
+			if initial > 0 {
+				done = math.Max(done, 1-soc[id]/initial)
+			}
+		}
+		reportProgress(ctx, done)
+
+		for k, comp := range work.Components {
+			if comp.Type == Battery {
+				work.Components[k] = comp.WithProperty("stateOfCharge", soc[comp.ID])
+			}
+		}
+
+		sol, err := Solve(&work)
+		if err != nil {
+			return nil, fmt.Errorf("at t=%gs: %w", t, err)
+		}
+		monitor.Observe(t, sol)
+
+		sample := DrainSample{Time: t, Batteries: make(map[string]BatteryState)}
+		dt := opts.MaxDuration - t
+		for _, comp := range work.Components {
+			if comp.Type != Battery {
+				continue
+			}
+			discharge := -sol.Currents[comp.ID]
+			sample.Batteries[comp.ID] = BatteryState{
+				StateOfCharge:   soc[comp.ID],
+				OpenCircuit:     openCircuitVoltage(comp),
+				TerminalVoltage: sol.Voltages[comp.ID],
+				Current:         discharge,
+			}
+			if result.Depleted == "" && (soc[comp.ID] <= 0 || math.Abs(sol.Voltages[comp.ID]) <= cutoffVoltage(comp)) {
+				result.Depleted = comp.ID
+			}
+			if discharge > 0 {
+				dt = math.Min(dt, opts.MaxStepCharge*batteryCapacity(comp)/discharge)
+			}
+		}
+		result.Samples = append(result.Samples, sample)
+
+		if result.Depleted != "" || t >= opts.MaxDuration {
+			break
+		}
+
+		// never step past the point where a battery empties
+		for id, s := range sample.Batteries {
+			if s.Current > 0 {
+				capacity := batteryCapacity(findComponentByID(&work, id))
+				dt = math.Min(dt, math.Max(soc[id], 1e-9)*capacity/s.Current)
+			}
+		}
+		for id, s := range sample.Batteries {
+			if s.Current > 0 {
+				capacity := batteryCapacity(findComponentByID(&work, id))
+				soc[id] = math.Max(0, soc[id]-s.Current*dt/capacity)
+			}
+		}
+		t += dt
+	}
+
+	result.Runtime = t
+	result.Stress = monitor.Findings()
+	return result, nil
+}
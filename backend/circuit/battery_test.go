@@ -0,0 +1,77 @@
+package circuit
+
+import "testing"
+
+func TestOpenCircuitVoltageCurve(t *testing.T) {
+	b := Component{ID: "B1", Type: Battery, Value: 9,
+		Properties: map[string]interface{}{"chemistry": "Alkaline"}}
+	if v := openCircuitVoltage(b); !isClose(v, 6*1.58) {
+		t.Errorf("fresh 9V alkaline = %v, want %v", v, 6*1.58)
+	}
+	if v := openCircuitVoltage(b.WithProperty("stateOfCharge", 0.55)); !isClose(v, 6*1.27) {
+		t.Errorf("half 9V alkaline = %v, want %v", v, 6*1.27)
+	}
+	if b.Properties["stateOfCharge"] != nil {
+		t.Errorf("WithProperty modified the original component")
+	}
+}
+
+func TestSolveInternalResistance(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 9, Nodes: []string{"vcc", "0"},
+				Properties: map[string]interface{}{"internalResistance": 2.0}},
+			{ID: "R1", Type: Resistor, Value: 16, Nodes: []string{"vcc", "0"}},
+		},
+	}
+	sol, err := Solve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isClose(sol.Voltages["B1"], 8) {
+		t.Errorf("terminal voltage = %v, want 8", sol.Voltages["B1"])
+	}
+	if !isClose(sol.Currents["B1"], -0.5) {
+		t.Errorf("battery current = %v, want -0.5", sol.Currents["B1"])
+	}
+}
+
+func TestSimulateDrainIdealBattery(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 10, Nodes: []string{"vcc", "0"},
+				Properties: map[string]interface{}{"capacity": 100.0}},
+			{ID: "R1", Type: Resistor, Value: 100, Nodes: []string{"vcc", "0"}},
+		},
+	}
+	result, err := SimulateDrain(c, DrainOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 100 mAh at 100 mA
+	if result.Depleted != "B1" || !isClose(result.Runtime, 3600) {
+		t.Errorf("depleted %q after %vs, want B1 after 3600s", result.Depleted, result.Runtime)
+	}
+}
+
+func TestSimulateDrainAlkalineCutoff(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 9, Nodes: []string{"vcc", "0"},
+				Properties: map[string]interface{}{"chemistry": "alkaline", "capacity": 550.0}},
+			{ID: "R1", Type: Resistor, Value: 470, Nodes: []string{"vcc", "0"}},
+		},
+	}
+	result, err := SimulateDrain(c, DrainOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := result.Samples[len(result.Samples)-1].Batteries["B1"]
+	if result.Depleted != "B1" || last.TerminalVoltage > 5.4 {
+		t.Errorf("ended at %+v, want B1 at its 5.4V cutoff", last)
+	}
+	// roughly 15-20 mA from 550 mAh
+	if hours := result.Runtime / 3600; hours < 25 || hours > 40 {
+		t.Errorf("runtime = %.1fh, want 25-40h", hours)
+	}
+}
@@ -1,26 +1,103 @@
 package circuit
 
+import "strings"
+
 type ComponentType string
 
 const (
     Battery  ComponentType = "battery"
     Resistor ComponentType = "resistor"
     CurrentSource ComponentType = "current_source"
+    Capacitor ComponentType = "capacitor"
+    Diode ComponentType = "diode"
+    LED ComponentType = "led"
+    Wire ComponentType = "wire"
+    Inductor ComponentType = "inductor"
+    Transistor ComponentType = "transistor"
+    Mosfet ComponentType = "mosfet"
+    VCVS ComponentType = "vcvs"
+    VCCS ComponentType = "vccs"
+    CCCS ComponentType = "cccs"
+    CCVS ComponentType = "ccvs"
+    Switch ComponentType = "switch"
+    Potentiometer ComponentType = "potentiometer"
+    SubcircuitInstance ComponentType = "subcircuit"
     // Add more component types as needed
 )
 
 type Component struct {
-    ID    string
-    Type  ComponentType
-    Value float64
+    ID    string        `json:"id"`
+    Type  ComponentType `json:"type"`
+    Value float64       `json:"value"`
+    // Expression, when set, is what Value is computed from, such as "2*R1"
+    // or "VCC/2"; see Evaluate.
+    Expression string `json:"expression,omitempty"`
+    // Nodes optionally names the net at each terminal, positive first, as in
+    // a SPICE element card. When any component lists nodes, Connections are
+    // ignored and the circuit is wired by net name instead.
+    Nodes []string `json:"nodes,omitempty"`
+    // Properties holds the part's ratings and options as edited in the
+    // frontend's property panel (powerRating, maxCurrent, voltageRating...).
+    Properties map[string]interface{} `json:"properties,omitempty"`
 }
 
 type Connection struct {
-    From string
-    To   string
+    From string `json:"from"`
+    To   string `json:"to"`
 }
 
 type Circuit struct {
-    Components  []Component
-    Connections []Connection
-}
\ No newline at end of file
+    Components  []Component  `json:"components"`
+    Connections []Connection `json:"connections"`
+    // Subcircuits defines the blocks the circuit's subcircuit instances
+    // use. Flatten expands the instances before solving.
+    Subcircuits []Subcircuit `json:"subcircuits,omitempty"`
+    // Params are named values component expressions are written in terms
+    // of, such as a supply voltage VCC, so that changing one, by hand or in
+    // a sweep, changes every value that depends on it. Each is a number or
+    // a string: an expression over the other parameters and component IDs,
+    // or else a value in engineering notation ("1.5 MΩ").
+    Params map[string]interface{} `json:"params,omitempty"`
+
+    // conducting tracks which diodes are forward biased while solving.
+    conducting map[string]bool
+    // nodeOwners records the component each node is numbered after when
+    // wired by Connections. assignNodeNumbers sets it, so it is only
+    // filled in on a private copy of the circuit.
+    nodeOwners map[string]string
+}
+
+// Float returns a numeric property, accepting numbers and strings in
+// engineering notation.
+func (c Component) Float(name string) (float64, bool) {
+    switch v := c.Properties[name].(type) {
+    case float64:
+        return v, true
+    case int:
+        return float64(v), true
+    case string:
+        f, err := ParseValue(v)
+        return f, err == nil
+    }
+    return 0, false
+}
+
+// WithProperty returns a copy of the component with one property replaced,
+// leaving the original's property map untouched.
+func (c Component) WithProperty(name string, value interface{}) Component {
+    props := make(map[string]interface{}, len(c.Properties)+1)
+    for k, v := range c.Properties {
+        props[k] = v
+    }
+    props[name] = value
+    c.Properties = props
+    return c
+}
+
+// Text returns a text property lower-cased, as the frontend's selects emit.
+func (c Component) Text(name string) string {
+    if v, ok := c.Properties[name].(string); ok {
+        return strings.ToLower(strings.TrimSpace(v))
+    }
+    return ""
+}
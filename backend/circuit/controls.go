@@ -0,0 +1,89 @@
+package circuit
+
+import "math"
+
+// open stands in for an open switch: nothing is stamped for it, so it
+// carries no current.
+const open ComponentType = "open"
+
+// minTrackResistance keeps a potentiometer turned fully to one end from
+// leaving that half of its track open.
+const minTrackResistance = 1e-3
+
+// SwitchClosed reports whether a switch conducts. Its "closed" property is
+// a boolean; a "state" of "closed" or "on" works too.
+func SwitchClosed(comp Component) bool {
+	if closed, ok := comp.Properties["closed"].(bool); ok {
+		return closed
+	}
+	switch comp.Text("state") {
+	case "closed", "on":
+		return true
+	}
+	return false
+}
+
+// WiperPosition returns where a potentiometer's wiper sits on its track,
+// from 0 at its first terminal to 1 at its second. It defaults to the
+// middle.
+func WiperPosition(comp Component) float64 {
+	if p, ok := comp.Float("position"); ok {
+		return math.Max(0, math.Min(1, p))
+	}
+	return 0.5
+}
+
+// WiperID is the ID under which a potentiometer's second half, from its
+// wiper to its second terminal, appears in a Solution.
+func WiperID(id string) string {
+	return id + ".wiper"
+}
+
+// lowerControls returns c with its switches and potentiometers replaced by
+// the wires and resistors they behave as, or c itself when it has none. A
+// closed switch becomes a wire and an open one is left out. A
+// potentiometer with a wiper node (its third) becomes two resistors split
+// at the wiper: the first keeps the potentiometer's ID and the second is
+// WiperID. With only two terminals it is a variable resistor between its
+// first terminal and the wiper.
+func lowerControls(c *Circuit) *Circuit {
+	found := false
+	for _, comp := range c.Components {
+		if comp.Type == Switch || comp.Type == Potentiometer {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return c
+	}
+
+	work := *c
+	work.Components = make([]Component, 0, len(c.Components)+1)
+	for _, comp := range c.Components {
+		switch comp.Type {
+		case Switch:
+			if SwitchClosed(comp) {
+				comp.Type = Wire
+			} else {
+				comp.Type = open
+			}
+		case Potentiometer:
+			pos := WiperPosition(comp)
+			comp.Type = Resistor
+			total := comp.Value
+			comp.Value = math.Max(total*pos, minTrackResistance)
+			if len(comp.Nodes) >= 3 {
+				rest := comp
+				rest.ID = WiperID(comp.ID)
+				rest.Nodes = []string{comp.Nodes[2], comp.Nodes[1]}
+				rest.Value = math.Max(total*(1-pos), minTrackResistance)
+				comp.Nodes = []string{comp.Nodes[0], comp.Nodes[2]}
+				work.Components = append(work.Components, comp, rest)
+				continue
+			}
+		}
+		work.Components = append(work.Components, comp)
+	}
+	return &work
+}
@@ -0,0 +1,460 @@
+package circuit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Expr is a parsed arithmetic expression, such as "2*R1", "VCC/2" or
+// "1/(2*pi*sqrt(L*C))". It has the operators + - * / and ^ (or **),
+// parentheses, numbers in engineering notation ("4.7k", "10uF"), named
+// values and the functions in exprFuncs. pi and e are predefined unless a
+// name shadows them.
+type Expr struct {
+	text string
+	root exprNode
+}
+
+// ParseExpr parses an expression.
+func ParseExpr(text string) (*Expr, error) {
+	p := &exprParser{text: text}
+	p.next()
+	root, err := p.expr()
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", text, err)
+	}
+	if p.tok.kind != tokEnd {
+		return nil, fmt.Errorf("expression %q: unexpected %q at %d", text, p.tok.text, p.tok.pos+1)
+	}
+	return &Expr{text: text, root: root}, nil
+}
+
+// String returns the expression as it was written.
+func (e *Expr) String() string {
+	return e.text
+}
+
+// Eval computes the expression, looking names up with lookup. A name
+// that lookup reports as ErrUnknownName may still be a constant.
+func (e *Expr) Eval(lookup func(name string) (float64, error)) (float64, error) {
+	v, err := e.root.eval(lookup)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", e.text, err)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("%s is not a finite number", e.text)
+	}
+	return v, nil
+}
+
+// Rename returns the expression's text with every name replaced by
+// rename's result for it, leaving the rest as written.
+func (e *Expr) Rename(rename func(name string) string) string {
+	p := &exprParser{text: e.text}
+	var b strings.Builder
+	last := 0
+	for p.next(); p.tok.kind != tokEnd; {
+		tok := p.tok
+		p.next()
+		if tok.kind != tokName || p.isOp("(") {
+			continue
+		}
+		b.WriteString(e.text[last:tok.pos])
+		b.WriteString(rename(tok.text))
+		last = tok.pos + len(tok.text)
+	}
+	b.WriteString(e.text[last:])
+	return b.String()
+}
+
+// Names returns the names the expression refers to, other than functions,
+// in order of first use.
+func (e *Expr) Names() []string {
+	var names []string
+	e.root.names(func(name string) {
+		for _, have := range names {
+			if have == name {
+				return
+			}
+		}
+		names = append(names, name)
+	})
+	return names
+}
+
+// exprFuncs are the functions expressions may call, by name and arity; an
+// arity of -1 takes one or more arguments.
+var exprFuncs = map[string]struct {
+	arity int
+	fn    func(args []float64) float64
+}{
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"ln":    {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log10": {1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sin":   {1, func(a []float64) float64 { return math.Sin(a[0]) }},
+	"cos":   {1, func(a []float64) float64 { return math.Cos(a[0]) }},
+	"tan":   {1, func(a []float64) float64 { return math.Tan(a[0]) }},
+	"asin":  {1, func(a []float64) float64 { return math.Asin(a[0]) }},
+	"acos":  {1, func(a []float64) float64 { return math.Acos(a[0]) }},
+	"atan":  {1, func(a []float64) float64 { return math.Atan(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+	"atan2": {2, func(a []float64) float64 { return math.Atan2(a[0], a[1]) }},
+	"hypot": {2, func(a []float64) float64 { return math.Hypot(a[0], a[1]) }},
+	"min": {-1, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Min(m, v)
+		}
+		return m
+	}},
+	"max": {-1, func(a []float64) float64 {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Max(m, v)
+		}
+		return m
+	}},
+}
+
+// ErrUnknownName is returned by lookups for a name they do not define.
+// Such names may still be constants.
+var ErrUnknownName = errors.New("unknown name")
+
+// exprConstants are the names predefined in every expression.
+var exprConstants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+type exprNode interface {
+	eval(lookup func(string) (float64, error)) (float64, error)
+	names(add func(string))
+}
+
+type numberNode float64
+
+func (n numberNode) eval(func(string) (float64, error)) (float64, error) { return float64(n), nil }
+func (n numberNode) names(func(string))                                  {}
+
+type nameNode string
+
+func (n nameNode) eval(lookup func(string) (float64, error)) (float64, error) {
+	v, err := lookup(string(n))
+	if errors.Is(err, ErrUnknownName) {
+		if c, ok := exprConstants[string(n)]; ok {
+			return c, nil
+		}
+	}
+	return v, err
+}
+
+func (n nameNode) names(add func(string)) {
+	add(string(n))
+}
+
+type unaryNode struct {
+	operand exprNode
+}
+
+func (n unaryNode) eval(lookup func(string) (float64, error)) (float64, error) {
+	v, err := n.operand.eval(lookup)
+	return -v, err
+}
+
+func (n unaryNode) names(add func(string)) {
+	n.operand.names(add)
+}
+
+type binaryNode struct {
+	op          byte
+	left, right exprNode
+}
+
+func (n binaryNode) eval(lookup func(string) (float64, error)) (float64, error) {
+	a, err := n.left.eval(lookup)
+	if err != nil {
+		return 0, err
+	}
+	b, err := n.right.eval(lookup)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return a + b, nil
+	case '-':
+		return a - b, nil
+	case '*':
+		return a * b, nil
+	case '/':
+		if b == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return a / b, nil
+	}
+	return math.Pow(a, b), nil
+}
+
+func (n binaryNode) names(add func(string)) {
+	n.left.names(add)
+	n.right.names(add)
+}
+
+type callNode struct {
+	name string
+	args []exprNode
+}
+
+func (n callNode) eval(lookup func(string) (float64, error)) (float64, error) {
+	args := make([]float64, len(n.args))
+	for k, arg := range n.args {
+		v, err := arg.eval(lookup)
+		if err != nil {
+			return 0, err
+		}
+		args[k] = v
+	}
+	return exprFuncs[n.name].fn(args), nil
+}
+
+func (n callNode) names(add func(string)) {
+	for _, arg := range n.args {
+		arg.names(add)
+	}
+}
+
+type tokenKind int
+
+const (
+	tokEnd tokenKind = iota
+	tokNumber
+	tokName
+	tokOp
+)
+
+type exprToken struct {
+	kind  tokenKind
+	text  string
+	pos   int
+	value float64
+}
+
+type exprParser struct {
+	text string
+	pos  int
+	tok  exprToken
+	err  error
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isNamePart(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// next reads the next token. A number runs on through any letters after
+// it, so its suffix ("4.7k", "10uF", "4k7") is parsed with it.
+func (p *exprParser) next() {
+	for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t') {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.text) {
+		p.tok = exprToken{kind: tokEnd, pos: start}
+		return
+	}
+
+	rest := p.text[p.pos:]
+	r := []rune(rest)[0]
+	switch {
+	case r >= '0' && r <= '9' || r == '.':
+		end := 0
+		for end < len(rest) {
+			c := rest[end]
+			switch {
+			case c >= '0' && c <= '9' || c == '.':
+				end++
+			case (c == 'e' || c == 'E') && end+1 < len(rest) && (isDigit(rest[end+1]) ||
+				(rest[end+1] == '+' || rest[end+1] == '-') && end+2 < len(rest) && isDigit(rest[end+2])):
+				end += 2
+			default:
+				rn := []rune(rest[end:])[0]
+				if !unicode.IsLetter(rn) && rn != 'Ω' && rn != '%' {
+					goto done
+				}
+				end += len(string(rn))
+			}
+		}
+	done:
+		text := rest[:end]
+		v, err := ParseValue(text)
+		if err != nil && p.err == nil {
+			p.err = err
+		}
+		p.pos += end
+		p.tok = exprToken{kind: tokNumber, text: text, pos: start, value: v}
+	case isNameStart(r):
+		end := 0
+		for _, rn := range rest {
+			if !isNamePart(rn) {
+				break
+			}
+			end += len(string(rn))
+		}
+		p.pos += end
+		p.tok = exprToken{kind: tokName, text: rest[:end], pos: start}
+	case strings.HasPrefix(rest, "**"):
+		p.pos += 2
+		p.tok = exprToken{kind: tokOp, text: "^", pos: start}
+	default:
+		p.pos += len(string(r))
+		p.tok = exprToken{kind: tokOp, text: string(r), pos: start}
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *exprParser) isOp(ops ...string) bool {
+	if p.tok.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+// expr parses a sum.
+func (p *exprParser) expr() (exprNode, error) {
+	left, err := p.term()
+	for err == nil && p.isOp("+", "-") {
+		op := p.tok.text[0]
+		p.next()
+		var right exprNode
+		right, err = p.term()
+		left = binaryNode{op, left, right}
+	}
+	return left, err
+}
+
+// term parses a product.
+func (p *exprParser) term() (exprNode, error) {
+	left, err := p.unary()
+	for err == nil && p.isOp("*", "/") {
+		op := p.tok.text[0]
+		p.next()
+		var right exprNode
+		right, err = p.unary()
+		left = binaryNode{op, left, right}
+	}
+	return left, err
+}
+
+// unary parses a signed power; -2^2 is -4.
+func (p *exprParser) unary() (exprNode, error) {
+	switch {
+	case p.isOp("-"):
+		p.next()
+		operand, err := p.unary()
+		return unaryNode{operand}, err
+	case p.isOp("+"):
+		p.next()
+		return p.unary()
+	}
+	return p.power()
+}
+
+// power parses a right-associative power.
+func (p *exprParser) power() (exprNode, error) {
+	base, err := p.primary()
+	if err != nil || !p.isOp("^") {
+		return base, err
+	}
+	p.next()
+	exponent, err := p.unary()
+	return binaryNode{'^', base, exponent}, err
+}
+
+func (p *exprParser) primary() (exprNode, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	tok := p.tok
+	switch {
+	case tok.kind == tokNumber:
+		p.next()
+		return numberNode(tok.value), p.err
+	case tok.kind == tokName:
+		p.next()
+		if !p.isOp("(") {
+			return nameNode(tok.text), nil
+		}
+		return p.call(tok)
+	case p.isOp("("):
+		p.next()
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, fmt.Errorf("missing ) at %d", p.tok.pos+1)
+		}
+		p.next()
+		return inner, nil
+	case tok.kind == tokEnd:
+		return nil, fmt.Errorf("unexpected end")
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos+1)
+}
+
+func (p *exprParser) call(name exprToken) (exprNode, error) {
+	f, ok := exprFuncs[strings.ToLower(name.text)]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", name.text)
+	}
+	p.next() // (
+	var args []exprNode
+	for !p.isOp(")") {
+		if len(args) > 0 {
+			if !p.isOp(",") {
+				return nil, fmt.Errorf("expected , or ) at %d", p.tok.pos+1)
+			}
+			p.next()
+		}
+		arg, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+	if f.arity >= 0 && len(args) != f.arity || f.arity < 0 && len(args) == 0 {
+		return nil, fmt.Errorf("%s takes %s", name.text, arityText(f.arity))
+	}
+	return callNode{strings.ToLower(name.text), args}, nil
+}
+
+func arityText(n int) string {
+	switch n {
+	case -1:
+		return "one or more arguments"
+	case 1:
+		return "one argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
@@ -0,0 +1,99 @@
+package circuit
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestExpr(t *testing.T) {
+	vars := map[string]float64{"R1": 1000, "VCC": 9, "X1.RB": 4700}
+	lookup := func(name string) (float64, error) {
+		if v, ok := vars[name]; ok {
+			return v, nil
+		}
+		return 0, fmt.Errorf("%w %s", ErrUnknownName, name)
+	}
+	tests := []struct {
+		text string
+		want float64
+	}{
+		{"2*R1", 2000},
+		{"VCC/2 + 0.5", 5},
+		{"4.7k + 10", 4710},
+		{"4k7", 4700},
+		{"10uF * 2", 20e-6},
+		{"1e3*2", 2000},
+		{"1.5e-3", 1.5e-3},
+		{"-2^2", -4},
+		{"2^3^2", 512},
+		{"2**3", 8},
+		{"(1 + 2) * 3", 9},
+		{"sqrt(16) + log10(100)", 6},
+		{"max(1, R1, 3) - min(4, 2)", 998},
+		{"round(2*pi)", 6},
+		{"1/(2*pi*sqrt(1m*1u))", 1 / (2 * math.Pi * math.Sqrt(1e-9))},
+		{"X1.RB / 1k", 4.7},
+		{"e", math.E},
+	}
+	for _, tt := range tests {
+		e, err := ParseExpr(tt.text)
+		if err != nil {
+			t.Errorf("ParseExpr(%q): %v", tt.text, err)
+			continue
+		}
+		got, err := e.Eval(lookup)
+		if err != nil || math.Abs(got-tt.want) > 1e-9*math.Abs(tt.want) {
+			t.Errorf("%s = %v, %v; want %v", tt.text, got, err, tt.want)
+		}
+	}
+
+	for text, want := range map[string]string{
+		"2*":        "unexpected end",
+		"(1 + 2":    "missing )",
+		"2 3":       "unexpected",
+		"foo(1)":    "unknown function",
+		"pow(2)":    "takes 2 arguments",
+		"4.7q":      "invalid value",
+		"1 $ 2":     "unexpected",
+		"sqrt(1,2)": "takes one argument",
+	} {
+		if _, err := ParseExpr(text); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseExpr(%q) = %v, want %q", text, err, want)
+		}
+	}
+
+	for text, want := range map[string]string{
+		"R2 + 1":    "unknown name R2",
+		"1/(R1-1k)": "division by zero",
+		"log(-1)":   "not a finite number",
+	} {
+		e, err := ParseExpr(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := e.Eval(lookup); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", text, err, want)
+		}
+	}
+}
+
+func TestExprRename(t *testing.T) {
+	e, err := ParseExpr("2*R1 + sqrt(RB) - 4k7/pi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(e.Names(), " "); got != "R1 RB pi" {
+		t.Errorf("names: %s", got)
+	}
+	got := e.Rename(func(name string) string {
+		if name == "pi" {
+			return name
+		}
+		return "X1." + name
+	})
+	if want := "2*X1.R1 + sqrt(X1.RB) - 4k7/pi"; got != want {
+		t.Errorf("renamed %q, want %q", got, want)
+	}
+}
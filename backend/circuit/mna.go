@@ -2,32 +2,27 @@ package circuit
 
 import (
 	// "fmt"
-	// "sort"
+	"sort"
 	"strconv"
 
 	"gonum.org/v1/gonum/mat"
 )
 
-var globalNodeMapping = make(map[string]string)
+// gmin is the tiny conductance from every node to ground, as in SPICE.
+const gmin = 1e-12
 
 func SolveCircuit(c *Circuit) (map[string]float64, error) {
-	// 1. Assign node numbers
-	nodeMap, nodeComponents := assignNodeNumbers(c)
-
-	// 2. Build MNA matrices
-	A, x, z := buildMNAMatrices(c, nodeMap, nodeComponents)
-
-	// 3. Solve the system
-	err := x.SolveVec(A, z)
+	sol, err := Solve(c)
 	if err != nil {
 		return nil, err
 	}
-
-	// 4. Extract results
-	return nil, nil
+	return sol.Results(), nil
 }
 
 func assignNodeNumbers(c *Circuit) (map[string]int, map[string][]string) {
+	if usesExplicitNodes(c) {
+		return assignExplicitNodes(c)
+	}
 
 	// Initialize node numbers
 	nodeNumbers := make(map[string]int)
@@ -89,11 +84,12 @@ func assignNodeNumbers(c *Circuit) (map[string]int, map[string][]string) {
 
 	newNodeNumbers := make(map[string]int)
 	newNodeNumbers["ground"] = 0
+	c.nodeOwners = make(map[string]string)
 
 	for nodeName, nodeIndex := range nodeNumbers {
 		if nodeIndex > 0 {
 			newNodeNumbers["v_"+strconv.Itoa(nodeIndex)] = nodeIndex
-            globalNodeMapping["v_" + strconv.Itoa(nodeIndex)] = nodeName 
+            c.nodeOwners["v_" + strconv.Itoa(nodeIndex)] = nodeName
 		}
 	}
 
@@ -119,6 +115,8 @@ func buildMNAMatrices(c *Circuit, nodeNumbers map[string]int, nodeComponents map
 		for j := 0; j < n-1; j++ {
 			A.Set(i, j, G.At(i, j))
 		}
+		// gmin keeps nodes reached only through open parts from floating
+		A.Set(i, i, A.At(i, i)+gmin)
 	}
 	// Build B matrix
 	B := buildBMatrix(c, nodeNumbers, nodeComponents)
@@ -139,6 +137,12 @@ func buildMNAMatrices(c *Circuit, nodeNumbers map[string]int, nodeComponents map
 			A.Set(n-1+i, j, C.At(i, j))
 		}
 	}
+
+	// Copy D matrix (source series resistances) into the bottom-right corner of A
+	D := buildDMatrix(c)
+	for i := 0; i < m; i++ {
+		A.Set(n-1+i, n-1+i, D.At(i, i))
+	}
     
 	return A, x, z
 }
@@ -150,57 +154,21 @@ func buildGMatrix(circuit *Circuit, nodeNumbers map[string]int, nodeComponents m
     // Initialize the G matrix with the correct size
     G := mat.NewDense(matrixSize-1, matrixSize-1, nil)
     
-    for nodeName, components := range nodeComponents {
-        if nodeName == "ground" {
+    // Stamp each resistor between its two terminals; parallel resistors add up
+    for _, comp := range circuit.Components {
+        if (comp.Type != Resistor && comp.Type != companion) || comp.Value == 0 {
             continue
         }
-        
-        nodeIndex := nodeNumbers[nodeName] - 1 // Adjust for 0-based indexing
-        
-        // Calculate total conductance for the node
-        totalConductance := 0.0
-        for _, compID := range components {
-            for _, comp := range circuit.Components {
-                if comp.ID == compID && comp.Type == Resistor {
-                    totalConductance += 1.0 / comp.Value
-                }
-            }
-        }
-        
-        if totalConductance > 0 {
-            G.Set(nodeIndex, nodeIndex, totalConductance)
-            // fmt.Println(nodeName, nodeIndex, totalConductance)
-        }
-    }
-    
-    // Build off-diagonal elements of G matrix
-    for nodeName1, components1 := range nodeComponents {
-        for nodeName2, components2 := range nodeComponents {
-            if nodeName1 == "ground" || nodeName2 == "ground" || nodeName1 == nodeName2 {
-                continue
-            }
-            
-            for _, compID := range components1 {
-                if contains(components2, compID) {
-                    comp := findComponentByID(circuit, compID)
-                    if comp.Type == Resistor {
-                        conductance := 1.0 / comp.Value * (-1.0)
-                        i := nodeNumbers[nodeName1] - 1
-                        j := nodeNumbers[nodeName2] - 1
-                        
-                        // Stamp the negative conductance at (i,j) and (j,i)
-                        G.Set(i, j, conductance)
-                        G.Set(j, i, conductance)
-                        // fmt.Println(i, j, conductance, comp.Value)
-                        // fmt.Println(i, j, conductance)
-                    }
-                }
-            }
+        positiveNode, negativeNode := componentTerminals(circuit, comp.ID, nodeComponents)
+        if positiveNode == "" || negativeNode == "" {
+            continue // a dangling lead carries no current
         }
+        stampResistor(G, nodeNumbers[positiveNode]-1, nodeNumbers[negativeNode]-1, 1.0/comp.Value)
     }
     
     return G
 }
+
 func buildBMatrix(c *Circuit, nodeNumbers map[string]int, nodeComponents map[string][]string) *mat.Dense {
     m := countVoltageSources(c)
     n := len(nodeNumbers)
@@ -208,23 +176,14 @@ func buildBMatrix(c *Circuit, nodeNumbers map[string]int, nodeComponents map[str
     
     voltIndex := 0
     for _, comp := range c.Components {
-        if comp.Type == Battery {
-            var positiveNode, negativeNode string
-            
-            // Determine positive and negative nodes
-            for nodeName, components := range nodeComponents {
-                if(nodeName != "ground" && globalNodeMapping[nodeName] == comp.ID) {
-                    positiveNode = nodeName
-                }else if contains(components, comp.ID) {
-                    negativeNode = nodeName
-                }
-            }
+        if isVoltageSource(c, comp) {
+            positiveNode, negativeNode := componentTerminals(c, comp.ID, nodeComponents)
 
             // Set values in B matrix
-            if positiveNode != "ground" {
+            if nodeNumbers[positiveNode] > 0 {
                 B.Set(nodeNumbers[positiveNode]-1, voltIndex, 1)
             }
-            if negativeNode != "ground" {
+            if nodeNumbers[negativeNode] > 0 {
                 B.Set(nodeNumbers[negativeNode]-1, voltIndex, -1)
             }
             
@@ -246,6 +205,16 @@ func buildCMatrix(c *Circuit, nodeNumbers map[string]int, nodeComponents map[str
 func buildDMatrix(c *Circuit) *mat.Dense {
     m := countVoltageSources(c)
     D := mat.NewDense(m, m, nil)
+    if m == 0 {
+        return D
+    }
+    voltIndex := 0
+    for _, comp := range c.Components {
+        if isVoltageSource(c, comp) {
+            D.Set(voltIndex, voltIndex, -seriesResistance(comp))
+            voltIndex++
+        }
+    }
     return D
 }
 
@@ -302,21 +271,17 @@ func buildzMatrix(c *Circuit, nodeNumbers map[string]int, nodeComponents map[str
 func buildiMatrix(c *Circuit, nodeNumbers map[string]int, nodeComponents map[string][]string) *mat.VecDense {
     n := len(nodeNumbers)
     i := mat.NewVecDense(n, nil)
-    // TODO: Implement i matrix (currents through voltage sources: none for now)
-    for nodeName, components := range nodeComponents {
-        if nodeName != "ground"{
-            comp := findComponentByID(c, globalNodeMapping[nodeName])
-            if comp.Type == CurrentSource {
-                currentValue := i.AtVec(nodeNumbers[nodeName]-1)
-                i.SetVec(nodeNumbers[nodeName]-1, currentValue + comp.Value)
-            }
-            for _, compID := range components {
-                compIn := findComponentByID(c, compID)
-                if compIn.Type == CurrentSource {
-                    currentValue := i.AtVec(nodeNumbers[nodeName]-1)
-                    i.SetVec(nodeNumbers[nodeName]-1, currentValue - compIn.Value)
-                }
-            }
+    // Current sources push their value out of the positive terminal
+    for _, comp := range c.Components {
+        if comp.Type != CurrentSource {
+            continue
+        }
+        positiveNode, negativeNode := componentTerminals(c, comp.ID, nodeComponents)
+        if k := nodeNumbers[positiveNode] - 1; k >= 0 {
+            i.SetVec(k, i.AtVec(k)+comp.Value)
+        }
+        if k := nodeNumbers[negativeNode] - 1; k >= 0 {
+            i.SetVec(k, i.AtVec(k)-comp.Value)
         }
     }
     return i
@@ -327,8 +292,8 @@ func buildeMatrix(c *Circuit) *mat.VecDense {
     e := mat.NewVecDense(m, nil)
     voltIndex := 0
     for _, comp := range c.Components {
-        if comp.Type == Battery {
-            e.SetVec(voltIndex, comp.Value)
+        if isVoltageSource(c, comp) {
+            e.SetVec(voltIndex, sourceVoltage(comp))
             voltIndex++
         }
     }
@@ -347,13 +312,72 @@ func getNodePair(compID string, connections []Connection, nodeMap map[string]int
 func countVoltageSources(c *Circuit) int {
     count := 0
 	for _, comp := range c.Components {
-        if comp.Type == Battery {
+        if isVoltageSource(c, comp) {
             count++
 		}
 	}
 	return count
 }
 
+// isVoltageSource reports whether comp is stamped as a voltage source row:
+// batteries, wires and inductors (as 0 V sources at DC) always, diodes and
+// LEDs only while conducting.
+func isVoltageSource(c *Circuit, comp Component) bool {
+	switch comp.Type {
+	case Battery, Wire, Inductor:
+		return true
+	case Diode, LED:
+		return c.conducting[comp.ID]
+	}
+	return false
+}
+
+// componentTerminals returns the positive and negative node of a component.
+// With explicit nodes these are the first two the component lists. Otherwise
+// the positive node is the one numbered after the component itself, and the
+// remaining node(s) containing it are taken in node-number order.
+func componentTerminals(c *Circuit, compID string, nodeComponents map[string][]string) (string, string) {
+	comp := findComponentByID(c, compID)
+	if usesExplicitNodes(c) {
+		var terminals [2]string
+		for k := 0; k < len(comp.Nodes) && k < 2; k++ {
+			terminals[k] = canonicalNode(comp.Nodes[k])
+		}
+		return terminals[0], terminals[1]
+	}
+
+	var positiveNode string
+	var others []string
+	for nodeName, components := range nodeComponents {
+		if nodeName != "ground" && c.nodeOwners[nodeName] == compID {
+			positiveNode = nodeName
+		} else if contains(components, compID) {
+			others = append(others, nodeName)
+		}
+	}
+	sort.Strings(others)
+	if positiveNode == "" && len(others) > 0 {
+		positiveNode, others = others[0], others[1:]
+	}
+	if len(others) == 0 {
+		return positiveNode, ""
+	}
+	return positiveNode, others[0]
+}
+
+func stampResistor(G *mat.Dense, n1, n2 int, conductance float64) {
+	if n1 >= 0 {
+		G.Set(n1, n1, G.At(n1, n1)+conductance)
+	}
+	if n2 >= 0 {
+		G.Set(n2, n2, G.At(n2, n2)+conductance)
+	}
+	if n1 >= 0 && n2 >= 0 {
+		G.Set(n1, n2, G.At(n1, n2)-conductance)
+		G.Set(n2, n1, G.At(n2, n1)-conductance)
+	}
+}
+
 func findComponentByID(c *Circuit, id string) Component {
     for _, comp := range c.Components {
         if comp.ID == id {
@@ -416,19 +440,6 @@ func contains(slice []string, item string) bool {
 //     return -1 // Error case
 // }
 
-// func stampResistor(G *mat.Dense, n1, n2 int, conductance float64) {
-//     if n1 >= 0 {
-//         G.Set(n1, n1, G.At(n1, n1)+conductance)
-//     }
-//     if n2 >= 0 {
-//         G.Set(n2, n2, G.At(n2, n2)+conductance)
-//     }
-//     if n1 >= 0 && n2 >= 0 {
-//         G.Set(n1, n2, G.At(n1, n2)-conductance)
-//         G.Set(n2, n1, G.At(n2, n1)-conductance)
-//     }
-// }
-
 // // Update the function signature to include numNodes
 // func stampVoltageSource(A *mat.Dense, z *mat.VecDense, n1, n2, voltIndex int, voltage float64, numNodes int) {
 //     if n1 > 0 {
@@ -0,0 +1,340 @@
+package circuit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Distribution is how a component's value spreads within its tolerance.
+type Distribution string
+
+const (
+	// Uniform spreads values evenly over the tolerance band.
+	Uniform Distribution = "uniform"
+	// Gaussian spreads values normally with the tolerance as three
+	// standard deviations, cut off at the tolerance as parts are binned.
+	Gaussian Distribution = "gaussian"
+)
+
+// maxMonteCarloRuns bounds the runs of one analysis.
+const maxMonteCarloRuns = 100000
+
+// maxHistogramBins bounds the bins of each net's histogram.
+const maxHistogramBins = 1000
+
+// Limit is a pass/fail test of a net's voltage. Either bound may be left
+// out.
+type Limit struct {
+	Net string   `json:"net"`
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+func (l Limit) pass(v float64) bool {
+	return (l.Min == nil || v >= *l.Min) && (l.Max == nil || v <= *l.Max)
+}
+
+// MonteCarloOptions configures MonteCarlo. Zero values pick defaults: 100
+// runs, a seed chosen at random, the gaussian distribution, every net and
+// 20 histogram bins. The same seed draws the same values.
+type MonteCarloOptions struct {
+	Runs         int          `json:"runs"`
+	Seed         int64        `json:"seed"`
+	Distribution Distribution `json:"distribution"`
+	Nets         []string     `json:"nets,omitempty"`
+	Limits       []Limit      `json:"limits,omitempty"`
+	Bins         int          `json:"bins"`
+}
+
+// Histogram counts values in bins of equal Width from Start.
+type Histogram struct {
+	Start  float64 `json:"start"`
+	Width  float64 `json:"width"`
+	Counts []int   `json:"counts"`
+}
+
+// NetStatistics summarizes a net's voltage over the runs that solved.
+type NetStatistics struct {
+	Nominal   float64   `json:"nominal"`
+	Mean      float64   `json:"mean"`
+	StdDev    float64   `json:"stdDev"`
+	Min       float64   `json:"min"`
+	Max       float64   `json:"max"`
+	Histogram Histogram `json:"histogram"`
+}
+
+// MonteCarloResult is the outcome of MonteCarlo. Varied lists the
+// components drawn from their tolerance. Failed counts the runs the solver
+// could not solve, which count as failing; Yield is the share of runs that
+// solved and met every limit.
+type MonteCarloResult struct {
+	Runs         int                      `json:"runs"`
+	Seed         int64                    `json:"seed"`
+	Distribution Distribution             `json:"distribution"`
+	Varied       []string                 `json:"varied"`
+	Nets         map[string]NetStatistics `json:"nets"`
+	Limits       []Limit                  `json:"limits,omitempty"`
+	Failed       int                      `json:"failed"`
+	Passed       int                      `json:"passed"`
+	Yield        float64                  `json:"yield"`
+}
+
+// MonteCarlo solves c's operating point opts.Runs times, each time with
+// the value of every component that has a "tolerance" property, in
+// percent, drawn at random within it, and summarizes the voltage of each
+// net. A component's "distribution" property overrides opts.Distribution
+// for it. Values written as expressions vary too, and so do those that
+// depend on them.
+func MonteCarlo(c *Circuit, opts MonteCarloOptions) (*MonteCarloResult, error) {
+	return MonteCarloContext(context.Background(), c, opts)
+}
+
+// MonteCarloContext is MonteCarlo stopping early with ctx's error once ctx
+// is done. The runs are solved in parallel; progress is reported to ctx as
+// the share of runs done.
+func MonteCarloContext(ctx context.Context, c *Circuit, opts MonteCarloOptions) (*MonteCarloResult, error) {
+	if opts.Runs == 0 {
+		opts.Runs = 100
+	}
+	if opts.Runs < 0 || opts.Runs > maxMonteCarloRuns {
+		return nil, fmt.Errorf("runs must be between 1 and %d", maxMonteCarloRuns)
+	}
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+	switch opts.Distribution {
+	case "":
+		opts.Distribution = Gaussian
+	case Uniform, Gaussian:
+	default:
+		return nil, fmt.Errorf("unknown distribution %q", opts.Distribution)
+	}
+	if opts.Bins == 0 {
+		opts.Bins = 20
+	}
+	if opts.Bins < 0 || opts.Bins > maxHistogramBins {
+		return nil, fmt.Errorf("bins must be between 1 and %d", maxHistogramBins)
+	}
+
+	c, err := Flatten(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSupported(c, AnalysisOP); err != nil {
+		return nil, err
+	}
+	nominal, err := Solve(c)
+	if err != nil {
+		return nil, fmt.Errorf("nominal: %w", err)
+	}
+	nets := opts.Nets
+	if len(nets) == 0 {
+		for net := range nominal.NodeVoltages {
+			if canonicalNode(net) != "ground" {
+				nets = append(nets, net)
+			}
+		}
+		sort.Strings(nets)
+	}
+	for _, net := range nets {
+		if _, ok := nominal.NodeVoltages[net]; !ok {
+			return nil, fmt.Errorf("net %s is not in the circuit", net)
+		}
+	}
+	for _, l := range opts.Limits {
+		if _, ok := nominal.NodeVoltages[l.Net]; !ok {
+			return nil, fmt.Errorf("limit on net %s, which is not in the circuit", l.Net)
+		}
+	}
+
+	varied, err := tolerances(c, opts.Distribution)
+	if err != nil {
+		return nil, err
+	}
+	// the draws are made up front, in order, so they depend on the seed
+	// alone and not on how the runs are scheduled
+	rng := rand.New(rand.NewSource(opts.Seed))
+	factors := make([][]float64, opts.Runs)
+	for k := range factors {
+		factors[k] = make([]float64, len(varied))
+		for j, v := range varied {
+			factors[k][j] = v.draw(rng)
+		}
+	}
+
+	type run struct {
+		voltages map[string]float64
+		err      error
+	}
+	next := make(chan int)
+	runs := make(chan run)
+	var wg sync.WaitGroup
+	for w := 0; w < min(runtime.GOMAXPROCS(0), opts.Runs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range next {
+				sol, err := Solve(sample(c, varied, factors[k]))
+				r := run{err: err}
+				if err == nil {
+					r.voltages = sol.NodeVoltages
+				}
+				runs <- r
+			}
+		}()
+	}
+	go func() {
+		defer close(next)
+		for k := 0; k < opts.Runs; k++ {
+			select {
+			case next <- k:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(runs)
+	}()
+
+	values := make(map[string][]float64, len(nets))
+	res := &MonteCarloResult{
+		Runs:         opts.Runs,
+		Seed:         opts.Seed,
+		Distribution: opts.Distribution,
+		Varied:       make([]string, len(varied)),
+		Nets:         make(map[string]NetStatistics, len(nets)),
+		Limits:       opts.Limits,
+	}
+	for j, v := range varied {
+		res.Varied[j] = c.Components[v.index].ID
+	}
+	done := 0
+	for r := range runs {
+		done++
+		reportProgress(ctx, float64(done)/float64(opts.Runs))
+		if r.err != nil {
+			res.Failed++
+			continue
+		}
+		for _, net := range nets {
+			values[net] = append(values[net], r.voltages[net])
+		}
+		pass := true
+		for _, l := range opts.Limits {
+			pass = pass && l.pass(r.voltages[l.Net])
+		}
+		if pass {
+			res.Passed++
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, net := range nets {
+		res.Nets[net] = statistics(values[net], nominal.NodeVoltages[net], opts.Bins)
+	}
+	res.Yield = float64(res.Passed) / float64(opts.Runs)
+	return res, nil
+}
+
+// toleranced is a component whose value is drawn from its tolerance.
+type toleranced struct {
+	index        int
+	tolerance    float64
+	distribution Distribution
+}
+
+// tolerances lists the components of c that have a tolerance.
+func tolerances(c *Circuit, def Distribution) ([]toleranced, error) {
+	var list []toleranced
+	for k, comp := range c.Components {
+		tol, ok := comp.Float("tolerance")
+		if !ok || tol == 0 {
+			continue
+		}
+		if tol < 0 || tol > 100 {
+			return nil, fmt.Errorf("%s: tolerance %g%% is not between 0 and 100", comp.ID, tol)
+		}
+		dist := def
+		if d := comp.Text("distribution"); d != "" {
+			dist = Distribution(d)
+		}
+		if dist != Uniform && dist != Gaussian {
+			return nil, fmt.Errorf("%s: unknown distribution %q", comp.ID, dist)
+		}
+		list = append(list, toleranced{index: k, tolerance: tol / 100, distribution: dist})
+	}
+	return list, nil
+}
+
+// draw returns the factor a value is scaled by.
+func (t toleranced) draw(rng *rand.Rand) float64 {
+	if t.distribution == Uniform {
+		return 1 + t.tolerance*(2*rng.Float64()-1)
+	}
+	for {
+		d := rng.NormFloat64() / 3
+		if math.Abs(d) <= 1 {
+			return 1 + t.tolerance*d
+		}
+	}
+}
+
+// sample returns a copy of c with the varied components' values scaled.
+func sample(c *Circuit, varied []toleranced, factors []float64) *Circuit {
+	work := *c
+	work.Components = append([]Component(nil), c.Components...)
+	for j, v := range varied {
+		comp := &work.Components[v.index]
+		if comp.Expression != "" {
+			comp.Expression = "(" + comp.Expression + ")*" + strconv.FormatFloat(factors[j], 'g', -1, 64)
+		} else {
+			comp.Value *= factors[j]
+		}
+	}
+	return &work
+}
+
+// statistics summarizes values, which may be none.
+func statistics(values []float64, nominal float64, bins int) NetStatistics {
+	s := NetStatistics{Nominal: nominal, Histogram: Histogram{Counts: make([]int, bins)}}
+	if len(values) == 0 {
+		return s
+	}
+	s.Min, s.Max = math.Inf(1), math.Inf(-1)
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+		s.Min = math.Min(s.Min, v)
+		s.Max = math.Max(s.Max, v)
+	}
+	s.Mean = sum / float64(len(values))
+	if len(values) > 1 {
+		sq := 0.0
+		for _, v := range values {
+			sq += (v - s.Mean) * (v - s.Mean)
+		}
+		s.StdDev = math.Sqrt(sq / float64(len(values)-1))
+	}
+
+	s.Histogram.Start = s.Min
+	s.Histogram.Width = (s.Max - s.Min) / float64(bins)
+	for _, v := range values {
+		bin := 0
+		if s.Histogram.Width > 0 {
+			bin = min(int((v-s.Min)/s.Histogram.Width), bins-1)
+		}
+		s.Histogram.Counts[bin]++
+	}
+	return s
+}
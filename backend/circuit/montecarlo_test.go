@@ -0,0 +1,102 @@
+package circuit
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMonteCarlo(t *testing.T) {
+	tol := map[string]interface{}{"tolerance": 5.0}
+	c := &Circuit{Components: []Component{
+		{ID: "B1", Type: Battery, Value: 10, Nodes: []string{"vcc", "0"}},
+		{ID: "R1", Type: Resistor, Value: 1000, Nodes: []string{"vcc", "out"}, Properties: tol},
+		{ID: "R2", Type: Resistor, Value: 1000, Nodes: []string{"out", "0"}, Properties: tol},
+	}}
+	lo, hi := 4.9, 5.1
+	opts := MonteCarloOptions{
+		Runs:         500,
+		Seed:         42,
+		Distribution: Uniform,
+		Limits:       []Limit{{Net: "out", Min: &lo, Max: &hi}},
+		Bins:         10,
+	}
+	res, err := MonteCarlo(c, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(res.Varied, " "); got != "R1 R2" {
+		t.Errorf("varied %s", got)
+	}
+	out := res.Nets["out"]
+	// out = 10 * R2/(R1+R2) stays within 10 * 0.95/(1.05+0.95)
+	if math.Abs(out.Nominal-5) > 1e-6 || out.Min < 4.75-1e-6 || out.Max > 5.25+1e-6 || math.Abs(out.Mean-5) > 0.02 {
+		t.Errorf("out statistics %+v", out)
+	}
+	if out.StdDev < 0.05 || out.StdDev > 0.15 {
+		t.Errorf("out standard deviation %v", out.StdDev)
+	}
+	total := 0
+	for _, n := range out.Histogram.Counts {
+		total += n
+	}
+	if total != 500 || len(out.Histogram.Counts) != 10 {
+		t.Errorf("histogram %+v", out.Histogram)
+	}
+	if res.Failed != 0 || res.Yield <= 0.3 || res.Yield >= 1 || res.Yield != float64(res.Passed)/500 {
+		t.Errorf("yield %v, passed %d, failed %d", res.Yield, res.Passed, res.Failed)
+	}
+	if _, ok := res.Nets["vcc"]; !ok {
+		t.Error("vcc missing from the statistics")
+	}
+
+	// the same seed draws the same values however the runs are scheduled
+	again, err := MonteCarlo(c, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, again) {
+		t.Error("the same seed gave different results")
+	}
+
+	// gaussian draws cluster more tightly about the nominal value
+	opts.Distribution = Gaussian
+	gauss, err := MonteCarlo(c, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sd := gauss.Nets["out"].StdDev; sd >= out.StdDev {
+		t.Errorf("gaussian standard deviation %v, uniform %v", sd, out.StdDev)
+	}
+}
+
+func TestMonteCarloExpressions(t *testing.T) {
+	// R2 follows R1, so the divider stays at half whatever R1 is drawn as
+	c := &Circuit{Components: []Component{
+		{ID: "B1", Type: Battery, Value: 10, Nodes: []string{"vcc", "0"}},
+		{ID: "R1", Type: Resistor, Value: 1000, Nodes: []string{"vcc", "out"}, Properties: map[string]interface{}{"tolerance": 10.0}},
+		{ID: "R2", Type: Resistor, Expression: "R1", Nodes: []string{"out", "0"}},
+	}}
+	res, err := MonteCarlo(c, MonteCarloOptions{Runs: 50, Seed: 1, Nets: []string{"out"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := res.Nets["out"]; out.StdDev > 1e-6 || math.Abs(out.Mean-5) > 1e-6 {
+		t.Errorf("out statistics %+v", out)
+	}
+	if len(res.Nets) != 1 || res.Yield != 1 {
+		t.Errorf("result %+v", res)
+	}
+
+	for name, opts := range map[string]MonteCarloOptions{
+		"not in the circuit":   {Nets: []string{"nowhere"}},
+		"unknown distribution": {Distribution: "bimodal"},
+		"runs must be":         {Runs: -1},
+		"bins must be":         {Bins: maxHistogramBins + 1},
+	} {
+		if _, err := MonteCarlo(c, opts); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package circuit
+
+import "strings"
+
+// usesExplicitNodes reports whether the circuit is wired by net name.
+func usesExplicitNodes(c *Circuit) bool {
+	for _, comp := range c.Components {
+		if len(comp.Nodes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalNode folds the usual spellings of the reference node onto "ground".
+func canonicalNode(name string) string {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "0", "gnd", "ground":
+		return "ground"
+	}
+	return strings.TrimSpace(name)
+}
+
+// assignExplicitNodes numbers the nets named by the components in order of
+// first appearance and lists the components touching each one.
+func assignExplicitNodes(c *Circuit) (map[string]int, map[string][]string) {
+	nodeNumbers := map[string]int{"ground": 0}
+	nodeComponents := map[string][]string{"ground": {}}
+	nextNode := 1
+
+	for _, comp := range c.Components {
+		for _, node := range comp.Nodes {
+			name := canonicalNode(node)
+			if name == "" {
+				continue
+			}
+			if _, exists := nodeNumbers[name]; !exists {
+				nodeNumbers[name] = nextNode
+				nextNode++
+			}
+			if !contains(nodeComponents[name], comp.ID) {
+				nodeComponents[name] = append(nodeComponents[name], comp.ID)
+			}
+		}
+	}
+	return nodeNumbers, nodeComponents
+}
+
+// ComponentNodes returns the nets each component connects to in terminal
+// order, positive first. The reference node is named "ground"; a terminal
+// left unconnected is "".
+func ComponentNodes(c *Circuit) map[string][]string {
+	nodes := make(map[string][]string)
+	if usesExplicitNodes(c) {
+		for _, comp := range c.Components {
+			for _, node := range comp.Nodes {
+				nodes[comp.ID] = append(nodes[comp.ID], canonicalNode(node))
+			}
+		}
+		return nodes
+	}
+
+	work := *c
+	c = &work
+	_, nodeComponents := assignNodeNumbers(c)
+	for _, comp := range c.Components {
+		pos, neg := componentTerminals(c, comp.ID, nodeComponents)
+		nodes[comp.ID] = []string{pos, neg}
+	}
+	return nodes
+}
+
+// InternalResistance returns a battery's series resistance, zero for an
+// ideal source.
+func InternalResistance(comp Component) float64 {
+	return internalResistance(comp)
+}
+
+// OpenCircuitVoltage returns a battery's EMF at its state of charge.
+func OpenCircuitVoltage(comp Component) float64 {
+	return openCircuitVoltage(comp)
+}
+
+// ForwardVoltage returns the forward voltage a diode or LED is modelled
+// with, and SeriesResistance the resistance in series with it.
+func ForwardVoltage(comp Component) float64 {
+	return forwardVoltage(comp)
+}
+
+func SeriesResistance(comp Component) float64 {
+	return seriesResistance(comp)
+}
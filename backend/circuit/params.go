@@ -0,0 +1,188 @@
+package circuit
+
+import (
+	"fmt"
+	"sort"
+)
+
+// WithParam returns a copy of c with parameter name set to v, leaving the
+// original's parameters untouched.
+func (c *Circuit) WithParam(name string, v float64) *Circuit {
+	params := make(map[string]interface{}, len(c.Params)+1)
+	for k, p := range c.Params {
+		params[k] = p
+	}
+	params[name] = v
+	work := *c
+	work.Params = params
+	return &work
+}
+
+// Evaluate returns c with the value of every component that has an
+// expression computed from it, or c itself when none has. The expressions
+// and parameters are kept, so a copy with other parameters can be
+// evaluated again. Subcircuit instances must be flattened first.
+func Evaluate(c *Circuit) (*Circuit, error) {
+	found := false
+	for _, comp := range c.Components {
+		if comp.Expression != "" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return c, nil
+	}
+
+	ev := newEvaluator(c)
+	work := *c
+	work.Components = append([]Component(nil), c.Components...)
+	for k, comp := range work.Components {
+		if comp.Expression == "" {
+			continue
+		}
+		v, err := ev.component(k)
+		if err != nil {
+			return nil, err
+		}
+		work.Components[k].Value = v
+	}
+	return &work, nil
+}
+
+// EvaluateParams returns the value of every parameter of c.
+func EvaluateParams(c *Circuit) (map[string]float64, error) {
+	ev := newEvaluator(c)
+	names := make([]string, 0, len(c.Params))
+	for name := range c.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make(map[string]float64, len(names))
+	for _, name := range names {
+		v, err := ev.param(name)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = v
+	}
+	return values, nil
+}
+
+// fixValues evaluates c and drops its expressions, for analyses that go on
+// to change values themselves.
+func fixValues(c *Circuit) (*Circuit, error) {
+	c, err := Evaluate(c)
+	if err != nil {
+		return nil, err
+	}
+	work := *c
+	work.Components = append([]Component(nil), c.Components...)
+	for k := range work.Components {
+		work.Components[k].Expression = ""
+	}
+	work.Params = nil
+	return &work, nil
+}
+
+// evaluator computes parameters and component values on demand, each
+// once, catching values that depend on themselves.
+type evaluator struct {
+	c      *Circuit
+	ids    map[string]int
+	params map[string]float64
+	comps  map[int]float64
+	busy   map[string]bool
+}
+
+func newEvaluator(c *Circuit) *evaluator {
+	ev := &evaluator{
+		c:      c,
+		ids:    make(map[string]int, len(c.Components)),
+		params: make(map[string]float64),
+		comps:  make(map[int]float64),
+		busy:   make(map[string]bool),
+	}
+	for k, comp := range c.Components {
+		ev.ids[comp.ID] = k
+	}
+	return ev
+}
+
+// lookup resolves a name in an expression: parameters first, then
+// component IDs.
+func (ev *evaluator) lookup(name string) (float64, error) {
+	if _, ok := ev.c.Params[name]; ok {
+		return ev.param(name)
+	}
+	if k, ok := ev.ids[name]; ok {
+		return ev.component(k)
+	}
+	return 0, fmt.Errorf("%w %s", ErrUnknownName, name)
+}
+
+func (ev *evaluator) param(name string) (float64, error) {
+	if v, ok := ev.params[name]; ok {
+		return v, nil
+	}
+	key := "param " + name
+	if ev.busy[key] {
+		return 0, fmt.Errorf("parameter %s depends on itself", name)
+	}
+	ev.busy[key] = true
+	defer delete(ev.busy, key)
+
+	var v float64
+	switch p := ev.c.Params[name].(type) {
+	case float64:
+		v = p
+	case int:
+		v = float64(p)
+	case string:
+		var err error
+		if v, err = ev.text(p); err != nil {
+			return 0, fmt.Errorf("parameter %s: %w", name, err)
+		}
+	default:
+		return 0, fmt.Errorf("parameter %s must be a number or an expression", name)
+	}
+	ev.params[name] = v
+	return v, nil
+}
+
+func (ev *evaluator) component(k int) (float64, error) {
+	comp := ev.c.Components[k]
+	if comp.Expression == "" {
+		return comp.Value, nil
+	}
+	if v, ok := ev.comps[k]; ok {
+		return v, nil
+	}
+	key := "component " + comp.ID
+	if ev.busy[key] {
+		return 0, fmt.Errorf("the value of %s depends on itself", comp.ID)
+	}
+	ev.busy[key] = true
+	defer delete(ev.busy, key)
+
+	v, err := ev.text(comp.Expression)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", comp.ID, err)
+	}
+	ev.comps[k] = v
+	return v, nil
+}
+
+// text evaluates an expression or, failing that, a value in engineering
+// notation such as "1.5 MΩ". Names come first, so "R1" is a component and
+// not the RKM code for 0.1Ω.
+func (ev *evaluator) text(s string) (float64, error) {
+	e, err := ParseExpr(s)
+	if err != nil {
+		if v, valueErr := ParseValue(s); valueErr == nil {
+			return v, nil
+		}
+		return 0, err
+	}
+	return e.Eval(ev.lookup)
+}
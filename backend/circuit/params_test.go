@@ -0,0 +1,120 @@
+package circuit
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	c := &Circuit{
+		Params: map[string]interface{}{"VCC": 10.0, "RB": "4.7k", "RTOP": "2*RB"},
+		Components: []Component{
+			{ID: "B1", Type: Battery, Expression: "VCC", Nodes: []string{"vcc", "0"}},
+			{ID: "R1", Type: Resistor, Expression: "RTOP", Nodes: []string{"vcc", "out"}},
+			{ID: "R2", Type: Resistor, Expression: "R1/2", Nodes: []string{"out", "0"}},
+		},
+	}
+	ev, err := Evaluate(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range []float64{10, 9400, 4700} {
+		if got := ev.Components[k].Value; got != want {
+			t.Errorf("%s = %v, want %v", ev.Components[k].ID, got, want)
+		}
+	}
+	if c.Components[0].Value != 0 {
+		t.Error("Evaluate changed the original circuit")
+	}
+
+	// one parameter moves every value that depends on it
+	sols, err := SweepDC(c, "VCC", []float64{3, 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, vcc := range []float64{3, 6} {
+		if v := sols[k].NodeVoltages["out"]; math.Abs(v-vcc/3) > 1e-6 {
+			t.Errorf("VCC = %v: out = %v, want %v", vcc, v, vcc/3)
+		}
+	}
+
+	params, err := EvaluateParams(c.WithParam("RB", 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params["RTOP"] != 2000 || c.Params["RB"] != "4.7k" {
+		t.Errorf("params = %v, original %v", params, c.Params)
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	for name, c := range map[string]*Circuit{
+		"parameter A depends on itself": {
+			Params:     map[string]interface{}{"A": "B+1", "B": "2*A"},
+			Components: []Component{{ID: "R1", Type: Resistor, Expression: "A"}},
+		},
+		"value of R1 depends on itself": {
+			Components: []Component{
+				{ID: "R1", Type: Resistor, Expression: "R2"},
+				{ID: "R2", Type: Resistor, Expression: "R1"},
+			},
+		},
+		"unknown name VDD": {
+			Components: []Component{{ID: "B1", Type: Battery, Expression: "VDD"}},
+		},
+		"must be a number or an expression": {
+			Params:     map[string]interface{}{"on": true},
+			Components: []Component{{ID: "R1", Type: Resistor, Expression: "on*1k"}},
+		},
+	} {
+		if _, err := Evaluate(c); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
+
+func TestSubcircuitParams(t *testing.T) {
+	// a divider whose ratio an instance sets
+	ratio := Subcircuit{
+		Name:   "ratio",
+		Ports:  []string{"in", "out"},
+		Params: map[string]interface{}{"R": "1k", "K": 1.0},
+		Components: []Component{
+			{ID: "RT", Type: Resistor, Expression: "K*R", Nodes: []string{"in", "out"}},
+			{ID: "RB", Type: Resistor, Expression: "R", Nodes: []string{"out", "0"}},
+		},
+	}
+	c := &Circuit{
+		Params: map[string]interface{}{"GAIN": 3.0},
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 8, Nodes: []string{"vcc", "0"}},
+			instance("X1", "ratio", "vcc", "a"),
+			instance("X2", "ratio", "vcc", "b"),
+		},
+		Subcircuits: []Subcircuit{ratio},
+	}
+	c.Components[2].Properties["K"] = "GAIN"
+
+	flat, err := Flatten(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := flat.Components[1].Expression; got != "X1.K*X1.R" {
+		t.Errorf("X1.RT expression = %q", got)
+	}
+	if flat.Params["X2.K"] != "GAIN" || flat.Params["X1.R"] != "1k" {
+		t.Errorf("flattened params = %v", flat.Params)
+	}
+
+	sol, err := Solve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := sol.NodeVoltages["a"]; math.Abs(v-4) > 1e-6 {
+		t.Errorf("a = %v, want 4", v)
+	}
+	if v := sol.NodeVoltages["b"]; math.Abs(v-2) > 1e-6 {
+		t.Errorf("b = %v, want 2", v)
+	}
+}
@@ -0,0 +1,105 @@
+package circuit
+
+import "math"
+
+// PowerBalanceTolerance is the relative mismatch between delivered and
+// dissipated power accepted as a balanced budget.
+const PowerBalanceTolerance = 1e-6
+
+// SourcePower accounts for one battery or current source. Delivered is
+// measured at the terminals; Generated adds the loss in the internal
+// resistance. A source being charged reports negative power.
+type SourcePower struct {
+	ComponentID  string        `json:"componentId"`
+	Type         ComponentType `json:"type"`
+	Generated    float64       `json:"generated"`
+	InternalLoss float64       `json:"internalLoss"`
+	Delivered    float64       `json:"delivered"`
+	Efficiency   float64       `json:"efficiency"`
+}
+
+// ComponentPower is the power a passive part dissipates and its share of
+// the total delivered to the loads.
+type ComponentPower struct {
+	ComponentID string        `json:"componentId"`
+	Type        ComponentType `json:"type"`
+	Dissipated  float64       `json:"dissipated"`
+	Share       float64       `json:"share"`
+}
+
+// PowerBudget is where the power in a solved circuit comes from and goes.
+// Efficiency is the fraction of generated power that reaches the loads.
+type PowerBudget struct {
+	Sources         []SourcePower    `json:"sources"`
+	Loads           []ComponentPower `json:"loads"`
+	TotalGenerated  float64          `json:"totalGenerated"`
+	InternalLosses  float64          `json:"internalLosses"`
+	TotalDelivered  float64          `json:"totalDelivered"`
+	TotalDissipated float64          `json:"totalDissipated"`
+	Efficiency      float64          `json:"efficiency"`
+	Imbalance       float64          `json:"imbalance"`
+	Balanced        bool             `json:"balanced"`
+}
+
+// dissipated is the power comp takes in sol, over both halves of a
+// potentiometer's track.
+func dissipated(comp Component, sol *Solution) float64 {
+	p := sol.Voltages[comp.ID] * sol.Currents[comp.ID]
+	if comp.Type == Potentiometer {
+		wiper := WiperID(comp.ID)
+		p += sol.Voltages[wiper] * sol.Currents[wiper]
+	}
+	return p
+}
+
+// BuildPowerBudget derives the power budget from a solution.
+func BuildPowerBudget(c *Circuit, sol *Solution) *PowerBudget {
+	budget := &PowerBudget{}
+
+	for _, comp := range c.Components {
+		v := sol.Voltages[comp.ID]
+		i := sol.Currents[comp.ID]
+
+		switch comp.Type {
+		case Battery, CurrentSource:
+			s := SourcePower{
+				ComponentID: comp.ID,
+				Type:        comp.Type,
+				Delivered:   -v * i,
+			}
+			if comp.Type == Battery {
+				s.InternalLoss = i * i * internalResistance(comp)
+			}
+			s.Generated = s.Delivered + s.InternalLoss
+			if s.Generated != 0 {
+				s.Efficiency = s.Delivered / s.Generated
+			}
+			budget.Sources = append(budget.Sources, s)
+			budget.TotalGenerated += s.Generated
+			budget.InternalLosses += s.InternalLoss
+			budget.TotalDelivered += s.Delivered
+		default:
+			p := dissipated(comp, sol)
+			budget.Loads = append(budget.Loads, ComponentPower{
+				ComponentID: comp.ID,
+				Type:        comp.Type,
+				Dissipated:  p,
+			})
+			budget.TotalDissipated += p
+		}
+	}
+
+	for k := range budget.Loads {
+		if budget.TotalDissipated != 0 {
+			budget.Loads[k].Share = budget.Loads[k].Dissipated / budget.TotalDissipated
+		}
+	}
+	if budget.TotalGenerated != 0 {
+		budget.Efficiency = budget.TotalDissipated / budget.TotalGenerated
+	}
+
+	budget.Imbalance = budget.TotalDelivered - budget.TotalDissipated
+	scale := math.Max(math.Abs(budget.TotalDelivered), math.Abs(budget.TotalDissipated))
+	budget.Balanced = math.Abs(budget.Imbalance) <= PowerBalanceTolerance*math.Max(scale, 1e-9)
+	return budget
+}
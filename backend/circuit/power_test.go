@@ -0,0 +1,69 @@
+package circuit
+
+import "testing"
+
+func TestBuildPowerBudget(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 9, Nodes: []string{"vcc", "0"},
+				Properties: map[string]interface{}{"internalResistance": 2.0}},
+			{ID: "R1", Type: Resistor, Value: 4, Nodes: []string{"vcc", "a"}},
+			{ID: "R2", Type: Resistor, Value: 12, Nodes: []string{"a", "0"}},
+			{ID: "I1", Type: CurrentSource, Value: 0.1, Nodes: []string{"0", "a"}},
+		},
+	}
+	sol, err := Solve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	budget := BuildPowerBudget(c, sol)
+
+	if !budget.Balanced {
+		t.Errorf("budget not balanced: imbalance %v", budget.Imbalance)
+	}
+	if len(budget.Sources) != 2 || len(budget.Loads) != 2 {
+		t.Fatalf("got %d sources and %d loads, want 2 and 2", len(budget.Sources), len(budget.Loads))
+	}
+
+	b1 := budget.Sources[0]
+	i := -sol.Currents["B1"]
+	if !isClose(b1.InternalLoss, i*i*2) || !isClose(b1.Generated, 9*i) {
+		t.Errorf("B1 = %+v, want loss %v and generated %v", b1, i*i*2, 9*i)
+	}
+	if !isClose(budget.TotalGenerated, budget.TotalDissipated+budget.InternalLosses) {
+		t.Errorf("generated %v != dissipated %v + losses %v",
+			budget.TotalGenerated, budget.TotalDissipated, budget.InternalLosses)
+	}
+	if share := budget.Loads[0].Share + budget.Loads[1].Share; !isClose(share, 1) {
+		t.Errorf("load shares sum to %v, want 1", share)
+	}
+}
+
+func TestPotentiometerPower(t *testing.T) {
+	// the whole track is across the supply, split a quarter of the way
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 10, Nodes: []string{"vcc", "0"}},
+			{ID: "P1", Type: Potentiometer, Value: 1000, Nodes: []string{"vcc", "0", "w"},
+				Properties: map[string]interface{}{"position": 0.25, "powerRating": 0.05}},
+		},
+	}
+	sol, err := Solve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	budget := BuildPowerBudget(c, sol)
+	if !budget.Balanced {
+		t.Errorf("budget not balanced: imbalance %v", budget.Imbalance)
+	}
+	i := sol.Currents["P1"]
+	if len(budget.Loads) != 1 || !isClose(budget.Loads[0].Dissipated, i*i*1000) {
+		t.Errorf("loads = %+v, want P1 dissipating %v", budget.Loads, i*i*1000)
+	}
+
+	// the rating holds for the whole track, not the half before the wiper
+	stress := AnalyzeStress(c, sol)
+	if len(stress) != 1 || stress[0].Level != StressDestroyed || !isClose(stress[0].Value, i*i*1000) {
+		t.Errorf("stress = %+v", stress)
+	}
+}
@@ -0,0 +1,17 @@
+package circuit
+
+import "context"
+
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx to which the long-running analyses
+// given it report how far they have got, as a fraction from 0 to 1.
+func WithProgress(ctx context.Context, report func(done float64)) context.Context {
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+func reportProgress(ctx context.Context, done float64) {
+	if report, ok := ctx.Value(progressKey{}).(func(float64)); ok {
+		report(done)
+	}
+}
@@ -0,0 +1,308 @@
+package circuit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Analysis names a kind of simulation.
+type Analysis string
+
+const (
+	// AnalysisOP is the DC operating point found by Solve.
+	AnalysisOP Analysis = "op"
+	// AnalysisDC sweeps a source with SweepDC.
+	AnalysisDC Analysis = "dc"
+	// AnalysisTransient steps the circuit through time with Transient.
+	AnalysisTransient Analysis = "tran"
+	// AnalysisDrain runs batteries down with SimulateDrain.
+	AnalysisDrain Analysis = "drain"
+)
+
+// Param describes one parameter of an element: its value or one of its
+// properties. Kind is how it is edited, one of "number", "select", "text",
+// "textarea" or "boolean"; Options lists the choices of a select. Min and
+// Max bound a number when set.
+type Param struct {
+	Name    string      `json:"name"`
+	Label   string      `json:"label"`
+	Kind    string      `json:"type"`
+	Unit    string      `json:"unit,omitempty"`
+	Default interface{} `json:"default,omitempty"`
+	Min     *float64    `json:"min,omitempty"`
+	Max     *float64    `json:"max,omitempty"`
+	Options []string    `json:"options,omitempty"`
+}
+
+// Element describes a component type: its terminals in the order of
+// Component.Nodes, its parameters, and the analyses that model it. Value
+// names the parameter held in Component.Value, if any; the others are
+// properties. An element with no analyses is carried through to exports,
+// such as SPICE netlists, but the solver does not model it.
+type Element struct {
+	Type     ComponentType `json:"type"`
+	Label    string        `json:"label"`
+	Pins     []string      `json:"pins"`
+	Value    string        `json:"value,omitempty"`
+	Params   []Param       `json:"params"`
+	Analyses []Analysis    `json:"analyses"`
+}
+
+// Param returns the element's parameter called name.
+func (e Element) Param(name string) (Param, bool) {
+	for _, p := range e.Params {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Param{}, false
+}
+
+// Supports reports whether the element is modelled by analysis a.
+func (e Element) Supports(a Analysis) bool {
+	for _, have := range e.Analyses {
+		if have == a {
+			return true
+		}
+	}
+	return false
+}
+
+// UnsupportedError is returned by an analysis for a circuit with parts it
+// does not model, listed by ID in Components.
+type UnsupportedError struct {
+	Analysis   Analysis
+	Components []string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("the %s analysis does not model %s", e.Analysis, strings.Join(e.Components, ", "))
+}
+
+// checkSupported returns an *UnsupportedError naming every component of c
+// whose element analysis a does not model. Types that are not registered
+// are left to the solver.
+func checkSupported(c *Circuit, a Analysis) error {
+	var e *UnsupportedError
+	for _, comp := range c.Components {
+		el, ok := Lookup(comp.Type)
+		if !ok || el.Supports(a) {
+			continue
+		}
+		if e == nil {
+			e = &UnsupportedError{Analysis: a}
+		}
+		e.Components = append(e.Components, comp.ID)
+	}
+	if e == nil {
+		return nil
+	}
+	return e
+}
+
+var registry = make(map[ComponentType]Element)
+
+// Register adds an element type to the registry. It is meant to be called
+// from init functions, and fails for a type already registered.
+func Register(e Element) error {
+	if e.Type == "" {
+		return fmt.Errorf("element has no type")
+	}
+	if _, dup := registry[e.Type]; dup {
+		return fmt.Errorf("element %q is already registered", e.Type)
+	}
+	if e.Value != "" {
+		if _, ok := e.Param(e.Value); !ok {
+			return fmt.Errorf("element %q has no parameter %q for its value", e.Type, e.Value)
+		}
+	}
+	if e.Params == nil {
+		e.Params = []Param{}
+	}
+	if e.Analyses == nil {
+		e.Analyses = []Analysis{}
+	}
+	registry[e.Type] = e
+	return nil
+}
+
+// Lookup returns the registered element of type t.
+func Lookup(t ComponentType) (Element, bool) {
+	e, ok := registry[t]
+	return e, ok
+}
+
+// Elements returns every registered element, ordered by type.
+func Elements() []Element {
+	list := make([]Element, 0, len(registry))
+	for _, e := range registry {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Type < list[j].Type })
+	return list
+}
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+// analog lists the analyses of the elements the solver models.
+var analog = []Analysis{AnalysisOP, AnalysisDC, AnalysisTransient, AnalysisDrain}
+
+func number(name, label, unit string, def interface{}, min, max *float64) Param {
+	return Param{Name: name, Label: label, Kind: "number", Unit: unit, Default: def, Min: min, Max: max}
+}
+
+func choice(name, label string, def string, options ...string) Param {
+	p := Param{Name: name, Label: label, Kind: "select", Options: options}
+	if def != "" {
+		p.Default = def
+	}
+	return p
+}
+
+var builtinElements = []Element{
+	{
+		Type: Resistor, Label: "Resistor", Pins: []string{"a", "b"}, Value: "resistance",
+		Params: []Param{
+			number("resistance", "Resistance", "Ω", 1000.0, bound(0), nil),
+			number("powerRating", "Power Rating", "W", 0.25, bound(0), nil),
+			number("tolerance", "Tolerance", "%", 5.0, bound(0), bound(100)),
+		},
+		Analyses: analog,
+	},
+	{
+		Type: Capacitor, Label: "Capacitor", Pins: []string{"+", "-"}, Value: "capacitance",
+		Params: []Param{
+			number("capacitance", "Capacitance", "F", 1e-6, bound(0), nil),
+			number("voltageRating", "Voltage Rating", "V", nil, bound(0), nil),
+			choice("capacitorType", "Type", "ceramic", "electrolytic", "ceramic", "film"),
+			number("tolerance", "Tolerance", "%", nil, bound(0), bound(100)),
+			number("initialVoltage", "Initial Voltage", "V", 0.0, nil, nil),
+		},
+		Analyses: analog,
+	},
+	{
+		Type: Inductor, Label: "Inductor", Pins: []string{"a", "b"}, Value: "inductance",
+		Params: []Param{
+			number("inductance", "Inductance", "H", 1e-3, bound(0), nil),
+			number("currentRating", "Current Rating", "A", nil, bound(0), nil),
+			number("tolerance", "Tolerance", "%", nil, bound(0), bound(100)),
+			number("initialCurrent", "Initial Current", "A", 0.0, nil, nil),
+		},
+		Analyses: analog,
+	},
+	{
+		Type: Diode, Label: "Diode", Pins: []string{"anode", "cathode"},
+		Params: []Param{
+			number("forwardVoltage", "Forward Voltage", "V", defaultDiodeForwardVoltage, bound(0), nil),
+			number("seriesResistance", "Series Resistance", "Ω", defaultDiodeResistance, bound(0), nil),
+			number("maxCurrent", "Max Current", "mA", nil, bound(0), nil),
+		},
+		Analyses: analog,
+	},
+	{
+		Type: LED, Label: "LED", Pins: []string{"anode", "cathode"},
+		Params: []Param{
+			number("forwardVoltage", "Forward Voltage", "V", defaultLEDForwardVoltage, bound(0), nil),
+			number("seriesResistance", "Series Resistance", "Ω", defaultLEDResistance, bound(0), nil),
+			number("maxCurrent", "Max Current", "mA", 20.0, bound(0), nil),
+			choice("color", "Color", "red", "red", "green", "blue", "yellow"),
+		},
+		Analyses: analog,
+	},
+	{
+		Type: Battery, Label: "Battery", Pins: []string{"+", "-"}, Value: "voltage",
+		Params: []Param{
+			number("voltage", "Voltage", "V", 9.0, nil, nil),
+			number("capacity", "Capacity", "mAh", DefaultBatteryCapacity, bound(0), nil),
+			choice("chemistry", "Chemistry", "", string(Alkaline), string(NiMH), string(LiIon)),
+			number("internalResistance", "Internal Resistance", "Ω", nil, bound(0), nil),
+			number("cutoffVoltage", "Cutoff Voltage", "V", nil, bound(0), nil),
+			number("stateOfCharge", "State of Charge", "", 1.0, bound(0), bound(1)),
+			number("maxCurrent", "Max Current", "A", DangerousCurrent, bound(0), nil),
+		},
+		Analyses: analog,
+	},
+	{
+		Type: CurrentSource, Label: "Current Source", Pins: []string{"out", "in"}, Value: "current",
+		Params: []Param{
+			number("current", "Current", "A", 1e-3, nil, nil),
+		},
+		Analyses: analog,
+	},
+	{
+		Type: Wire, Label: "Wire", Pins: []string{"a", "b"},
+		Analyses: analog,
+	},
+	{
+		Type: Switch, Label: "Switch", Pins: []string{"a", "b"},
+		Params: []Param{
+			{Name: "closed", Label: "Closed", Kind: "boolean", Default: false},
+		},
+		Analyses: analog,
+	},
+	{
+		Type: Potentiometer, Label: "Potentiometer", Pins: []string{"a", "b", "wiper"}, Value: "resistance",
+		Params: []Param{
+			number("resistance", "Resistance", "Ω", 10e3, bound(0), nil),
+			number("position", "Wiper Position", "", 0.5, bound(0), bound(1)),
+		},
+		Analyses: analog,
+	},
+	{
+		// its pins are the ports of the subcircuit it instantiates
+		Type: SubcircuitInstance, Label: "Subcircuit",
+		Params: []Param{
+			{Name: SubcircuitProperty, Label: "Subcircuit", Kind: "text"},
+		},
+		Analyses: analog,
+	},
+	{
+		Type: Transistor, Label: "Transistor", Pins: []string{"collector", "base", "emitter"},
+		Params: []Param{
+			choice("transistorType", "Type", "npn", "npn", "pnp"),
+			number("gain", "Gain (hFE)", "", 100.0, bound(0), nil),
+			number("maxCollectorCurrent", "Max Collector Current", "A", nil, bound(0), nil),
+		},
+	},
+	{
+		Type: Mosfet, Label: "MOSFET", Pins: []string{"drain", "gate", "source"},
+		Params: []Param{
+			choice("mosfetType", "Type", "nmos", "nmos", "pmos"),
+			number("w", "Channel Width", "m", nil, bound(0), nil),
+			number("l", "Channel Length", "m", nil, bound(0), nil),
+		},
+	},
+	{
+		Type: VCVS, Label: "Voltage-Controlled Voltage Source", Pins: []string{"+", "-", "control+", "control-"}, Value: "gain",
+		Params: []Param{number("gain", "Gain", "V/V", 1.0, nil, nil)},
+	},
+	{
+		Type: VCCS, Label: "Voltage-Controlled Current Source", Pins: []string{"+", "-", "control+", "control-"}, Value: "gain",
+		Params: []Param{number("gain", "Transconductance", "S", 1.0, nil, nil)},
+	},
+	{
+		Type: CCCS, Label: "Current-Controlled Current Source", Pins: []string{"+", "-"}, Value: "gain",
+		Params: []Param{
+			number("gain", "Gain", "A/A", 1.0, nil, nil),
+			{Name: "control", Label: "Controlling Source", Kind: "text"},
+		},
+	},
+	{
+		Type: CCVS, Label: "Current-Controlled Voltage Source", Pins: []string{"+", "-"}, Value: "gain",
+		Params: []Param{
+			number("gain", "Transresistance", "Ω", 1.0, nil, nil),
+			{Name: "control", Label: "Controlling Source", Kind: "text"},
+		},
+	},
+}
+
+func init() {
+	for _, e := range builtinElements {
+		if err := Register(e); err != nil {
+			panic(err)
+		}
+	}
+}
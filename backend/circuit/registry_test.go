@@ -0,0 +1,71 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	for _, typ := range []ComponentType{Battery, Resistor, CurrentSource, Capacitor, Diode, LED, Wire, Inductor, Switch, Potentiometer} {
+		e, ok := Lookup(typ)
+		if !ok {
+			t.Errorf("%s is not registered", typ)
+			continue
+		}
+		if !e.Supports(AnalysisOP) || !e.Supports(AnalysisTransient) {
+			t.Errorf("%s analyses = %v", typ, e.Analyses)
+		}
+		if len(e.Pins) < 2 {
+			t.Errorf("%s pins = %v", typ, e.Pins)
+		}
+	}
+
+	r, _ := Lookup(Resistor)
+	if p, ok := r.Param(r.Value); !ok || p.Unit != "Ω" || p.Min == nil || *p.Min != 0 {
+		t.Errorf("resistor value parameter = %+v", p)
+	}
+	led, _ := Lookup(LED)
+	if p, _ := led.Param("forwardVoltage"); p.Default != defaultLEDForwardVoltage {
+		t.Errorf("LED forward voltage default = %v", p.Default)
+	}
+	if q, _ := Lookup(Transistor); q.Supports(AnalysisOP) {
+		t.Error("the solver does not model transistors")
+	}
+
+	if err := Register(Element{Type: Resistor}); err == nil {
+		t.Error("registered the resistor twice")
+	}
+	if err := Register(Element{Type: "thermistor", Value: "resistance"}); err == nil {
+		t.Error("registered an element whose value is not a parameter")
+	}
+	if n := len(Elements()); n != len(builtinElements) {
+		t.Errorf("%d elements, want %d", n, len(builtinElements))
+	}
+}
+
+func TestUnsupportedElements(t *testing.T) {
+	c := &Circuit{Components: []Component{
+		{ID: "B1", Type: Battery, Value: 5, Nodes: []string{"in", "0"}},
+		{ID: "E1", Type: VCVS, Value: 2, Nodes: []string{"out", "0", "in", "0"}},
+		{ID: "R1", Type: Resistor, Value: 1000, Nodes: []string{"out", "0"}},
+		{ID: "Q1", Type: Transistor, Nodes: []string{"out", "in", "0"}},
+	}}
+	analyses := map[Analysis]func() error{
+		AnalysisOP: func() error { _, err := Solve(c); return err },
+		AnalysisDC: func() error { _, err := SweepDC(c, "B1", []float64{1, 2}); return err },
+		AnalysisTransient: func() error {
+			_, _, _, err := SimulateTransient(context.Background(), c, 1e-3, 1e-2)
+			return err
+		},
+		AnalysisDrain: func() error { _, err := SimulateDrain(c, DrainOptions{}); return err },
+		"montecarlo":  func() error { _, err := MonteCarlo(c, MonteCarloOptions{}); return err },
+	}
+	for name, run := range analyses {
+		var unsupported *UnsupportedError
+		if err := run(); !errors.As(err, &unsupported) || strings.Join(unsupported.Components, " ") != "E1 Q1" {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
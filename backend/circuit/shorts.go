@@ -0,0 +1,191 @@
+package circuit
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ShortResistance is the resistance below which a part is treated as a
+// direct connection when looking for shorts across a source.
+const ShortResistance = 0.1
+
+// DefaultInternalResistance is assumed when estimating how much current a
+// short would really draw from a battery that sets neither an
+// internalResistance property nor a chemistry.
+const DefaultInternalResistance = 0.5
+
+// DangerousCurrent is the source current flagged when a battery sets no
+// maxCurrent of its own.
+const DangerousCurrent = 1.0
+
+// ShortCircuit describes a near-zero-resistance path across a source.
+// Path lists the parts from the positive to the negative terminal, and
+// Wires the connections joining them when the circuit is wired by
+// Connections rather than net names. Unbounded is set, and
+// EstimatedCurrent left zero, when nothing in the loop has resistance: an
+// ideal source shorted by wires.
+type ShortCircuit struct {
+	SourceID           string       `json:"sourceId"`
+	Path               []string     `json:"path"`
+	Wires              []Connection `json:"wires,omitempty"`
+	PathResistance     float64      `json:"pathResistance"`
+	InternalResistance float64      `json:"internalResistance"`
+	EstimatedCurrent   float64      `json:"estimatedCurrent"`
+	Unbounded          bool         `json:"unbounded,omitempty"`
+	Message            string       `json:"message"`
+}
+
+// ShortCircuitError is returned by Solve when a source is shorted.
+type ShortCircuitError struct {
+	Shorts []ShortCircuit
+}
+
+func (e *ShortCircuitError) Error() string {
+	msgs := make([]string, len(e.Shorts))
+	for k, s := range e.Shorts {
+		msgs[k] = s.Message
+	}
+	return "short circuit: " + strings.Join(msgs, "; ")
+}
+
+type shortEdge struct {
+	compID     string
+	node       string
+	resistance float64
+}
+
+// DetectShorts finds every battery whose terminals are joined by wires or
+// resistors below ShortResistance and estimates the resulting current
+// from the battery's internal resistance.
+func DetectShorts(c *Circuit) []ShortCircuit {
+	work := *c
+	c = &work
+	_, nodeComponents := assignNodeNumbers(c)
+
+	graph := make(map[string][]shortEdge)
+	for _, comp := range c.Components {
+		r, ok := directResistance(comp)
+		if !ok {
+			continue
+		}
+		pos, neg := componentTerminals(c, comp.ID, nodeComponents)
+		if pos == "" || neg == "" {
+			continue
+		}
+		graph[pos] = append(graph[pos], shortEdge{comp.ID, neg, r})
+		graph[neg] = append(graph[neg], shortEdge{comp.ID, pos, r})
+	}
+
+	var shorts []ShortCircuit
+	for _, comp := range c.Components {
+		if comp.Type != Battery {
+			continue
+		}
+		pos, neg := componentTerminals(c, comp.ID, nodeComponents)
+		if pos == "" || neg == "" {
+			continue
+		}
+		path, ok := shortPath(graph, pos, neg)
+		if !ok {
+			continue
+		}
+
+		s := ShortCircuit{
+			SourceID:           comp.ID,
+			InternalResistance: internalResistance(comp),
+		}
+		// an explicit zero models an ideal source on purpose
+		if _, set := comp.Properties["internalResistance"]; !set && s.InternalResistance == 0 {
+			s.InternalResistance = DefaultInternalResistance
+		}
+		for _, edge := range path {
+			s.Path = append(s.Path, edge.compID)
+			s.PathResistance += edge.resistance
+		}
+		if !usesExplicitNodes(c) {
+			s.Wires = pathWires(c, comp.ID, s.Path)
+		}
+		if total := s.PathResistance + s.InternalResistance; total > 0 {
+			s.EstimatedCurrent = math.Abs(sourceVoltage(comp)) / total
+			s.Message = fmt.Sprintf("%s is shorted through %s, drawing about %s",
+				comp.ID, describePath(s.Path), FormatValue(s.EstimatedCurrent, "A"))
+		} else {
+			s.Unbounded = true
+			s.Message = fmt.Sprintf("%s is shorted through %s, with its current limited only by the wiring",
+				comp.ID, describePath(s.Path))
+		}
+		shorts = append(shorts, s)
+	}
+	return shorts
+}
+
+// directResistance reports the resistance of parts that count as a direct
+// connection.
+func directResistance(comp Component) (float64, bool) {
+	switch comp.Type {
+	case Wire, Inductor:
+		return 0, true
+	case Resistor:
+		if math.Abs(comp.Value) < ShortResistance {
+			return math.Abs(comp.Value), true
+		}
+	}
+	return 0, false
+}
+
+// shortPath searches breadth first for the path with fewest parts from
+// one node to another.
+func shortPath(graph map[string][]shortEdge, from, to string) ([]shortEdge, bool) {
+	if from == to {
+		return nil, true
+	}
+	type step struct {
+		prev string
+		edge shortEdge
+	}
+	visited := map[string]step{from: {}}
+	queue := []string{from}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, edge := range graph[node] {
+			if _, seen := visited[edge.node]; seen {
+				continue
+			}
+			visited[edge.node] = step{node, edge}
+			if edge.node == to {
+				var path []shortEdge
+				for n := to; n != from; n = visited[n].prev {
+					path = append([]shortEdge{visited[n].edge}, path...)
+				}
+				return path, true
+			}
+			queue = append(queue, edge.node)
+		}
+	}
+	return nil, false
+}
+
+// pathWires returns the connections that join the source and the parts on
+// its short path.
+func pathWires(c *Circuit, sourceID string, path []string) []Connection {
+	onPath := map[string]bool{sourceID: true, "ground": true}
+	for _, id := range path {
+		onPath[id] = true
+	}
+	var wires []Connection
+	for _, conn := range c.Connections {
+		if onPath[conn.From] && onPath[conn.To] {
+			wires = append(wires, conn)
+		}
+	}
+	return wires
+}
+
+func describePath(path []string) string {
+	if len(path) == 0 {
+		return "a direct connection"
+	}
+	return strings.Join(path, " -> ")
+}
@@ -0,0 +1,121 @@
+package circuit
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectShortThroughWires(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 9, Nodes: []string{"vcc", "0"},
+				Properties: map[string]interface{}{"internalResistance": 1.5}},
+			{ID: "R1", Type: Resistor, Value: 1000, Nodes: []string{"vcc", "0"}},
+			{ID: "W1", Type: Wire, Nodes: []string{"vcc", "a"}},
+			{ID: "R2", Type: Resistor, Value: 0.05, Nodes: []string{"a", "b"}},
+			{ID: "W2", Type: Wire, Nodes: []string{"b", "gnd"}},
+		},
+	}
+	shorts := DetectShorts(c)
+	if len(shorts) != 1 {
+		t.Fatalf("got %d shorts, want 1", len(shorts))
+	}
+	s := shorts[0]
+	if !reflect.DeepEqual(s.Path, []string{"W1", "R2", "W2"}) {
+		t.Errorf("path = %v, want [W1 R2 W2]", s.Path)
+	}
+	if !isClose(s.EstimatedCurrent, 9/1.55) {
+		t.Errorf("estimated current = %v, want %v", s.EstimatedCurrent, 9/1.55)
+	}
+
+	_, err := Solve(c)
+	var short *ShortCircuitError
+	if !errors.As(err, &short) {
+		t.Errorf("Solve error = %v, want *ShortCircuitError", err)
+	}
+}
+
+func TestDetectShortInternalResistance(t *testing.T) {
+	for _, tt := range []struct {
+		props map[string]interface{}
+		want  float64
+	}{
+		{nil, DefaultInternalResistance},
+		{map[string]interface{}{"internalResistance": 0.0}, 0},
+		{map[string]interface{}{"internalResistance": 2.0}, 2},
+	} {
+		c := &Circuit{Components: []Component{
+			{ID: "B1", Type: Battery, Value: 9, Nodes: []string{"vcc", "0"}, Properties: tt.props},
+			{ID: "R1", Type: Resistor, Value: 0.05, Nodes: []string{"vcc", "0"}},
+		}}
+		shorts := DetectShorts(c)
+		if len(shorts) != 1 {
+			t.Fatalf("%v: got %d shorts, want 1", tt.props, len(shorts))
+		}
+		if r := shorts[0].InternalResistance; r != tt.want {
+			t.Errorf("%v: internal resistance = %v, want %v", tt.props, r, tt.want)
+		}
+		if want := 9 / (0.05 + tt.want); !isClose(shorts[0].EstimatedCurrent, want) {
+			t.Errorf("%v: estimated current = %v, want %v", tt.props, shorts[0].EstimatedCurrent, want)
+		}
+	}
+}
+
+func TestDetectShortIdealSource(t *testing.T) {
+	// nothing in the loop limits the current
+	c := &Circuit{Components: []Component{
+		{ID: "B1", Type: Battery, Value: 9, Nodes: []string{"vcc", "0"}, Properties: map[string]interface{}{"internalResistance": 0.0}},
+		{ID: "W1", Type: Wire, Nodes: []string{"vcc", "0"}},
+	}}
+	shorts := DetectShorts(c)
+	if len(shorts) != 1 {
+		t.Fatalf("got %d shorts, want 1", len(shorts))
+	}
+	s := shorts[0]
+	if !s.Unbounded || s.EstimatedCurrent != 0 || !strings.Contains(s.Message, "limited only by the wiring") {
+		t.Errorf("short = %+v", s)
+	}
+}
+
+func TestDetectShortIgnoresLoadedSource(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 9, Nodes: []string{"vcc", "0"}},
+			{ID: "W1", Type: Wire, Nodes: []string{"vcc", "a"}},
+			{ID: "R1", Type: Resistor, Value: 330, Nodes: []string{"a", "0"}},
+		},
+	}
+	if shorts := DetectShorts(c); len(shorts) != 0 {
+		t.Fatalf("got shorts %+v, want none", shorts)
+	}
+	sol, err := Solve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isClose(sol.Currents["W1"], 9.0/330) {
+		t.Errorf("wire current = %v, want %v", sol.Currents["W1"], 9.0/330)
+	}
+}
+
+func TestDetectShortLegacyConnections(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "V1", Type: Battery, Value: 5},
+			{ID: "R1", Type: Resistor, Value: 0},
+		},
+		Connections: []Connection{
+			{From: "ground", To: "V1"},
+			{From: "V1", To: "R1"},
+			{From: "R1", To: "ground"},
+		},
+	}
+	shorts := DetectShorts(c)
+	if len(shorts) != 1 {
+		t.Fatalf("got %d shorts, want 1", len(shorts))
+	}
+	if len(shorts[0].Wires) != 3 {
+		t.Errorf("wires = %v, want all 3 connections", shorts[0].Wires)
+	}
+}
@@ -0,0 +1,209 @@
+package circuit
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// maxDiodeIterations bounds the search for a consistent set of diode states.
+const maxDiodeIterations = 50
+
+// Default piecewise-linear diode parameters, used when the part leaves
+// forwardVoltage or seriesResistance unset.
+const (
+	defaultDiodeForwardVoltage = 0.7
+	defaultLEDForwardVoltage   = 2.0
+	defaultDiodeResistance     = 1.0
+	defaultLEDResistance       = 10.0
+)
+
+// Solution is the DC operating point of a circuit. Component voltages are
+// measured from the positive to the negative terminal and component currents
+// flow through the part in the same direction, so a discharging battery
+// reports a negative current.
+type Solution struct {
+	NodeVoltages map[string]float64   `json:"nodeVoltages"`
+	Voltages     map[string]float64   `json:"voltages"`
+	Currents     map[string]float64   `json:"currents"`
+	Terminals    map[string][2]string `json:"terminals"`
+}
+
+// Solve finds the operating point of c. Diodes and LEDs are modelled as a
+// forward-voltage source with series resistance while conducting and as an
+// open circuit otherwise; their states are iterated until consistent.
+// Switches and potentiometers are solved as the wires and resistors they
+// currently behave as, subcircuit instances as their flattened components
+// and expressions as their values. A shorted source is reported as a
+// *ShortCircuitError instead of solving, and parts the solver does not
+// model as an *UnsupportedError.
+func Solve(c *Circuit) (*Solution, error) {
+	c, err := Flatten(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSupported(c, AnalysisOP); err != nil {
+		return nil, err
+	}
+	if c, err = Evaluate(c); err != nil {
+		return nil, err
+	}
+	c = lowerControls(c)
+	if shorts := DetectShorts(c); len(shorts) > 0 {
+		return nil, &ShortCircuitError{Shorts: shorts}
+	}
+
+	work := *c
+	work.conducting = make(map[string]bool)
+
+	nodeMap, nodeComponents := assignNodeNumbers(&work)
+
+	for iter := 0; iter < maxDiodeIterations; iter++ {
+		if len(nodeMap)-1+countVoltageSources(&work) == 0 {
+			return extractSolution(&work, nodeMap, nodeComponents, nil), nil
+		}
+
+		A, _, z := buildMNAMatrices(&work, nodeMap, nodeComponents)
+
+		var x mat.VecDense
+		if err := x.SolveVec(A, z); err != nil {
+			return nil, err
+		}
+
+		sol := extractSolution(&work, nodeMap, nodeComponents, &x)
+		if !updateDiodeStates(&work, sol) {
+			return sol, nil
+		}
+	}
+	return nil, fmt.Errorf("diode states did not settle after %d iterations", maxDiodeIterations)
+}
+
+func extractSolution(c *Circuit, nodeNumbers map[string]int, nodeComponents map[string][]string, x *mat.VecDense) *Solution {
+	sol := &Solution{
+		NodeVoltages: make(map[string]float64),
+		Voltages:     make(map[string]float64),
+		Currents:     make(map[string]float64),
+		Terminals:    make(map[string][2]string),
+	}
+
+	n := len(nodeNumbers)
+	for nodeName, index := range nodeNumbers {
+		if index > 0 && x != nil {
+			sol.NodeVoltages[nodeName] = x.AtVec(index - 1)
+		} else {
+			sol.NodeVoltages[nodeName] = 0
+		}
+	}
+
+	voltIndex := 0
+	for _, comp := range c.Components {
+		pos, neg := componentTerminals(c, comp.ID, nodeComponents)
+		v := sol.NodeVoltages[pos] - sol.NodeVoltages[neg]
+		sol.Terminals[comp.ID] = [2]string{pos, neg}
+		sol.Voltages[comp.ID] = v
+
+		switch {
+		case isVoltageSource(c, comp):
+			if x != nil {
+				sol.Currents[comp.ID] = x.AtVec(n - 1 + voltIndex)
+			}
+			voltIndex++
+		case (comp.Type == Resistor || comp.Type == companion) && comp.Value != 0:
+			sol.Currents[comp.ID] = v / comp.Value
+		case comp.Type == CurrentSource:
+			sol.Currents[comp.ID] = -comp.Value
+		default:
+			sol.Currents[comp.ID] = 0
+		}
+	}
+	return sol
+}
+
+// updateDiodeStates flips diodes whose state contradicts sol and reports
+// whether anything changed.
+func updateDiodeStates(c *Circuit, sol *Solution) bool {
+	changed := false
+	for _, comp := range c.Components {
+		if comp.Type != Diode && comp.Type != LED {
+			continue
+		}
+		if c.conducting[comp.ID] {
+			if sol.Currents[comp.ID] < 0 {
+				c.conducting[comp.ID] = false
+				changed = true
+			}
+		} else if sol.Voltages[comp.ID] > forwardVoltage(comp) {
+			c.conducting[comp.ID] = true
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Results flattens the solution into the map returned by SolveCircuit:
+// node voltages keyed by node name and component currents keyed "I_<id>".
+func (s *Solution) Results() map[string]float64 {
+	results := make(map[string]float64)
+	for nodeName, v := range s.NodeVoltages {
+		if nodeName != "ground" {
+			results[nodeName] = v
+		}
+	}
+	for id, i := range s.Currents {
+		results["I_"+id] = i
+	}
+	return results
+}
+
+// FormattedResults is Results rendered in engineering notation with units,
+// e.g. "4.5V" for a node and "20.5mA" for a current.
+func (s *Solution) FormattedResults() map[string]string {
+	formatted := make(map[string]string)
+	for nodeName, v := range s.NodeVoltages {
+		if nodeName != "ground" {
+			formatted[nodeName] = FormatValue(v, "V")
+		}
+	}
+	for id, i := range s.Currents {
+		formatted["I_"+id] = FormatValue(i, "A")
+	}
+	return formatted
+}
+
+func sourceVoltage(comp Component) float64 {
+	switch comp.Type {
+	case Diode, LED:
+		return forwardVoltage(comp)
+	case Wire, Inductor:
+		return 0
+	case Battery:
+		return openCircuitVoltage(comp)
+	}
+	return comp.Value
+}
+
+func seriesResistance(comp Component) float64 {
+	switch comp.Type {
+	case Diode, LED:
+		if r, ok := comp.Float("seriesResistance"); ok && r > 0 {
+			return r
+		}
+		if comp.Type == LED {
+			return defaultLEDResistance
+		}
+		return defaultDiodeResistance
+	case Battery:
+		return internalResistance(comp)
+	}
+	return 0
+}
+
+func forwardVoltage(comp Component) float64 {
+	if vf, ok := comp.Float("forwardVoltage"); ok && vf > 0 {
+		return vf
+	}
+	if comp.Type == LED {
+		return defaultLEDForwardVoltage
+	}
+	return defaultDiodeForwardVoltage
+}
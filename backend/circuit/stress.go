@@ -0,0 +1,175 @@
+package circuit
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+type StressLevel string
+
+const (
+	StressOK        StressLevel = "ok"
+	StressWarning   StressLevel = "warning"
+	StressDestroyed StressLevel = "destroyed"
+)
+
+// StressWarnRatio is the fraction of a rating above which a part is flagged
+// as running hot. Exceeding the rating itself destroys the part.
+const StressWarnRatio = 0.8
+
+// ElectrolyticReverseLimit is the reverse voltage an electrolytic capacitor
+// survives; any reverse bias up to it is still reported as a warning.
+const ElectrolyticReverseLimit = 1.0
+
+// StressFinding describes one part loaded near or beyond its rating.
+// FirstExceeded is only set by a StressMonitor, to the simulation time at
+// which the rating was first exceeded.
+type StressFinding struct {
+	ComponentID   string        `json:"componentId"`
+	Type          ComponentType `json:"type"`
+	Level         StressLevel   `json:"level"`
+	Condition     string        `json:"condition"`
+	Quantity      string        `json:"quantity"`
+	Value         float64       `json:"value"`
+	Limit         float64       `json:"limit"`
+	Message       string        `json:"message"`
+	FirstExceeded *float64      `json:"firstExceeded,omitempty"`
+}
+
+// AnalyzeStress compares the solved operating point against each part's
+// ratings and returns a finding for every part at warning level or worse.
+// Parts without a rating property are not checked, except batteries, whose
+// current is held to DangerousCurrent unless they set maxCurrent.
+func AnalyzeStress(c *Circuit, sol *Solution) []StressFinding {
+	var findings []StressFinding
+	for _, comp := range c.Components {
+		for _, f := range checkComponent(comp, sol) {
+			if f.Level != StressOK {
+				findings = append(findings, f)
+			}
+		}
+	}
+	return findings
+}
+
+func checkComponent(comp Component, sol *Solution) []StressFinding {
+	v := sol.Voltages[comp.ID]
+	i := sol.Currents[comp.ID]
+
+	switch comp.Type {
+	case Battery:
+		limit := DangerousCurrent
+		if rating, ok := comp.Float("maxCurrent"); ok && rating > 0 {
+			limit = rating
+		}
+		return []StressFinding{rate(comp, "dangerous-current", "current", math.Abs(i), limit, "A")}
+	case Resistor, Potentiometer:
+		if rating, ok := comp.Float("powerRating"); ok && rating > 0 {
+			return []StressFinding{rate(comp, "burnt", "power", math.Abs(dissipated(comp, sol)), rating, "W")}
+		}
+	case LED, Diode:
+		// maxCurrent is entered in mA in the property panel
+		if rating, ok := comp.Float("maxCurrent"); ok && rating > 0 {
+			return []StressFinding{rate(comp, "blown", "current", math.Abs(i), rating/1000, "A")}
+		}
+	case Capacitor:
+		var findings []StressFinding
+		if rating, ok := comp.Float("voltageRating"); ok && rating > 0 {
+			findings = append(findings, rate(comp, "over-voltage", "voltage", math.Abs(v), rating, "V"))
+		}
+		if comp.Text("capacitorType") == "electrolytic" && v < 0 {
+			f := rate(comp, "reverse-biased", "reverse voltage", -v, ElectrolyticReverseLimit, "V")
+			if f.Level == StressOK {
+				f.Level = StressWarning
+				f.Message = fmt.Sprintf("%s is reverse biased by %s", comp.ID, FormatValue(-v, "V"))
+			}
+			findings = append(findings, f)
+		}
+		return findings
+	}
+	return nil
+}
+
+func rate(comp Component, condition, quantity string, value, limit float64, unit string) StressFinding {
+	f := StressFinding{
+		ComponentID: comp.ID,
+		Type:        comp.Type,
+		Level:       StressOK,
+		Condition:   condition,
+		Quantity:    quantity,
+		Value:       value,
+		Limit:       limit,
+	}
+	switch {
+	case value > limit:
+		f.Level = StressDestroyed
+		f.Message = fmt.Sprintf("%s %s: %s %s exceeds rating of %s", comp.ID, condition, quantity, FormatValue(value, unit), FormatValue(limit, unit))
+	case value > StressWarnRatio*limit:
+		f.Level = StressWarning
+		f.Message = fmt.Sprintf("%s %s at %.0f%% of its %s rating", comp.ID, quantity, 100*value/limit, FormatValue(limit, unit))
+	}
+	return f
+}
+
+// StressMonitor accumulates stress findings over the steps of a
+// time-domain simulation, keeping the worst level and peak value seen for
+// each part and the time its rating was first exceeded.
+type StressMonitor struct {
+	circuit  *Circuit
+	findings map[string]*StressFinding
+}
+
+func NewStressMonitor(c *Circuit) *StressMonitor {
+	return &StressMonitor{
+		circuit:  c,
+		findings: make(map[string]*StressFinding),
+	}
+}
+
+// Observe checks the solution at simulation time t.
+func (m *StressMonitor) Observe(t float64, sol *Solution) {
+	for _, f := range AnalyzeStress(m.circuit, sol) {
+		key := f.ComponentID + "/" + f.Condition
+		prev, seen := m.findings[key]
+		if !seen {
+			prev = &StressFinding{}
+			*prev = f
+			m.findings[key] = prev
+		}
+		if f.Level == StressDestroyed && prev.FirstExceeded == nil {
+			at := t
+			prev.FirstExceeded = &at
+		}
+		if f.Value > prev.Value || (f.Level == StressDestroyed && prev.Level != StressDestroyed) {
+			first := prev.FirstExceeded
+			*prev = f
+			prev.FirstExceeded = first
+		}
+	}
+}
+
+// Findings returns the accumulated findings ordered by component ID.
+func (m *StressMonitor) Findings() []StressFinding {
+	findings := make([]StressFinding, 0, len(m.findings))
+	for _, f := range m.findings {
+		findings = append(findings, *f)
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].ComponentID != findings[j].ComponentID {
+			return findings[i].ComponentID < findings[j].ComponentID
+		}
+		return findings[i].Condition < findings[j].Condition
+	})
+	return findings
+}
+
+// Destroyed reports whether any observed part has been destroyed.
+func (m *StressMonitor) Destroyed() bool {
+	for _, f := range m.findings {
+		if f.Level == StressDestroyed {
+			return true
+		}
+	}
+	return false
+}
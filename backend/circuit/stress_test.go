@@ -0,0 +1,115 @@
+package circuit
+
+import "testing"
+
+func TestSolveLEDWithResistor(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 9, Nodes: []string{"vcc", "0"}},
+			{ID: "R1", Type: Resistor, Value: 350, Nodes: []string{"vcc", "a"}},
+			{ID: "D1", Type: LED, Nodes: []string{"a", "0"}},
+		},
+	}
+	sol, err := Solve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// (9 - 2) / (350 + 10) through the LED
+	if want := 7.0 / 360; !isClose(sol.Currents["D1"], want) {
+		t.Errorf("LED current = %v, want %v", sol.Currents["D1"], want)
+	}
+	if !isClose(sol.Currents["B1"], -7.0/360) {
+		t.Errorf("battery current = %v, want %v", sol.Currents["B1"], -7.0/360)
+	}
+}
+
+func TestSolveReverseLEDBlocks(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 9, Nodes: []string{"vcc", "0"}},
+			{ID: "R1", Type: Resistor, Value: 330, Nodes: []string{"vcc", "a"}},
+			{ID: "D1", Type: LED, Nodes: []string{"0", "a"}},
+		},
+	}
+	sol, err := Solve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isClose(sol.Currents["D1"], 0) {
+		t.Errorf("reverse LED current = %v, want 0", sol.Currents["D1"])
+	}
+}
+
+func TestAnalyzeStress(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 9, Nodes: []string{"vcc", "0"}},
+			{ID: "R1", Type: Resistor, Value: 100, Nodes: []string{"vcc", "0"},
+				Properties: map[string]interface{}{"powerRating": 0.25}},
+			{ID: "R2", Type: Resistor, Value: 400, Nodes: []string{"vcc", "0"},
+				Properties: map[string]interface{}{"powerRating": 0.25}},
+			{ID: "D1", Type: LED, Nodes: []string{"vcc", "0"},
+				Properties: map[string]interface{}{"maxCurrent": 20.0}},
+			{ID: "C1", Type: Capacitor, Nodes: []string{"0", "vcc"},
+				Properties: map[string]interface{}{"voltageRating": 16.0, "capacitorType": "Electrolytic"}},
+			{ID: "C2", Type: Capacitor, Nodes: []string{"vcc", "0"},
+				Properties: map[string]interface{}{"voltageRating": 6.3}},
+		},
+	}
+	sol, err := Solve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]StressFinding)
+	for _, f := range AnalyzeStress(c, sol) {
+		got[f.ComponentID+"/"+f.Condition] = f
+	}
+
+	want := map[string]StressLevel{
+		"R1/burnt":          StressDestroyed, // 0.81 W
+		"R2/burnt":          StressWarning,   // 0.2025 W
+		"D1/blown":          StressDestroyed, // 700 mA
+		"C1/reverse-biased": StressDestroyed,
+		"C2/over-voltage":   StressDestroyed,
+	}
+	for key, level := range want {
+		if got[key].Level != level {
+			t.Errorf("%s level = %q, want %q", key, got[key].Level, level)
+		}
+	}
+	if _, ok := got["C1/over-voltage"]; ok {
+		t.Errorf("C1 within its voltage rating was reported")
+	}
+}
+
+func TestStressMonitorFirstExceeded(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 1, Nodes: []string{"vcc", "0"}},
+			{ID: "R1", Type: Resistor, Value: 100, Nodes: []string{"vcc", "0"},
+				Properties: map[string]interface{}{"powerRating": 0.25}},
+		},
+	}
+	m := NewStressMonitor(c)
+	for step, v := range []float64{1, 4, 6, 9, 5} {
+		c.Components[0].Value = v
+		sol, err := Solve(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Observe(float64(step)*0.5, sol)
+	}
+
+	findings := m.Findings()
+	if len(findings) != 1 {
+		t.Fatalf("got %d findings, want 1", len(findings))
+	}
+	f := findings[0]
+	if f.Level != StressDestroyed || f.FirstExceeded == nil || *f.FirstExceeded != 1.0 {
+		t.Errorf("finding = %+v, want destroyed first at t=1", f)
+	}
+	if !isClose(f.Value, 0.81) {
+		t.Errorf("peak power = %v, want 0.81", f.Value)
+	}
+}
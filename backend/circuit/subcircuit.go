@@ -0,0 +1,264 @@
+package circuit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SubcircuitProperty is the property of a subcircuit instance naming the
+// subcircuit it instantiates.
+const SubcircuitProperty = "subcircuit"
+
+// maxSubcircuitDepth bounds how deeply subcircuits may nest.
+const maxSubcircuitDepth = 32
+
+// Subcircuit is a reusable block, such as a debounced button or an LED
+// driver stage. Its components are wired by net name; the nets listed in
+// Ports are joined to the nets an instance's Nodes name, in order, and the
+// others are private to each instance. Ground is shared with the circuit.
+// Subcircuits may define their own subcircuits, which are visible only
+// inside them, and may instantiate any subcircuit visible where they are
+// defined.
+//
+// Params are the subcircuit's parameters and their defaults, which its
+// component expressions may use; an instance sets its own with properties
+// of the same names, written in terms of the parameters where it is.
+type Subcircuit struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Ports       []string               `json:"ports"`
+	Components  []Component            `json:"components"`
+	Subcircuits []Subcircuit           `json:"subcircuits,omitempty"`
+	Params      map[string]interface{} `json:"params,omitempty"`
+}
+
+// Validate checks a subcircuit on its own: that it is named, its ports are
+// distinct nets other than ground, and its components are wired by net
+// name.
+func (s Subcircuit) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("subcircuit has no name")
+	}
+	seen := make(map[string]bool)
+	for _, port := range s.Ports {
+		name := canonicalNode(port)
+		switch {
+		case name == "":
+			return fmt.Errorf("subcircuit %s has an unnamed port", s.Name)
+		case name == "ground":
+			return fmt.Errorf("subcircuit %s: ground cannot be a port", s.Name)
+		case seen[name]:
+			return fmt.Errorf("subcircuit %s has port %s twice", s.Name, port)
+		}
+		seen[name] = true
+	}
+	ids := make(map[string]bool)
+	for _, comp := range s.Components {
+		if ids[comp.ID] {
+			return fmt.Errorf("subcircuit %s has two components %s", s.Name, comp.ID)
+		}
+		ids[comp.ID] = true
+		if len(comp.Nodes) == 0 {
+			return fmt.Errorf("subcircuit %s: %s has no nodes", s.Name, comp.ID)
+		}
+	}
+	for _, inner := range s.Subcircuits {
+		if err := inner.Validate(); err != nil {
+			return fmt.Errorf("in %s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// subcircuitScope resolves subcircuit names, innermost definitions first.
+type subcircuitScope struct {
+	defs   map[string]*Subcircuit
+	parent *subcircuitScope
+}
+
+func newSubcircuitScope(defs []Subcircuit, parent *subcircuitScope) *subcircuitScope {
+	sc := &subcircuitScope{defs: make(map[string]*Subcircuit), parent: parent}
+	for k := range defs {
+		sc.defs[defs[k].Name] = &defs[k]
+	}
+	return sc
+}
+
+func (sc *subcircuitScope) lookup(name string) (*Subcircuit, *subcircuitScope) {
+	for ; sc != nil; sc = sc.parent {
+		if def, ok := sc.defs[name]; ok {
+			return def, sc
+		}
+	}
+	return nil, nil
+}
+
+// Flatten returns c with every subcircuit instance replaced by the
+// components of its subcircuit, or c itself when it has no instances. The
+// components and private nets of an instance are named after it: component
+// R1 of instance X1 becomes "X1.R1" and its net "mid" becomes "X1.mid", so
+// results read hierarchically. Instances must be wired by net name.
+//
+// The expressions inside an instance are renamed the same way, and each of
+// its parameters becomes a parameter of the circuit, such as "X1.RB", so
+// Evaluate computes the values later.
+func Flatten(c *Circuit) (*Circuit, error) {
+	found := false
+	for _, comp := range c.Components {
+		if comp.Type == SubcircuitInstance {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return c, nil
+	}
+
+	f := &flattener{params: make(map[string]interface{})}
+	if err := f.expand(c.Components, newSubcircuitScope(c.Subcircuits, nil), "", nil, nil, nil); err != nil {
+		return nil, err
+	}
+	work := *c
+	work.Components = f.components
+	work.Subcircuits = nil
+	if len(f.params) > 0 {
+		for name, v := range c.Params {
+			f.params[name] = v
+		}
+		work.Params = f.params
+	}
+	return &work, nil
+}
+
+type flattener struct {
+	components []Component
+	params     map[string]interface{}
+}
+
+// renamer returns the renaming of names in expressions inside an instance
+// named prefix: its parameters and components are prefixed like its
+// components' IDs, and other names, the circuit's parameters, are kept.
+func renamer(prefix string, comps []Component, params map[string]interface{}) func(string) string {
+	if prefix == "" {
+		return func(name string) string { return name }
+	}
+	local := make(map[string]bool, len(comps)+len(params))
+	for _, comp := range comps {
+		local[comp.ID] = true
+	}
+	for name := range params {
+		local[name] = true
+	}
+	return func(name string) string {
+		if local[name] {
+			return prefix + name
+		}
+		return name
+	}
+}
+
+// renameValue renames the names in a value written as an expression.
+// Numbers and other values are kept as they are.
+func renameValue(v interface{}, rename func(string) string) (interface{}, error) {
+	text, ok := v.(string)
+	if !ok {
+		return v, nil
+	}
+	e, err := ParseExpr(text)
+	if err != nil {
+		if _, valueErr := ParseValue(text); valueErr == nil {
+			return text, nil
+		}
+		return nil, err
+	}
+	return e.Rename(rename), nil
+}
+
+// expand adds comps, instantiated under prefix. ports maps the port names
+// of the enclosing subcircuit to the nets they are joined to and params
+// holds its parameters; active lists the subcircuits being expanded, to
+// catch one instantiating itself.
+func (f *flattener) expand(comps []Component, scope *subcircuitScope, prefix string, ports map[string]string, params map[string]interface{}, active []string) error {
+	rename := renamer(prefix, comps, params)
+	net := func(node string) string {
+		name := canonicalNode(node)
+		if name == "ground" {
+			return node
+		}
+		if mapped, ok := ports[name]; ok {
+			return mapped
+		}
+		return prefix + node
+	}
+
+	for _, comp := range comps {
+		if comp.Type != SubcircuitInstance {
+			if prefix != "" {
+				comp.ID = prefix + comp.ID
+				nodes := make([]string, len(comp.Nodes))
+				for k, node := range comp.Nodes {
+					nodes[k] = net(node)
+				}
+				comp.Nodes = nodes
+				if control, ok := comp.Properties["control"].(string); ok && control != "" {
+					comp = comp.WithProperty("control", prefix+control)
+				}
+				if comp.Expression != "" {
+					expr, err := renameValue(comp.Expression, rename)
+					if err != nil {
+						return fmt.Errorf("%s: %w", comp.ID, err)
+					}
+					comp.Expression = expr.(string)
+				}
+			}
+			f.components = append(f.components, comp)
+			continue
+		}
+
+		id := prefix + comp.ID
+		name, _ := comp.Properties[SubcircuitProperty].(string)
+		def, defScope := scope.lookup(name)
+		switch {
+		case name == "":
+			return fmt.Errorf("subcircuit instance %s does not name a subcircuit", id)
+		case def == nil:
+			return fmt.Errorf("%s: unknown subcircuit %q", id, name)
+		case len(comp.Nodes) != len(def.Ports):
+			return fmt.Errorf("%s: subcircuit %s has %d ports, got %d nodes", id, name, len(def.Ports), len(comp.Nodes))
+		case len(active) >= maxSubcircuitDepth:
+			return fmt.Errorf("%s: subcircuits nested too deeply", id)
+		}
+		for _, outer := range active {
+			if outer == def.Name {
+				return fmt.Errorf("%s: subcircuit %s instantiates itself", id, name)
+			}
+		}
+		if err := def.Validate(); err != nil {
+			return err
+		}
+
+		inner := make(map[string]string, len(def.Ports))
+		for k, port := range def.Ports {
+			inner[canonicalNode(port)] = net(comp.Nodes[k])
+		}
+		// an instance's parameters are written where it is, their defaults
+		// inside the subcircuit
+		innerRename := renamer(id+".", def.Components, def.Params)
+		for name, value := range def.Params {
+			v, err := renameValue(value, innerRename)
+			if set, ok := comp.Properties[name]; ok {
+				v, err = renameValue(set, rename)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: parameter %s: %w", id, name, err)
+			}
+			f.params[id+"."+name] = v
+		}
+		// a subcircuit sees its own definitions and those where it is defined
+		err := f.expand(def.Components, newSubcircuitScope(def.Subcircuits, defScope), id+".", inner, def.Params, append(active, def.Name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package circuit
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// divider halves the voltage between in and ground at out.
+var divider = Subcircuit{
+	Name:  "divider",
+	Ports: []string{"in", "out"},
+	Components: []Component{
+		{ID: "R1", Type: Resistor, Value: 1000, Nodes: []string{"in", "out"}},
+		{ID: "R2", Type: Resistor, Value: 1000, Nodes: []string{"out", "0"}},
+	},
+}
+
+func instance(id, name string, nodes ...string) Component {
+	return Component{ID: id, Type: SubcircuitInstance, Nodes: nodes, Properties: map[string]interface{}{SubcircuitProperty: name}}
+}
+
+func TestFlatten(t *testing.T) {
+	// two dividers in cascade, the second built from a nested definition
+	quarter := Subcircuit{
+		Name:        "quarter",
+		Ports:       []string{"a", "b"},
+		Subcircuits: []Subcircuit{{Name: "buffer", Ports: []string{"x", "y"}, Components: []Component{{ID: "W", Type: Wire, Nodes: []string{"x", "y"}}}}},
+		Components: []Component{
+			instance("X1", "divider", "a", "mid"),
+			instance("X2", "buffer", "mid", "b"),
+		},
+	}
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 8, Nodes: []string{"vcc", "gnd"}},
+			instance("XA", "divider", "vcc", "half"),
+			instance("XB", "quarter", "half", "out"),
+		},
+		Subcircuits: []Subcircuit{divider, quarter},
+	}
+
+	flat, err := Flatten(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, comp := range flat.Components {
+		ids = append(ids, comp.ID)
+	}
+	if got := strings.Join(ids, " "); got != "B1 XA.R1 XA.R2 XB.X1.R1 XB.X1.R2 XB.X2.W" {
+		t.Errorf("flattened components: %s", got)
+	}
+	if len(c.Components) != 3 {
+		t.Error("Flatten changed the original circuit")
+	}
+
+	sol, err := Solve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second divider loads the first: half = 8 * (1k||2k) / (1k + 1k||2k)
+	half := 8 * (2000.0 / 3) / (1000 + 2000.0/3)
+	if v := sol.NodeVoltages["half"]; math.Abs(v-half) > 1e-6 {
+		t.Errorf("half = %v, want %v", v, half)
+	}
+	if v := sol.NodeVoltages["XB.mid"]; math.Abs(v-half/2) > 1e-6 {
+		t.Errorf("XB.mid = %v, want %v", v, half/2)
+	}
+	if i := sol.Currents["XB.X1.R2"]; math.Abs(i-half/2/1000) > 1e-9 {
+		t.Errorf("XB.X1.R2 current = %v", i)
+	}
+}
+
+func TestFlattenErrors(t *testing.T) {
+	loop := Subcircuit{Name: "loop", Ports: []string{"a"}, Components: []Component{instance("X", "loop", "a")}}
+	for name, comps := range map[string][]Component{
+		"unknown subcircuit":  {instance("X1", "nope", "a", "b")},
+		"has 2 ports, got 1":  {instance("X1", "divider", "a")},
+		"instantiates itself": {instance("X1", "loop", "a")},
+		"does not name":       {{ID: "X1", Type: SubcircuitInstance, Nodes: []string{"a"}}},
+	} {
+		c := &Circuit{Components: comps, Subcircuits: []Subcircuit{divider, loop}}
+		if _, err := Flatten(c); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+
+	bad := Subcircuit{Name: "bad", Ports: []string{"a", "0"}}
+	if err := bad.Validate(); err == nil {
+		t.Error("ground accepted as a port")
+	}
+}
@@ -0,0 +1,105 @@
+package circuit
+
+import (
+	"context"
+	"fmt"
+)
+
+// SweepDC solves c once for each value of the battery or current source
+// sourceID, as SPICE's .dc analysis does. A swept battery keeps its
+// internal resistance but its chemistry no longer sets its voltage, nor
+// does its expression. sourceID may instead name a parameter of c, which
+// sweeps every value that depends on it.
+func SweepDC(c *Circuit, sourceID string, values []float64) ([]*Solution, error) {
+	return SweepDCContext(context.Background(), c, sourceID, values)
+}
+
+// SweepDCContext is SweepDC stopping early with ctx's error once ctx is
+// done. It reports its progress to ctx as the share of points solved.
+func SweepDCContext(ctx context.Context, c *Circuit, sourceID string, values []float64) ([]*Solution, error) {
+	if _, ok := c.Params[sourceID]; ok {
+		return sweepParam(ctx, c, sourceID, values)
+	}
+	c, err := Flatten(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSupported(c, AnalysisDC); err != nil {
+		return nil, err
+	}
+	index := -1
+	for k, comp := range c.Components {
+		if comp.ID == sourceID {
+			index = k
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("sweep source %s is not in the circuit", sourceID)
+	}
+	source := c.Components[index]
+	if source.Type != Battery && source.Type != CurrentSource {
+		return nil, fmt.Errorf("sweep source %s is a %s, not a battery or current source", sourceID, source.Type)
+	}
+	if source.Type == Battery {
+		source = source.WithProperty("internalResistance", internalResistance(source))
+		delete(source.Properties, "chemistry")
+	}
+
+	work := *c
+	work.Components = append([]Component(nil), c.Components...)
+	solutions := make([]*Solution, len(values))
+	for k, v := range values {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		reportProgress(ctx, float64(k)/float64(len(values)))
+		source.Value, source.Expression = v, ""
+		work.Components[index] = source
+		sol, err := Solve(&work)
+		if err != nil {
+			return nil, fmt.Errorf("%s = %g: %w", sourceID, v, err)
+		}
+		solutions[k] = sol
+	}
+	return solutions, nil
+}
+
+func sweepParam(ctx context.Context, c *Circuit, name string, values []float64) ([]*Solution, error) {
+	c, err := Flatten(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSupported(c, AnalysisDC); err != nil {
+		return nil, err
+	}
+	solutions := make([]*Solution, len(values))
+	for k, v := range values {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		reportProgress(ctx, float64(k)/float64(len(values)))
+		sol, err := Solve(c.WithParam(name, v))
+		if err != nil {
+			return nil, fmt.Errorf("%s = %g: %w", name, v, err)
+		}
+		solutions[k] = sol
+	}
+	return solutions, nil
+}
+
+// SweepValues lists the points of a sweep from start to stop in steps of
+// step, including stop when it falls on a step.
+func SweepValues(start, stop, step float64) ([]float64, error) {
+	if step == 0 || (stop-start)/step < 0 {
+		return nil, fmt.Errorf("step %g does not lead from %g to %g", step, start, stop)
+	}
+	n := int((stop-start)/step+1e-9) + 1
+	if n > 100000 {
+		return nil, fmt.Errorf("sweep of %d points is too long", n)
+	}
+	values := make([]float64, n)
+	for k := range values {
+		values[k] = start + float64(k)*step
+	}
+	return values, nil
+}
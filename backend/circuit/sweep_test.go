@@ -0,0 +1,42 @@
+package circuit
+
+import "testing"
+
+func TestSweepDCChemistryBattery(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 9, Nodes: []string{"vcc", "0"},
+				Properties: map[string]interface{}{"chemistry": "alkaline", "internalResistance": 1.0}},
+			{ID: "R1", Type: Resistor, Value: 9, Nodes: []string{"vcc", "0"}},
+		},
+	}
+	sols, err := SweepDC(c, "B1", []float64{0, 5, 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range []float64{0, 4.5, 9} {
+		if !isClose(sols[k].NodeVoltages["vcc"], want) {
+			t.Errorf("point %d: vcc = %v, want %v", k, sols[k].NodeVoltages["vcc"], want)
+		}
+	}
+	if c.Components[0].Value != 9 || c.Components[0].Properties["chemistry"] != "alkaline" {
+		t.Errorf("SweepDC modified the circuit: %+v", c.Components[0])
+	}
+
+	if _, err := SweepDC(c, "R1", []float64{1}); err == nil {
+		t.Error("SweepDC accepted a resistor as the source")
+	}
+}
+
+func TestSweepValues(t *testing.T) {
+	values, err := SweepValues(0, 1, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 11 || !isClose(values[10], 1) {
+		t.Errorf("values = %v", values)
+	}
+	if _, err := SweepValues(0, 1, -0.1); err == nil {
+		t.Error("SweepValues accepted a step away from stop")
+	}
+}
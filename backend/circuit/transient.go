@@ -0,0 +1,222 @@
+package circuit
+
+import (
+	"context"
+	"fmt"
+)
+
+// companion is the conductance a capacitor or inductor is replaced by for
+// one time step. It is stamped like a resistor of Value ohms but, unlike
+// a small resistor, never counts as a short.
+const companion ComponentType = "companion"
+
+// Transient steps a circuit through time with backward Euler integration.
+// For each step of length h, a capacitor C becomes a resistance h/C in
+// parallel with a current source holding its previous voltage, and an
+// inductor L a resistance L/h in parallel with a source carrying its
+// previous current; the result is solved like any DC circuit. Switches,
+// potentiometers and every other part keep their DC behaviour, so the
+// circuit may be changed between steps with Update.
+//
+// A Transient is not safe for concurrent use.
+type Transient struct {
+	circuit  *Circuit // wired by net name
+	step     float64
+	steps    int
+	voltages map[string]float64 // capacitor voltages
+	currents map[string]float64 // inductor currents
+	last     *Solution
+	monitor  *StressMonitor
+}
+
+// NewTransient prepares to step c in steps of step seconds. The starting
+// point is the DC operating point, at which capacitors are open and
+// inductors shorted, except for capacitors and inductors that set an
+// initialVoltage or initialCurrent property.
+func NewTransient(c *Circuit, step float64) (*Transient, error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("time step %g is not positive", step)
+	}
+	c, err := Flatten(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSupported(c, AnalysisTransient); err != nil {
+		return nil, err
+	}
+	// values are fixed from here on, so changes made while stepping hold
+	if c, err = fixValues(c); err != nil {
+		return nil, err
+	}
+	sol, err := Solve(c)
+	if err != nil {
+		return nil, fmt.Errorf("operating point: %w", err)
+	}
+
+	t := &Transient{
+		circuit:  &Circuit{Components: make([]Component, len(c.Components))},
+		step:     step,
+		voltages: make(map[string]float64),
+		currents: make(map[string]float64),
+		last:     sol,
+	}
+	nodes := ComponentNodes(c)
+	initial := &Circuit{Components: make([]Component, len(c.Components))}
+	set := false
+	for k, comp := range c.Components {
+		comp.Nodes = nodes[comp.ID]
+		t.circuit.Components[k] = comp
+		initial.Components[k] = comp
+		switch comp.Type {
+		case Capacitor:
+			t.voltages[comp.ID] = sol.Voltages[comp.ID]
+			if v, ok := comp.Float("initialVoltage"); ok {
+				t.voltages[comp.ID] = v
+				initial.Components[k] = Component{ID: comp.ID, Type: Battery, Value: v, Nodes: comp.Nodes}
+				set = true
+			}
+		case Inductor:
+			t.currents[comp.ID] = sol.Currents[comp.ID]
+			if i, ok := comp.Float("initialCurrent"); ok {
+				t.currents[comp.ID] = i
+				initial.Components[k] = Component{ID: comp.ID, Type: CurrentSource, Value: -i, Nodes: comp.Nodes}
+				set = true
+			}
+		}
+	}
+	if set {
+		// the starting point holds the parts at their initial conditions
+		if t.last, err = Solve(initial); err != nil {
+			return nil, fmt.Errorf("initial conditions: %w", err)
+		}
+	}
+	t.monitor = NewStressMonitor(t.circuit)
+	t.monitor.Observe(0, t.last)
+	return t, nil
+}
+
+// Time returns the simulated time in seconds.
+func (t *Transient) Time() float64 {
+	return float64(t.steps) * t.step
+}
+
+// Solution returns the circuit's state at Time: the operating point
+// before the first step.
+func (t *Transient) Solution() *Solution {
+	return t.last
+}
+
+// Step advances the simulation by one time step and returns the new
+// state of the circuit.
+func (t *Transient) Step() (*Solution, error) {
+	h := t.step
+	work := &Circuit{Components: make([]Component, 0, len(t.circuit.Components))}
+	for _, comp := range t.circuit.Components {
+		if (comp.Type != Capacitor && comp.Type != Inductor) || comp.Value <= 0 || len(comp.Nodes) < 2 {
+			work.Components = append(work.Components, comp)
+			continue
+		}
+		pos, neg := comp.Nodes[0], comp.Nodes[1]
+		if comp.Type == Capacitor {
+			g := comp.Value / h
+			work.Components = append(work.Components,
+				Component{ID: comp.ID, Type: companion, Value: 1 / g, Nodes: []string{pos, neg}},
+				Component{ID: historyID(comp.ID), Type: CurrentSource, Value: g * t.voltages[comp.ID], Nodes: []string{pos, neg}})
+		} else {
+			work.Components = append(work.Components,
+				Component{ID: comp.ID, Type: companion, Value: comp.Value / h, Nodes: []string{pos, neg}},
+				Component{ID: historyID(comp.ID), Type: CurrentSource, Value: t.currents[comp.ID], Nodes: []string{neg, pos}})
+		}
+	}
+
+	sol, err := Solve(work)
+	if err != nil {
+		return nil, fmt.Errorf("at t=%gs: %w", float64(t.steps+1)*h, err)
+	}
+	for _, comp := range t.circuit.Components {
+		if (comp.Type != Capacitor && comp.Type != Inductor) || comp.Value <= 0 || len(comp.Nodes) < 2 {
+			continue
+		}
+		hist := historyID(comp.ID)
+		delete(sol.Voltages, hist)
+		delete(sol.Currents, hist)
+		delete(sol.Terminals, hist)
+
+		v := sol.Voltages[comp.ID]
+		if comp.Type == Capacitor {
+			sol.Currents[comp.ID] = comp.Value / h * (v - t.voltages[comp.ID])
+			t.voltages[comp.ID] = v
+		} else {
+			t.currents[comp.ID] += v * h / comp.Value
+			sol.Currents[comp.ID] = t.currents[comp.ID]
+		}
+	}
+	t.steps++
+	t.last = sol
+	t.monitor.Observe(t.Time(), sol)
+	return sol, nil
+}
+
+// Update changes the component id between steps. The change is made on a
+// copy of the component whose properties may be modified freely; its ID,
+// type and nodes stay as they were. Capacitors keep their voltage and
+// inductors their current.
+func (t *Transient) Update(id string, change func(*Component)) error {
+	for k, comp := range t.circuit.Components {
+		if comp.ID != id {
+			continue
+		}
+		props := make(map[string]interface{}, len(comp.Properties))
+		for name, v := range comp.Properties {
+			props[name] = v
+		}
+		updated := comp
+		updated.Properties = props
+		change(&updated)
+		updated.ID, updated.Type, updated.Nodes = comp.ID, comp.Type, comp.Nodes
+		t.circuit.Components[k] = updated
+		return nil
+	}
+	return fmt.Errorf("component %s is not in the circuit", id)
+}
+
+// Stress returns the parts loaded near or beyond their ratings at any
+// point so far, with the time each rating was first exceeded.
+func (t *Transient) Stress() []StressFinding {
+	return t.monitor.Findings()
+}
+
+// SimulateTransient steps c from its operating point to stop seconds in
+// steps of step, as SPICE's .tran analysis does, and returns the time and
+// solution of every point, the operating point first, and the stress
+// findings over the run. It stops early with ctx's error once ctx is done
+// and reports its progress to ctx.
+func SimulateTransient(ctx context.Context, c *Circuit, step, stop float64) ([]float64, []*Solution, []StressFinding, error) {
+	values, err := SweepValues(0, stop, step)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	t, err := NewTransient(c, step)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	solutions := []*Solution{t.Solution()}
+	for k := 1; k < len(values); k++ {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, nil, err
+		}
+		reportProgress(ctx, float64(k)/float64(len(values)))
+		sol, err := t.Step()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		solutions = append(solutions, sol)
+	}
+	return values, solutions, t.Stress(), nil
+}
+
+// historyID names the current source that carries a capacitor's or
+// inductor's state into the next step.
+func historyID(id string) string {
+	return id + "#history"
+}
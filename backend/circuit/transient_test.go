@@ -0,0 +1,136 @@
+package circuit
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestTransientRCSwitch(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 5, Nodes: []string{"vcc", "0"}},
+			{ID: "S1", Type: Switch, Nodes: []string{"vcc", "a"}},
+			{ID: "R1", Type: Resistor, Value: 1000, Nodes: []string{"a", "out"}},
+			{ID: "C1", Type: Capacitor, Value: 1e-6, Nodes: []string{"out", "0"}},
+		},
+	}
+	const h = 1e-5
+	tr, err := NewTransient(c, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := tr.Solution().NodeVoltages["out"]; !isClose(v, 0) {
+		t.Fatalf("out starts at %v with the switch open", v)
+	}
+	if _, err := tr.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if v := tr.Solution().NodeVoltages["out"]; math.Abs(v) > 1e-3 {
+		t.Fatalf("out = %v after a step with the switch open", v)
+	}
+
+	if err := tr.Update("S1", func(comp *Component) { comp.Properties["closed"] = true }); err != nil {
+		t.Fatal(err)
+	}
+	if c.Components[1].Properties != nil {
+		t.Errorf("Update modified the circuit: %+v", c.Components[1])
+	}
+	var sol *Solution
+	for k := 0; k < 100; k++ {
+		if sol, err = tr.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// backward Euler: each step keeps 1/(1+h/RC) of the remaining gap
+	want := 5 * (1 - math.Pow(1/(1+h/1e-3), 100))
+	if got := sol.NodeVoltages["out"]; math.Abs(got-want) > 1e-3 {
+		t.Errorf("out = %v after 1 ms, want %v", got, want)
+	}
+	if i, want := sol.Currents["C1"], (5-sol.NodeVoltages["out"])/1000; math.Abs(i-want) > 1e-6 {
+		t.Errorf("capacitor current = %v, want %v", i, want)
+	}
+	if _, ok := sol.Currents[historyID("C1")]; ok {
+		t.Error("solution reports the companion source")
+	}
+	if !isClose(tr.Time(), 101*h) {
+		t.Errorf("time = %v, want %v", tr.Time(), 101*h)
+	}
+
+	if err := tr.Update("X9", func(*Component) {}); err == nil {
+		t.Error("Update accepted an unknown component")
+	}
+}
+
+func TestTransientRL(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 10, Nodes: []string{"vcc", "0"}},
+			{ID: "R1", Type: Resistor, Value: 10, Nodes: []string{"vcc", "a"}},
+			{ID: "L1", Type: Inductor, Value: 0.1, Nodes: []string{"a", "0"},
+				Properties: map[string]interface{}{"initialCurrent": 0.0}},
+		},
+	}
+	tr, err := NewTransient(c, 1e-4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sol *Solution
+	for k := 0; k < 1000; k++ {
+		if sol, err = tr.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// ten time constants: the inductor carries nearly V/R
+	if i := sol.Currents["L1"]; math.Abs(i-1) > 1e-3 {
+		t.Errorf("inductor current = %v, want 1", i)
+	}
+}
+
+func TestPotentiometer(t *testing.T) {
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 5, Nodes: []string{"vcc", "0"}},
+			{ID: "P1", Type: Potentiometer, Value: 10000, Nodes: []string{"vcc", "0", "w"},
+				Properties: map[string]interface{}{"position": 0.25}},
+		},
+	}
+	sol, err := Solve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isClose(sol.NodeVoltages["w"], 3.75) {
+		t.Errorf("wiper = %v, want 3.75", sol.NodeVoltages["w"])
+	}
+	if !isClose(sol.Currents["P1"], 5.0/10000) || !isClose(sol.Currents[WiperID("P1")], 5.0/10000) {
+		t.Errorf("currents = %v", sol.Currents)
+	}
+}
+
+func TestSimulateTransientStress(t *testing.T) {
+	// C1 charges towards 5 V and passes its 3 V rating on the way
+	c := &Circuit{
+		Components: []Component{
+			{ID: "B1", Type: Battery, Value: 5, Nodes: []string{"vcc", "0"}},
+			{ID: "R1", Type: Resistor, Value: 1000, Nodes: []string{"vcc", "out"}},
+			{ID: "C1", Type: Capacitor, Value: 1e-6, Nodes: []string{"out", "0"}, Properties: map[string]interface{}{
+				"voltageRating":  3.0,
+				"initialVoltage": 0.0,
+			}},
+		},
+	}
+	_, _, stress, err := SimulateTransient(context.Background(), c, 1e-4, 2e-3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stress) != 1 || stress[0].ComponentID != "C1" || stress[0].Level != StressDestroyed {
+		t.Fatalf("stress = %+v", stress)
+	}
+	// backward Euler: v = 5(1 - 1.1^-n) passes 3 V at step 10
+	if at := stress[0].FirstExceeded; at == nil || !isClose(*at, 1e-3) {
+		t.Errorf("first exceeded at %v, want 1 ms", at)
+	}
+	if stress[0].Value < 4 {
+		t.Errorf("peak %v, want the voltage at 2 ms", stress[0].Value)
+	}
+}
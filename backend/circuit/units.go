@@ -0,0 +1,199 @@
+package circuit
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// siPrefixes maps SI prefix symbols to their multipliers. Prefixes are case
+// sensitive as on a parts list ("m" is milli, "M" is mega); SPICE's "meg"
+// is accepted in any case.
+var siPrefixes = map[string]float64{
+	"f": 1e-15,
+	"p": 1e-12,
+	"n": 1e-9,
+	"u": 1e-6,
+	"µ": 1e-6,
+	"μ": 1e-6,
+	"m": 1e-3,
+	"k": 1e3,
+	"K": 1e3,
+	"M": 1e6,
+	"G": 1e9,
+	"T": 1e12,
+}
+
+// units maps accepted unit spellings, lower-cased, to their symbol.
+var units = map[string]string{
+	"ω":    "Ω",
+	"ohm":  "Ω",
+	"ohms": "Ω",
+	"r":    "Ω",
+	"f":    "F",
+	"h":    "H",
+	"v":    "V",
+	"a":    "A",
+	"w":    "W",
+	"hz":   "Hz",
+	"s":    "s",
+	"ah":   "Ah",
+	"%":    "%",
+}
+
+var (
+	numberPattern = regexp.MustCompile(`^([+-]?(?:\d+\.?\d*|\.\d+)(?:[eE][+-]?\d+)?)(.*)$`)
+	// rkmPattern matches the RKM code, where the multiplier letter stands in
+	// for the decimal point: 4k7, 4R7, R47, 3V3, 2u2.
+	rkmPattern = regexp.MustCompile(`^([+-]?)(\d*)([RrVvfpnuµμmkKMGT])(\d+)(.*)$`)
+)
+
+// ParseValue parses a component value written in engineering notation,
+// such as "4.7k", "220n", "10uF", "1.5 MΩ", "4k7" or "3V3".
+func ParseValue(s string) (float64, error) {
+	v, _, err := ParseQuantity(s)
+	return v, err
+}
+
+// ParseQuantity parses a value in engineering notation and also returns
+// the unit symbol it was written with, or "" if it had none.
+func ParseQuantity(s string) (float64, string, error) {
+	text := strings.TrimSpace(s)
+	if text == "" {
+		return 0, "", fmt.Errorf("empty value")
+	}
+
+	if m := rkmPattern.FindStringSubmatch(text); m != nil && (m[2] != "" || m[3] == "R" || m[3] == "r") {
+		v, err := strconv.ParseFloat(m[2]+"."+m[4], 64)
+		if err == nil {
+			multiplier, unit := 1.0, ""
+			switch m[3] {
+			case "R", "r":
+				unit = "Ω"
+			case "V", "v":
+				unit = "V"
+			default:
+				multiplier = siPrefixes[m[3]]
+			}
+			suffixUnit, ok := parseUnit(m[5])
+			if ok {
+				if suffixUnit != "" {
+					unit = suffixUnit
+				}
+				if m[1] == "-" {
+					v = -v
+				}
+				return v * multiplier, unit, nil
+			}
+		}
+	}
+
+	m := numberPattern.FindStringSubmatch(text)
+	if m == nil {
+		return 0, "", fmt.Errorf("invalid value %q", s)
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid value %q", s)
+	}
+	multiplier, unit, ok := parseSuffix(strings.TrimSpace(m[2]))
+	if !ok {
+		return 0, "", fmt.Errorf("invalid value %q: unknown suffix %q", s, strings.TrimSpace(m[2]))
+	}
+	return v * multiplier, unit, nil
+}
+
+// parseSuffix splits what follows the number into a prefix multiplier and
+// a unit. A lone prefix letter is a prefix ("10f" is ten femto), otherwise
+// a bare unit wins, so "10F" is ten farads.
+func parseSuffix(suffix string) (float64, string, bool) {
+	if multiplier, ok := siPrefixes[suffix]; ok {
+		return multiplier, "", true
+	}
+	if unit, ok := parseUnit(suffix); ok {
+		return 1, unit, true
+	}
+	if len(suffix) >= 3 && strings.EqualFold(suffix[:3], "meg") {
+		unit, ok := parseUnit(suffix[3:])
+		return 1e6, unit, ok
+	}
+	for prefix, multiplier := range siPrefixes {
+		if strings.HasPrefix(suffix, prefix) {
+			if unit, ok := parseUnit(suffix[len(prefix):]); ok {
+				return multiplier, unit, true
+			}
+		}
+	}
+	return 0, "", false
+}
+
+func parseUnit(s string) (string, bool) {
+	if s == "" {
+		return "", true
+	}
+	unit, ok := units[strings.ToLower(s)]
+	return unit, ok
+}
+
+// formatPrefixes lists the prefixes FormatValue chooses from, by exponent.
+var formatPrefixes = map[int]string{
+	-15: "f", -12: "p", -9: "n", -6: "µ", -3: "m", 0: "", 3: "k", 6: "M", 9: "G", 12: "T",
+}
+
+// FormatValue renders v in engineering notation with three significant
+// digits and the given unit symbol, e.g. FormatValue(4700, "Ω") is "4.7kΩ".
+func FormatValue(v float64, unit string) string {
+	if v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'g', -1, 64) + unit
+	}
+
+	exp := int(math.Floor(math.Log10(math.Abs(v))/3)) * 3
+	exp = max(-15, min(12, exp))
+	mantissa, _ := strconv.ParseFloat(strconv.FormatFloat(v/math.Pow10(exp), 'g', 3, 64), 64)
+	if math.Abs(mantissa) >= 1000 && exp < 12 {
+		exp += 3
+		mantissa /= 1000
+	}
+	return strconv.FormatFloat(mantissa, 'f', -1, 64) + formatPrefixes[exp] + unit
+}
+
+// UnmarshalJSON accepts the value either as a number or as a string in
+// engineering notation. Any other string that parses as an expression,
+// such as "2*R1", is taken as the component's Expression. A string that
+// reads as both, like the RKM code "R1", is a value; to refer to component
+// R1 alone, set Expression.
+func (c *Component) UnmarshalJSON(data []byte) error {
+	type plain Component
+	var raw struct {
+		plain
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*c = Component(raw.plain)
+
+	if len(raw.Value) == 0 || string(raw.Value) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(raw.Value, &c.Value); err == nil {
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(raw.Value, &text); err != nil {
+		return fmt.Errorf("component %s: value must be a number or string", c.ID)
+	}
+	v, err := ParseValue(text)
+	if err == nil {
+		c.Value = v
+		return nil
+	}
+	if _, exprErr := ParseExpr(text); exprErr != nil {
+		return fmt.Errorf("component %s: %w", c.ID, err)
+	}
+	c.Value, c.Expression = 0, text
+	return nil
+}
@@ -0,0 +1,91 @@
+package circuit
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		unit string
+	}{
+		{"4.7k", 4700, ""},
+		{"220n", 220e-9, ""},
+		{"10uF", 10e-6, "F"},
+		{"10µF", 10e-6, "F"},
+		{"1.5 MΩ", 1.5e6, "Ω"},
+		{"2.2Meg", 2.2e6, ""},
+		{"330ohm", 330, "Ω"},
+		{"4k7", 4700, ""},
+		{"4K7Ω", 4700, "Ω"},
+		{"4R7", 4.7, "Ω"},
+		{"R47", 0.47, "Ω"},
+		{"3V3", 3.3, "V"},
+		{"2u2", 2.2e-6, ""},
+		{"10F", 10, "F"},
+		{"10f", 10e-15, ""},
+		{"20mA", 0.02, "A"},
+		{"-5V", -5, "V"},
+		{"1e3", 1000, ""},
+		{"9", 9, ""},
+	}
+	for _, tt := range tests {
+		got, unit, err := ParseQuantity(tt.in)
+		if err != nil {
+			t.Errorf("ParseQuantity(%q) error: %v", tt.in, err)
+			continue
+		}
+		if !isClose(got/tt.want, 1) || unit != tt.unit {
+			t.Errorf("ParseQuantity(%q) = %v %q, want %v %q", tt.in, got, unit, tt.want, tt.unit)
+		}
+	}
+
+	for _, bad := range []string{"", "k", "4.7x", "10 bananas"} {
+		if _, err := ParseValue(bad); err == nil {
+			t.Errorf("ParseValue(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		v    float64
+		unit string
+		want string
+	}{
+		{4700, "Ω", "4.7kΩ"},
+		{220e-9, "F", "220nF"},
+		{0.0205, "A", "20.5mA"},
+		{9, "V", "9V"},
+		{999.7, "Ω", "1kΩ"},
+		{-1.5e6, "Ω", "-1.5MΩ"},
+		{0, "W", "0W"},
+	}
+	for _, tt := range tests {
+		if got := FormatValue(tt.v, tt.unit); got != tt.want {
+			t.Errorf("FormatValue(%v, %q) = %q, want %q", tt.v, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestComponentValueJSON(t *testing.T) {
+	var comps []Component
+	data := `[{"id": "R1", "type": "resistor", "value": "4k7"}, {"id": "B1", "type": "battery", "value": 9}]`
+	if err := json.Unmarshal([]byte(data), &comps); err != nil {
+		t.Fatal(err)
+	}
+	if comps[0].ID != "R1" || comps[0].Value != 4700 || comps[1].Value != 9 {
+		t.Errorf("decoded %+v", comps)
+	}
+	if err := json.Unmarshal([]byte(`{"id": "R2", "value": "4.7q"}`), &comps[0]); err == nil {
+		t.Errorf("invalid value decoded without error")
+	}
+	if err := json.Unmarshal([]byte(`{"id": "R2", "value": "2*R1"}`), &comps[0]); err != nil {
+		t.Fatal(err)
+	}
+	if comps[0].Expression != "2*R1" || comps[0].Value != 0 {
+		t.Errorf("expression decoded as %+v", comps[0])
+	}
+}
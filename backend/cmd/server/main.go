@@ -1,237 +1,135 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
+	"context"
+	"flag"
 	"log"
 	"net/http"
-	"sync"
-	"breadboard-simulator/circuit"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+	"breadboard-simulator/api"
+	"breadboard-simulator/collab"
+	"breadboard-simulator/jobs"
+	"breadboard-simulator/parts"
+	"breadboard-simulator/project"
+	"breadboard-simulator/savefile"
+	"breadboard-simulator/session"
+	"breadboard-simulator/storage"
 )
 
-type BreadboardState struct {
-	Components []Component `json:"components"`
-	Connections []Connection `json:"connections"`
-}
-
-type Component struct {
-	ID string `json:"id"`
-	Type string `json:"type"`
-	Position struct {
-		X int `json:"x"`
-		Y int `json:"y"`
-	} `json:"position"`
-}
-
-type Connection struct {
-	From string `json:"from"`
-	To string `json:"to"`
-}
-
-var (
-	sessionState BreadboardState
-	stateMutex sync.RWMutex
-)
-
-type SimulationRequest struct {
-	Components  []simulation.Component  `json:"components"`
-	Connections []simulation.Connection `json:"connections"`
-}
-
-func handleSimulate(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received request to /api/simulate")
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	var simReq SimulationRequest
-	if err := json.NewDecoder(r.Body).Decode(&simReq); err != nil {
-		log.Println("Error decoding request body:", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	log.Printf("Simulation request body: %+v\n", simReq)
+// legacySaveFile is where /api/save-file kept the one saved breadboard
+// before projects; it is imported into the project store on startup.
+const legacySaveFile = "saved_breadboard.json"
 
-	// Create a Circuit struct from the request
-	circuit := simulation.Circuit{
-		Components:  simReq.Components,
-		Connections: simReq.Connections,
-	}
+// legacyProjectDir is the directory under the data directory where
+// projects were kept as files before the database; they are imported on
+// startup.
+const legacyProjectDir = "projects"
 
-	// Calculate voltages and currents
-	voltages, currents, err := simulation.CalculateVoltageAndCurrent(circuit)
+func main() {
+	addr := flag.String("addr", envOr("BREADBOARD_ADDR", ":8080"), "address to listen on")
+	dataDir := flag.String("data", envOr("BREADBOARD_DATA_DIR", "data"), "directory for stored projects")
+	sessionIdle := flag.Duration("session-idle", 24*time.Hour, "delete sessions idle for this long")
+	jobWorkers := flag.Int("job-workers", runtime.NumCPU(), "simulation jobs run at once")
+	jobTimeout := flag.Duration("job-timeout", 5*time.Minute, "longest a simulation job may run")
+	jobTTL := flag.Duration("job-ttl", time.Hour, "keep finished jobs' results for this long")
+	partsDir := flag.String("parts", envOr("BREADBOARD_PARTS_DIR", ""), "directory of extra parts and imported models (default parts under -data)")
+	flag.Parse()
+
+	if err := os.MkdirAll(*dataDir, 0755); err != nil {
+		log.Fatal(err)
+	}
+	db, err := storage.Open(filepath.Join(*dataDir, "breadboard.db"))
 	if err != nil {
-		log.Printf("Error calculating voltage and current: %v\n", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		log.Fatal(err)
 	}
+	defer db.Close()
+	importLegacyProjects(db, filepath.Join(*dataDir, legacyProjectDir))
+	importLegacySave(db)
 
-	// Prepare the response
-	response := map[string]interface{}{
-		"voltages": voltages,
-		"currents": currents,
+	if *partsDir == "" {
+		*partsDir = filepath.Join(*dataDir, "parts")
 	}
-
-	// Send the response
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding response: %v\n", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if err := parts.Default.LoadDir(*partsDir); err != nil {
+		log.Fatal(err)
 	}
 
-	log.Printf("Simulation response: %+v\n", response)
-}
-
-func main() {
-	mux := http.NewServeMux()
-
-	mux.HandleFunc("/", enableCORS(handleRoot))
-	mux.HandleFunc("/api/components", enableCORS(handleComponents))
-	mux.HandleFunc("/api/save", enableCORS(handleSave))
-	mux.HandleFunc("/api/load", enableCORS(handleLoad))
-	mux.HandleFunc("/api/save-file", enableCORS(handleSaveFile))
-	mux.HandleFunc("/api/load-file", enableCORS(handleLoadFile))
-	mux.HandleFunc("/api/download", enableCORS(handleDownload))
-	mux.HandleFunc("/api/upload", enableCORS(handleUpload))
-	mux.HandleFunc("/api/simulate", enableCORS(handleSimulate))
+	sessions := session.NewManager(db, *sessionIdle)
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go sessions.Cleanup(ctx, 10*time.Minute)
 
-	log.Println("Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", mux))
-}
-
-func handleRoot(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "Breadboard Simulator API is running")
-}
+	queue := jobs.NewQueue(jobs.Options{Workers: *jobWorkers, Timeout: *jobTimeout, TTL: *jobTTL})
+	defer queue.Close()
+	go queue.Cleanup(ctx, time.Minute)
 
-func handleComponents(w http.ResponseWriter, r *http.Request) {
-	log.Println("Handling /api/components request")
-	components := []string{"resistor", "capacitor", "led", "transistor", "ic"}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "success",
-		"components": components,
+	router := api.NewRouter(api.Services{
+		Store:    db,
+		Sessions: sessions,
+		Hub:      collab.NewHub(db),
+		Jobs:     queue,
 	})
-}
 
-func handleSave(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	log.Printf("Server starting on %s\n", *addr)
+	if err := http.ListenAndServe(*addr, router); err != nil {
+		log.Println(err)
 	}
-
-	var state BreadboardState
-	if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	stateMutex.Lock()
-	sessionState = state
-	stateMutex.Unlock()
-
-	w.WriteHeader(http.StatusOK)
 }
 
-func handleLoad(w http.ResponseWriter, r *http.Request) {
-	stateMutex.RLock()
-	json.NewEncoder(w).Encode(sessionState)
-	stateMutex.RUnlock()
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
 }
 
-func handleSaveFile(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// importLegacyProjects moves the projects kept as files in dir into the
+// database, renaming the directory so they are imported once.
+func importLegacyProjects(db *storage.DB, dir string) {
+	if _, err := os.Stat(dir); err != nil {
 		return
 	}
-
-	var state BreadboardState
-	if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	src, err := project.NewFileStore(dir)
+	if err != nil {
+		log.Printf("Not importing %s: %v\n", dir, err)
 		return
 	}
-
-	data, err := json.MarshalIndent(state, "", "  ")
+	n, err := db.ImportFileStore(src)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Printf("Imported %d projects from %s, then: %v\n", n, dir, err)
 		return
 	}
-
-	if err := ioutil.WriteFile("saved_breadboard.json", data, 0644); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := os.Rename(dir, dir+".imported"); err != nil {
+		log.Printf("Imported %d projects from %s but could not rename it: %v\n", n, dir, err)
 		return
 	}
-
-	w.WriteHeader(http.StatusOK)
+	log.Printf("Imported %d projects from %s\n", n, dir)
 }
 
-func handleLoadFile(w http.ResponseWriter, r *http.Request) {
-	data, err := ioutil.ReadFile("saved_breadboard.json")
+// importLegacySave moves a breadboard saved by the old /api/save-file
+// endpoint into the project store, renaming the file so it is imported
+// once.
+func importLegacySave(store project.Store) {
+	file, err := os.Open(legacySaveFile)
 	if err != nil {
-		http.Error(w, "No saved file found", http.StatusNotFound)
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(data)
-}
-
-func handleDownload(w http.ResponseWriter, r *http.Request) {
-	stateMutex.RLock()
-	data, err := json.MarshalIndent(sessionState, "", "  ")
-	stateMutex.RUnlock()
-
+	f, err := savefile.Load(file)
+	file.Close()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Disposition", "attachment; filename=breadboard_state.json")
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(data)
-}
-
-func handleUpload(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		log.Printf("Not importing %s: %v\n", legacySaveFile, err)
 		return
 	}
-
-	file, _, err := r.FormFile("file")
+	info, err := store.Import(f, "Saved breadboard")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Printf("Not importing %s: %v\n", legacySaveFile, err)
 		return
 	}
-	defer file.Close()
-
-	var state BreadboardState
-	if err := json.NewDecoder(file).Decode(&state); err != nil {
-		http.Error(w, "Invalid JSON file", http.StatusBadRequest)
+	if err := os.Rename(legacySaveFile, legacySaveFile+".imported"); err != nil {
+		log.Printf("Imported %s as project %s but could not rename it: %v\n", legacySaveFile, info.ID, err)
 		return
 	}
-
-	stateMutex.Lock()
-	sessionState = state
-	stateMutex.Unlock()
-
-	fmt.Fprintf(w, "File uploaded and state updated successfully")
+	log.Printf("Imported %s as project %s\n", legacySaveFile, info.ID)
 }
-
-// Add this function to enable CORS
-func enableCORS(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		
-		next.ServeHTTP(w, r)
-	}
-}
\ No newline at end of file
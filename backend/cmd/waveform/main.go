@@ -0,0 +1,184 @@
+// Command waveform simulates a circuit and writes the results as CSV, a
+// SPICE raw file or VCD.
+//
+// The input is a circuit in the API's JSON form ({"components": [...],
+// "connections": [...]}), a SPICE deck (.cir, .sp, .net) or a dataset in
+// JSON, which is converted without simulating:
+//
+//	waveform -format raw -sweep V1 -start 0 -stop 5 -step 0.1 divider.json
+//	waveform -format csv -drain -o drain.csv torch.json
+//	waveform -format vcd -low 0.8 -high 2 results.json
+//
+// A deck's own .dc directive is used when no analysis is given.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"breadboard-simulator/circuit"
+	"breadboard-simulator/spice"
+	"breadboard-simulator/waveform"
+)
+
+func main() {
+	format := flag.String("format", "csv", "output format: "+strings.Join(waveform.Formats, ", "))
+	out := flag.String("o", "", "output file (default stdout)")
+	title := flag.String("title", "", "dataset title")
+	sweep := flag.String("sweep", "", "source to sweep for a DC analysis")
+	start := flag.Float64("start", 0, "sweep start value")
+	stop := flag.Float64("stop", 0, "sweep stop value")
+	step := flag.Float64("step", 0, "sweep step")
+	drain := flag.Bool("drain", false, "simulate the batteries draining")
+	maxDuration := flag.Float64("max-duration", 0, "drain: longest simulated time in seconds")
+	low := flag.Float64("low", 0, "vcd: logic low threshold in volts (default 0.8)")
+	high := flag.Float64("high", 0, "vcd: logic high threshold in volts (default 2.0)")
+	timescale := flag.Float64("timescale", 0, "vcd: time unit in seconds (default 1e-9)")
+	signals := flag.String("signals", "", "vcd: comma-separated voltages to dump, such as v(out)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: waveform [flags] [input]\n\nReads stdin when no input is given.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	name := "-"
+	if flag.NArg() == 1 {
+		name = flag.Arg(0)
+	}
+	data, err := readInput(name)
+	if err != nil {
+		fatal(err)
+	}
+
+	a := waveform.Analysis{Source: *sweep, Start: *start, Stop: *stop, Step: *step}
+	switch {
+	case *sweep != "" && *drain:
+		fatal(fmt.Errorf("-sweep and -drain are exclusive"))
+	case *sweep != "":
+		a.Type = waveform.DCSweep
+	case *drain:
+		a.Type = "drain"
+		a.Drain.MaxDuration = *maxDuration
+	}
+
+	d, err := dataset(name, data, *title, a)
+	if err != nil {
+		fatal(err)
+	}
+
+	opts := waveform.VCDOptions{Low: *low, High: *high, Timescale: *timescale}
+	if *signals != "" {
+		opts.Signals = strings.Split(*signals, ",")
+	}
+	var buf bytes.Buffer
+	if err := waveform.Write(&buf, d, *format, opts); err != nil {
+		fatal(err)
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+	} else {
+		err = os.WriteFile(*out, buf.Bytes(), 0o644)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func readInput(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// dataset turns the input into a dataset, simulating it when it is a
+// circuit.
+func dataset(name string, data []byte, title string, a waveform.Analysis) (*waveform.Dataset, error) {
+	var c *circuit.Circuit
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".cir", ".sp", ".spi", ".net":
+		deck, err := spice.Parse(bytes.NewReader(data), name)
+		if err != nil {
+			return nil, err
+		}
+		if title == "" {
+			title = deck.Title
+		}
+		if a.Type == "" {
+			a, err = deckAnalysis(deck)
+			if err != nil {
+				return nil, err
+			}
+		}
+		c = deck.Circuit
+	default:
+		var probe struct {
+			Variables []waveform.Variable `json:"variables"`
+		}
+		if err := json.Unmarshal(data, &probe); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if probe.Variables != nil {
+			var d waveform.Dataset
+			if err := json.Unmarshal(data, &d); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			if title != "" {
+				d.Title = title
+			}
+			return &d, nil
+		}
+		c = &circuit.Circuit{}
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if a.Type == "" {
+		return nil, fmt.Errorf("no analysis: use -sweep or -drain")
+	}
+	return waveform.Run(title, c, a)
+}
+
+// deckAnalysis reads a deck's .dc directive.
+func deckAnalysis(deck *spice.Deck) (waveform.Analysis, error) {
+	for _, dir := range deck.Directives {
+		fields := strings.Fields(dir)
+		if len(fields) == 0 || strings.ToLower(fields[0]) != ".dc" {
+			continue
+		}
+		if len(fields) < 5 {
+			return waveform.Analysis{}, fmt.Errorf("%q: want .dc source start stop step", dir)
+		}
+		var values [3]float64
+		for k, f := range fields[2:5] {
+			v, err := circuit.ParseValue(f)
+			if err != nil {
+				return waveform.Analysis{}, fmt.Errorf("%q: %w", dir, err)
+			}
+			values[k] = v
+		}
+		return waveform.Analysis{
+			Type:   waveform.DCSweep,
+			Source: fields[1],
+			Start:  values[0],
+			Stop:   values[1],
+			Step:   values[2],
+		}, nil
+	}
+	return waveform.Analysis{}, fmt.Errorf("the deck has no .dc directive; use -sweep or -drain")
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "waveform:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,143 @@
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"breadboard-simulator/breadboard"
+	"breadboard-simulator/project"
+)
+
+func TestApply(t *testing.T) {
+	var s breadboard.State
+	steps := []struct {
+		op      Op
+		changed bool
+		err     error
+	}{
+		{Op{Type: AddComponent, Component: &breadboard.Component{ID: "r1", Type: "resistor"}}, true, nil},
+		{Op{Type: AddComponent, Component: &breadboard.Component{ID: "led1", Type: "led"}}, true, nil},
+		{Op{Type: AddComponent, Component: &breadboard.Component{ID: "r1", Type: "resistor"}}, false, ErrConflict},
+		{Op{Type: MoveComponent, ID: "r1", Position: &breadboard.Position{X: 3, Y: 4}}, true, nil},
+		{Op{Type: SetProperty, ID: "r1", Property: "resistance", Value: 220.0}, true, nil},
+		{Op{Type: AddWire, Connection: &breadboard.Connection{
+			From: breadboard.Endpoint{ComponentID: "r1", PointIndex: 1},
+			To:   breadboard.Endpoint{ComponentID: "led1"}}}, true, nil},
+		{Op{Type: AddWire, Connection: &breadboard.Connection{ // same wire, reversed
+			From: breadboard.Endpoint{ComponentID: "led1"},
+			To:   breadboard.Endpoint{ComponentID: "r1", PointIndex: 1}}}, false, nil},
+		{Op{Type: AddWire, Connection: &breadboard.Connection{
+			From: breadboard.Endpoint{ComponentID: "r1"},
+			To:   breadboard.Endpoint{ComponentID: "gone"}}}, false, ErrConflict},
+		{Op{Type: DeleteComponent, ID: "led1"}, true, nil},
+		{Op{Type: DeleteComponent, ID: "led1"}, false, nil},
+		{Op{Type: SetProperty, ID: "led1", Property: "color", Value: "red"}, false, ErrConflict},
+		{Op{Type: "explode"}, false, nil},
+	}
+	for k, step := range steps {
+		changed, err := apply(&s, step.op)
+		if step.op.Type == "explode" {
+			if err == nil || errors.Is(err, ErrConflict) {
+				t.Errorf("step %d: unknown op: err = %v", k, err)
+			}
+			continue
+		}
+		if changed != step.changed || !errors.Is(err, step.err) {
+			t.Errorf("step %d (%s): changed %v err %v, want %v %v", k, step.op.Type, changed, err, step.changed, step.err)
+		}
+	}
+	if len(s.Components) != 1 || s.Components[0].Position.X != 3 || s.Components[0].Properties["resistance"] != 220.0 {
+		t.Errorf("components = %+v", s.Components)
+	}
+	if len(s.Connections) != 0 {
+		t.Errorf("deleting led1 left its wire: %+v", s.Connections)
+	}
+}
+
+func receive(t *testing.T, c *Client) Message {
+	t.Helper()
+	select {
+	case data, ok := <-c.Out:
+		if !ok {
+			t.Fatal("client was disconnected")
+		}
+		var m Message
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	case <-time.After(time.Second):
+		t.Fatal("no message")
+	}
+	return Message{}
+}
+
+func TestRoomBroadcastsAndSaves(t *testing.T) {
+	store, err := project.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := store.Create("lab", "", breadboard.State{Components: []breadboard.Component{{ID: "bat", Type: "battery"}}})
+	hub := NewHub(store)
+	hub.SaveDelay = time.Hour
+
+	room, alice, err := hub.Join(info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := receive(t, alice); m.Type != SnapshotMessage || len(m.State.Components) != 1 {
+		t.Fatalf("snapshot = %+v", m)
+	}
+	room.Submit(alice, Op{Type: AddComponent, Ref: "a1", Component: &breadboard.Component{ID: "r1", Type: "resistor"}})
+	receive(t, alice)
+
+	room2, bob, _ := hub.Join(info.ID)
+	if room2 != room {
+		t.Fatal("second client got a different room")
+	}
+	if m := receive(t, bob); m.Version != 1 || len(m.State.Components) != 2 {
+		t.Fatalf("bob's snapshot = %+v", m)
+	}
+
+	room.Submit(bob, Op{Type: DeleteComponent, Ref: "b1", ID: "r1"})
+	room.Submit(alice, Op{Type: MoveComponent, Ref: "a2", ID: "r1", Position: &breadboard.Position{X: 1}})
+	for _, c := range []*Client{alice, bob} {
+		if m := receive(t, c); m.Type != OpMessage || m.Version != 2 || m.Client != bob.ID || m.Op.Ref != "b1" {
+			t.Errorf("broadcast = %+v", m)
+		}
+	}
+	if m := receive(t, alice); m.Type != RejectMessage || m.Op.Ref != "a2" || m.Version != 2 {
+		t.Errorf("alice's move of a deleted part: %+v", m)
+	}
+	select {
+	case data := <-bob.Out:
+		t.Errorf("bob was sent alice's rejection: %s", data)
+	default:
+	}
+
+	room.Submit(bob, Op{Type: SetProperty, ID: "bat", Property: "voltage", Value: 9.0})
+	receive(t, alice)
+	receive(t, bob)
+
+	hub.Leave(room, alice)
+	if p, _ := store.Get(info.ID); p.File.State.Components[0].Properties != nil {
+		t.Error("room saved before the last client left and before its save delay")
+	}
+	hub.Leave(room, bob)
+	p, _ := store.Get(info.ID)
+	if len(p.File.State.Components) != 1 || p.File.State.Components[0].Properties["voltage"] != 9.0 {
+		t.Errorf("saved state = %+v", p.File.State)
+	}
+	if _, ok := <-bob.Out; ok {
+		t.Error("outbox still open after leaving")
+	}
+}
+
+func TestJoinMissingProject(t *testing.T) {
+	store, _ := project.NewFileStore(t.TempDir())
+	if _, _, err := NewHub(store).Join("0123456789abcdef"); !errors.Is(err, project.ErrNotFound) {
+		t.Errorf("err = %v", err)
+	}
+}
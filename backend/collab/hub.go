@@ -0,0 +1,71 @@
+package collab
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"breadboard-simulator/project"
+)
+
+// Hub keeps a room for every project being edited.
+type Hub struct {
+	// SaveDelay is how long a room waits after a change before saving,
+	// so a burst of operations becomes one revision.
+	SaveDelay time.Duration
+
+	store project.Store
+	mu    sync.Mutex
+	rooms map[string]*Room
+}
+
+// NewHub returns a hub editing the projects in store.
+func NewHub(store project.Store) *Hub {
+	return &Hub{SaveDelay: 2 * time.Second, store: store, rooms: make(map[string]*Room)}
+}
+
+// Join connects a new client to a project's room, opening the room if
+// needed. The client's first message is a snapshot of the project.
+func (h *Hub) Join(projectID string) (*Room, *Client, error) {
+	var b [6]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, nil, err
+	}
+	id := hex.EncodeToString(b[:])
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	room := h.rooms[projectID]
+	if room == nil {
+		p, err := h.store.Get(projectID)
+		if err != nil {
+			return nil, nil, err
+		}
+		room = &Room{hub: h, projectID: projectID, state: p.File.State, clients: make(map[*Client]bool)}
+		h.rooms[projectID] = room
+	}
+	return room, room.join(id), nil
+}
+
+// Leave disconnects a client. When the last client leaves, the room saves
+// any unsaved changes and closes.
+func (h *Hub) Leave(room *Room, c *Client) {
+	// hold the hub while saving, so a client joining meanwhile loads the
+	// saved state rather than opening a room on the old one
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !room.leave(c) {
+		return
+	}
+	room.mu.Lock()
+	if room.saving != nil {
+		room.saving.Stop()
+		room.saving = nil
+	}
+	room.mu.Unlock()
+	room.save()
+	if h.rooms[room.projectID] == room {
+		delete(h.rooms, room.projectID)
+	}
+}
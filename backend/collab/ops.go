@@ -0,0 +1,178 @@
+// Package collab lets several clients edit one project's breadboard at the
+// same time.
+//
+// Clients send small operations (add, move or delete a component, change
+// a property, add or remove a wire) instead of whole states. Each project
+// being edited has a room that applies operations one at a time in the
+// order they arrive, numbering them with the room's version, and
+// broadcasts every applied operation to all clients in the room,
+// including its sender. Clients apply the broadcasts in version order and
+// so all converge on the server's state.
+//
+// Conflicts are resolved by that order: the last move or property change
+// of a component wins, and an operation on something another client has
+// already deleted is rejected back to its sender alone. Deleting what is
+// already gone, or adding a wire that already exists, is accepted as a
+// no-op. Rooms save their project to the store shortly after changes and
+// when the last client leaves.
+package collab
+
+import (
+	"errors"
+	"fmt"
+
+	"breadboard-simulator/breadboard"
+)
+
+// Operation kinds.
+const (
+	AddComponent    = "add_component"
+	MoveComponent   = "move_component"
+	DeleteComponent = "delete_component"
+	SetProperty     = "set_property"
+	AddWire         = "add_wire"
+	RemoveWire      = "remove_wire"
+)
+
+// Op is an edit. Which fields are used depends on Type: Component for
+// add_component; ID and Position for move_component; ID for
+// delete_component; ID, Property and Value for set_property, where a null
+// Value removes the property; Connection for the wire operations. Ref is
+// chosen by the sending client and echoed back so it can match the
+// broadcast or rejection to its request.
+type Op struct {
+	Type       string                 `json:"type"`
+	Ref        string                 `json:"ref,omitempty"`
+	ID         string                 `json:"id,omitempty"`
+	Component  *breadboard.Component  `json:"component,omitempty"`
+	Position   *breadboard.Position   `json:"position,omitempty"`
+	Property   string                 `json:"property,omitempty"`
+	Value      interface{}            `json:"value,omitempty"`
+	Connection *breadboard.Connection `json:"connection,omitempty"`
+}
+
+// ErrConflict wraps the reason an operation was rejected.
+var ErrConflict = errors.New("conflict")
+
+// apply performs op on s. It reports whether s changed; an operation that
+// cannot be applied returns an error wrapping ErrConflict, or a plain
+// error if it is malformed.
+func apply(s *breadboard.State, op Op) (bool, error) {
+	find := func(id string) int {
+		for k, c := range s.Components {
+			if c.ID == id {
+				return k
+			}
+		}
+		return -1
+	}
+
+	switch op.Type {
+	case AddComponent:
+		if op.Component == nil || op.Component.ID == "" || op.Component.Type == "" {
+			return false, fmt.Errorf("add_component needs a component with an id and type")
+		}
+		if find(op.Component.ID) >= 0 {
+			return false, fmt.Errorf("%w: component %s already exists", ErrConflict, op.Component.ID)
+		}
+		s.Components = append(s.Components, *op.Component)
+		return true, nil
+
+	case MoveComponent:
+		if op.Position == nil {
+			return false, fmt.Errorf("move_component needs a position")
+		}
+		k := find(op.ID)
+		if k < 0 {
+			return false, fmt.Errorf("%w: component %s has been deleted", ErrConflict, op.ID)
+		}
+		s.Components[k].Position = *op.Position
+		return true, nil
+
+	case DeleteComponent:
+		k := find(op.ID)
+		if k < 0 {
+			return false, nil
+		}
+		s.Components = append(s.Components[:k], s.Components[k+1:]...)
+		var kept []breadboard.Connection
+		for _, c := range s.Connections {
+			if c.From.ComponentID != op.ID && c.To.ComponentID != op.ID {
+				kept = append(kept, c)
+			}
+		}
+		s.Connections = kept
+		return true, nil
+
+	case SetProperty:
+		if op.Property == "" {
+			return false, fmt.Errorf("set_property needs a property name")
+		}
+		k := find(op.ID)
+		if k < 0 {
+			return false, fmt.Errorf("%w: component %s has been deleted", ErrConflict, op.ID)
+		}
+		c := &s.Components[k]
+		props := make(map[string]interface{}, len(c.Properties)+1)
+		for name, v := range c.Properties {
+			props[name] = v
+		}
+		if op.Value == nil {
+			delete(props, op.Property)
+		} else {
+			props[op.Property] = op.Value
+		}
+		c.Properties = props
+		return true, nil
+
+	case AddWire:
+		if op.Connection == nil {
+			return false, fmt.Errorf("add_wire needs a connection")
+		}
+		for _, end := range []breadboard.Endpoint{op.Connection.From, op.Connection.To} {
+			if !endpointExists(s, end, find) {
+				return false, fmt.Errorf("%w: wire end %+v does not exist", ErrConflict, end)
+			}
+		}
+		if wireIndex(s, *op.Connection) >= 0 {
+			return false, nil
+		}
+		s.Connections = append(s.Connections, *op.Connection)
+		return true, nil
+
+	case RemoveWire:
+		if op.Connection == nil {
+			return false, fmt.Errorf("remove_wire needs a connection")
+		}
+		k := wireIndex(s, *op.Connection)
+		if k < 0 {
+			return false, nil
+		}
+		s.Connections = append(s.Connections[:k], s.Connections[k+1:]...)
+		return true, nil
+	}
+	return false, fmt.Errorf("unknown operation %q", op.Type)
+}
+
+func endpointExists(s *breadboard.State, e breadboard.Endpoint, find func(string) int) bool {
+	if e.CustomPointID != "" {
+		for _, p := range s.CustomConnectionPoints {
+			if p.ID == e.CustomPointID {
+				return true
+			}
+		}
+		return false
+	}
+	return find(e.ComponentID) >= 0
+}
+
+// wireIndex finds a connection joining the same two ends, in either
+// direction.
+func wireIndex(s *breadboard.State, c breadboard.Connection) int {
+	for k, w := range s.Connections {
+		if w.From == c.From && w.To == c.To || w.From == c.To && w.To == c.From {
+			return k
+		}
+	}
+	return -1
+}
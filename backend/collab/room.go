@@ -0,0 +1,183 @@
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"breadboard-simulator/breadboard"
+	"breadboard-simulator/project"
+)
+
+// Message kinds sent to clients.
+const (
+	SnapshotMessage = "snapshot" // the state at Version, sent on joining
+	OpMessage       = "op"       // an applied operation, making Version
+	RejectMessage   = "reject"   // the sender's operation was not applied
+	ErrorMessage    = "error"    // the connection could not be set up
+)
+
+// Message is sent from a room to its clients.
+type Message struct {
+	Type    string            `json:"type"`
+	Version int               `json:"version"`
+	Client  string            `json:"client,omitempty"`
+	Op      *Op               `json:"op,omitempty"`
+	State   *breadboard.State `json:"state,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// outboxSize is how many messages may wait for a slow client before it is
+// disconnected.
+const outboxSize = 256
+
+// Client is a connection to a room. Messages for it arrive on Out, already
+// encoded as JSON; Out is closed when the client leaves or falls too far
+// behind.
+type Client struct {
+	ID  string
+	Out <-chan []byte
+	out chan []byte
+}
+
+// Room is the shared editing state of one project.
+type Room struct {
+	hub       *Hub
+	projectID string
+
+	saveMu  sync.Mutex // held through a save, so saves do not overlap
+	mu      sync.Mutex
+	state   breadboard.State
+	version int
+	clients map[*Client]bool
+	dirty   bool
+	saving  *time.Timer
+}
+
+func (r *Room) send(c *Client, msg Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("collab: encoding message: %v\n", err)
+		return
+	}
+	r.deliver(c, data)
+}
+
+// deliver queues data for c, dropping c if its outbox is full. The caller
+// holds r.mu.
+func (r *Room) deliver(c *Client, data []byte) {
+	select {
+	case c.out <- data:
+	default:
+		log.Printf("collab: client %s in project %s is too slow, disconnecting\n", c.ID, r.projectID)
+		delete(r.clients, c)
+		close(c.out)
+	}
+}
+
+// join adds a client and sends it a snapshot.
+func (r *Room) join(id string) *Client {
+	out := make(chan []byte, outboxSize)
+	c := &Client{ID: id, Out: out, out: out}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients[c] = true
+	state := r.state
+	r.send(c, Message{Type: SnapshotMessage, Version: r.version, State: &state})
+	return c
+}
+
+// Submit applies an operation from c and broadcasts it, or rejects it back
+// to c alone.
+func (r *Room) Submit(c *Client, op Op) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.clients[c] {
+		return
+	}
+
+	changed, err := apply(&r.state, op)
+	if err != nil {
+		r.send(c, Message{Type: RejectMessage, Version: r.version, Op: &op, Error: err.Error()})
+		return
+	}
+	if !changed {
+		// a no-op still answers the sender, at the current version
+		r.send(c, Message{Type: OpMessage, Version: r.version, Client: c.ID, Op: &op})
+		return
+	}
+
+	r.version++
+	data, err := json.Marshal(Message{Type: OpMessage, Version: r.version, Client: c.ID, Op: &op})
+	if err != nil {
+		log.Printf("collab: encoding message: %v\n", err)
+		return
+	}
+	for other := range r.clients {
+		r.deliver(other, data)
+	}
+	r.dirty = true
+	if r.saving == nil {
+		r.saving = time.AfterFunc(r.hub.SaveDelay, r.save)
+	}
+}
+
+// Reject tells c that a message it sent could not be read.
+func (r *Room) Reject(c *Client, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.clients[c] {
+		r.send(c, Message{Type: RejectMessage, Version: r.version, Error: err.Error()})
+	}
+}
+
+// Clients returns the number of clients in the room.
+func (r *Room) Clients() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.clients)
+}
+
+// save writes the state to the store if it has changed.
+func (r *Room) save() {
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+	r.mu.Lock()
+	r.saving = nil
+	if !r.dirty {
+		r.mu.Unlock()
+		return
+	}
+	r.dirty = false
+	data, err := json.Marshal(r.state)
+	r.mu.Unlock()
+	if err != nil {
+		log.Printf("collab: saving project %s: %v\n", r.projectID, err)
+		return
+	}
+
+	// save a copy, so later operations cannot race with the store
+	var state breadboard.State
+	json.Unmarshal(data, &state)
+	if _, err := r.hub.store.Save(r.projectID, state); err != nil {
+		log.Printf("collab: saving project %s: %v\n", r.projectID, err)
+		if !errors.Is(err, project.ErrNotFound) {
+			r.mu.Lock()
+			r.dirty = true
+			r.mu.Unlock()
+		}
+	}
+}
+
+// leave removes c, reporting whether the room is now empty.
+func (r *Room) leave(c *Client) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.clients[c] {
+		delete(r.clients, c)
+		close(c.out)
+	}
+	return len(r.clients) == 0
+}
@@ -0,0 +1,170 @@
+// Package fritzing imports Fritzing projects. A .fzz file is a zip archive
+// holding the sketch as .fz XML together with the definitions (.fzp) of
+// any parts that are not in Fritzing's core library.
+//
+// Only the breadboard view is read. Parts are mapped onto the frontend's
+// part types by their module ID, wires and the breadboard's internal
+// strips become connections between the pins they join, and parts with no
+// simulation model are reported rather than imported.
+package fritzing
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"breadboard-simulator/breadboard"
+	"breadboard-simulator/circuit"
+)
+
+// maxFileSize bounds each file read from an archive, so a small upload
+// cannot expand into an unbounded amount of memory.
+const maxFileSize = 32 << 20
+
+// Part identifies a Fritzing part instance.
+type Part struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	ModuleID string `json:"moduleId"`
+}
+
+// Project is an imported sketch: the layout for the frontend, the circuit
+// it wires up and the parts that were left out.
+type Project struct {
+	FritzingVersion string            `json:"fritzingVersion"`
+	State           *breadboard.State `json:"state"`
+	Circuit         *circuit.Circuit  `json:"circuit"`
+	Unsupported     []Part            `json:"unsupported"`
+}
+
+// ReadFile imports the .fzz archive or bare .fz sketch at name.
+func ReadFile(name string) (*Project, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(path.Ext(name), ".fz") {
+		return ParseSketch(bytes.NewReader(data))
+	}
+	return Read(bytes.NewReader(data), int64(len(data)))
+}
+
+// Read imports a .fzz archive.
+func Read(r io.ReaderAt, size int64) (*Project, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a Fritzing archive: %w", err)
+	}
+
+	var sketch []byte
+	parts := make(map[string]partDefinition)
+	for _, f := range archive.File {
+		switch strings.ToLower(path.Ext(f.Name)) {
+		case ".fz":
+			if sketch != nil {
+				return nil, fmt.Errorf("archive holds more than one sketch")
+			}
+			if sketch, err = readEntry(f); err != nil {
+				return nil, err
+			}
+		case ".fzp":
+			data, err := readEntry(f)
+			if err != nil {
+				return nil, err
+			}
+			var def partDefinition
+			if err := xml.Unmarshal(data, &def); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name, err)
+			}
+			parts[def.ModuleID] = def
+		}
+	}
+	if sketch == nil {
+		return nil, fmt.Errorf("archive holds no .fz sketch")
+	}
+	return parseSketch(bytes.NewReader(sketch), parts)
+}
+
+func readEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	if len(data) > maxFileSize {
+		return nil, fmt.Errorf("%s: larger than %d bytes", f.Name, maxFileSize)
+	}
+	return data, nil
+}
+
+// sketch mirrors the parts of a .fz file the importer reads.
+type sketch struct {
+	FritzingVersion string     `xml:"fritzingVersion,attr"`
+	Instances       []instance `xml:"instances>instance"`
+}
+
+type instance struct {
+	ModuleIDRef string     `xml:"moduleIdRef,attr"`
+	ModelIndex  string     `xml:"modelIndex,attr"`
+	Title       string     `xml:"title"`
+	Properties  []property `xml:"property"`
+	Breadboard  *view      `xml:"views>breadboardView"`
+}
+
+func (in instance) property(name string) (string, bool) {
+	for _, p := range in.Properties {
+		if strings.EqualFold(p.Name, name) {
+			return p.Value, p.Value != ""
+		}
+	}
+	return "", false
+}
+
+type property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type view struct {
+	Geometry   geometry    `xml:"geometry"`
+	Connectors []connector `xml:"connectors>connector"`
+}
+
+type geometry struct {
+	X         float64 `xml:"x,attr"`
+	Y         float64 `xml:"y,attr"`
+	WireFlags int     `xml:"wireFlags,attr"`
+}
+
+type connector struct {
+	ID       string    `xml:"connectorId,attr"`
+	Connects []connect `xml:"connects>connect"`
+}
+
+type connect struct {
+	ConnectorID string `xml:"connectorId,attr"`
+	ModelIndex  string `xml:"modelIndex,attr"`
+}
+
+// partDefinition mirrors the parts of a .fzp file the importer reads.
+type partDefinition struct {
+	ModuleID   string `xml:"moduleId,attr"`
+	Connectors []struct {
+		ID   string `xml:"id,attr"`
+		Name string `xml:"name,attr"`
+	} `xml:"connectors>connector"`
+	Buses []struct {
+		Members []struct {
+			ConnectorID string `xml:"connectorId,attr"`
+		} `xml:"nodeMember"`
+	} `xml:"buses>bus"`
+}
@@ -0,0 +1,177 @@
+package fritzing
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const blink = `<?xml version="1.0" encoding="UTF-8"?>
+<module fritzingVersion="0.9.3b">
+  <instances>
+    <instance moduleIdRef="BreadboardModuleID" modelIndex="1">
+      <title>Breadboard1</title>
+      <views><breadboardView layer="breadboardbreadboard">
+        <geometry x="0" y="0"/>
+        <connectors>
+          <connector connectorId="pin3A"><connects><connect connectorId="connector1" modelIndex="10"/></connects></connector>
+          <connector connectorId="pin3C"><connects><connect connectorId="connector0" modelIndex="2"/></connects></connector>
+          <connector connectorId="pin8C"><connects><connect connectorId="connector1" modelIndex="2"/></connects></connector>
+          <connector connectorId="pin8D"><connects><connect connectorId="connector1" modelIndex="3"/></connects></connector>
+          <connector connectorId="pin12A"><connects><connect connectorId="connector0" modelIndex="3"/></connects></connector>
+          <connector connectorId="pin12E"><connects><connect connectorId="connector0" modelIndex="11"/></connects></connector>
+        </connectors>
+      </breadboardView></views>
+    </instance>
+    <instance moduleIdRef="ResistorModuleID" modelIndex="2">
+      <property name="resistance" value="220Ω"/>
+      <property name="tolerance" value="±5%"/>
+      <title>R1</title>
+      <views><breadboardView><geometry x="120" y="40"/></breadboardView></views>
+    </instance>
+    <instance moduleIdRef="5mmColorLEDModuleID" modelIndex="3">
+      <property name="color" value="Red (633nm)"/>
+      <title>LED1</title>
+      <views><breadboardView><geometry x="200" y="60"/></breadboardView></views>
+    </instance>
+    <instance moduleIdRef="CustomBattery9V" modelIndex="4">
+      <property name="voltage" value="9V"/>
+      <title>VCC1</title>
+      <views><breadboardView>
+        <geometry x="20" y="200"/>
+        <connectors>
+          <connector connectorId="connector0"><connects><connect connectorId="connector1" modelIndex="11"/></connects></connector>
+        </connectors>
+      </breadboardView></views>
+    </instance>
+    <instance moduleIdRef="WireModuleID" modelIndex="10">
+      <title>Wire1</title>
+      <views><breadboardView layer="breadboardWire">
+        <geometry x="0" y="0" wireFlags="64"/>
+        <connectors>
+          <connector connectorId="connector0"><connects><connect connectorId="connector1" modelIndex="4"/></connects></connector>
+        </connectors>
+      </breadboardView></views>
+    </instance>
+    <instance moduleIdRef="WireModuleID" modelIndex="11">
+      <title>Wire2</title>
+      <views><breadboardView layer="breadboardWire"><geometry x="0" y="0" wireFlags="64"/></breadboardView></views>
+    </instance>
+    <instance moduleIdRef="WireModuleID" modelIndex="12">
+      <title>Wire3</title>
+      <views><breadboardView layer="breadboardWire">
+        <geometry x="0" y="0" wireFlags="16"/>
+        <connectors>
+          <connector connectorId="connector0"><connects><connect connectorId="connector0" modelIndex="2"/></connects></connector>
+          <connector connectorId="connector1"><connects><connect connectorId="connector1" modelIndex="2"/></connects></connector>
+        </connectors>
+      </breadboardView></views>
+    </instance>
+    <instance moduleIdRef="arduino_Uno_Rev3" modelIndex="5">
+      <title>Arduino1</title>
+      <views><breadboardView><geometry x="400" y="0"/></breadboardView></views>
+    </instance>
+    <instance moduleIdRef="GroundModuleID" modelIndex="6">
+      <title>GND1</title>
+      <views><schematicView><geometry x="0" y="0"/></schematicView></views>
+    </instance>
+  </instances>
+</module>`
+
+// cell is a part definition shipped in the archive; its connector names
+// put the positive terminal first, unlike the core battery.
+const cell = `<module moduleId="CustomBattery9V">
+  <connectors>
+    <connector id="connector0" name="+"/>
+    <connector id="connector1" name="-"/>
+  </connectors>
+</module>`
+
+func archive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	data := archive(t, map[string]string{
+		"blink.fz":                 blink,
+		"part.CustomBattery9V.fzp": cell,
+	})
+	project, err := Read(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.FritzingVersion != "0.9.3b" {
+		t.Errorf("version = %q", project.FritzingVersion)
+	}
+	if len(project.Unsupported) != 1 || project.Unsupported[0].ID != "Arduino1" {
+		t.Errorf("unsupported = %+v", project.Unsupported)
+	}
+	if len(project.State.Components) != 3 {
+		t.Fatalf("components = %+v", project.State.Components)
+	}
+	r1 := project.State.Components[0]
+	if r1.ID != "R1" || r1.Properties["resistance"] != 220.0 || r1.Properties["tolerance"] != 5.0 {
+		t.Errorf("R1 = %+v", r1)
+	}
+	if r1.Position.X != 6 || r1.Position.Y != 1 {
+		t.Errorf("R1 position = %+v", r1.Position)
+	}
+
+	nodes := make(map[string][]string)
+	for _, comp := range project.Circuit.Components {
+		nodes[comp.ID] = comp.Nodes
+	}
+	// by its definition the cell's connector0 is "+", which Wire2 takes to
+	// the LED cathode
+	if nodes["VCC1"][0] != nodes["LED1"][1] {
+		t.Errorf("nodes = %v", nodes)
+	}
+}
+
+func TestReadCircuit(t *testing.T) {
+	sketch := strings.ReplaceAll(blink, "CustomBattery9V", "Battery9VModuleID")
+	data := archive(t, map[string]string{"blink.fz": sketch})
+	project, err := Read(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(project.Circuit.Components) != 3 {
+		t.Fatalf("circuit = %+v", project.Circuit.Components)
+	}
+	nodes := make(map[string][]string)
+	for _, comp := range project.Circuit.Components {
+		nodes[comp.ID] = comp.Nodes
+	}
+	// the core battery's connector1 is "+", wired through Wire1 and the
+	// column 3 strip to R1; the LED cathode reaches "-" through Wire2
+	if nodes["VCC1"][0] != nodes["R1"][0] || nodes["VCC1"][1] != "0" || nodes["LED1"][1] != "0" {
+		t.Errorf("nodes = %v", nodes)
+	}
+	if nodes["R1"][1] != nodes["LED1"][0] {
+		t.Errorf("R1 and LED1 not joined by the column 8 strip: %v", nodes)
+	}
+}
+
+func TestReadRejectsNonArchive(t *testing.T) {
+	data := []byte(blink)
+	if _, err := Read(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Error("Read accepted bare XML as an archive")
+	}
+	if _, err := ParseSketch(bytes.NewReader(data)); err != nil {
+		t.Errorf("ParseSketch: %v", err)
+	}
+}
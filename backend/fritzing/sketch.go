@@ -0,0 +1,332 @@
+package fritzing
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"breadboard-simulator/breadboard"
+	"breadboard-simulator/circuit"
+)
+
+// ratsnestFlag marks an unrouted connection line; it shows a connection
+// made in another view, not a wire on the breadboard.
+const ratsnestFlag = 16
+
+// kind is how a Fritzing part is treated on import.
+type kind struct {
+	match string // substring of the lower-cased module ID
+	typ   string // frontend part type; "" for wires and breadboards
+	pins  int
+}
+
+// kinds is checked in order, so more specific matches come first.
+var kinds = []kind{
+	{"wire", "", 0},
+	{"breadboard", "", 0},
+	{"resistor", "resistor", 2},
+	{"capacitor", "capacitor", 2},
+	{"inductor", "inductor", 2},
+	{"led", "led", 2},
+	{"diode", "diode", 2},
+	{"1n4", "diode", 2},
+	{"battery", "battery", 2},
+	{"powersupply", "power_supply", 2},
+	{"power_supply", "power_supply", 2},
+	{"transistor", "transistor", 3},
+	{"npn", "transistor", 3},
+	{"pnp", "transistor", 3},
+}
+
+// defaultPins gives the pin index of each connector for core parts whose
+// definitions are not in the archive. Pin 0 is the positive terminal, as
+// the netlister expects; unlisted connectors "connectorN" are pin N.
+var defaultPins = map[string]map[string]int{
+	"led":        {"connector0": 1, "connector1": 0}, // cathode, anode
+	"diode":      {"connector0": 1, "connector1": 0},
+	"battery":    {"connector0": 1, "connector1": 0}, // -, +
+	"transistor": {"connector0": 2, "connector1": 1, "connector2": 0},
+}
+
+// pinNames orders connectors by the name a part definition gives them.
+var pinNames = map[string]int{
+	"+": 0, "anode": 0, "positive": 0, "collector": 0, "c": 0, "drain": 0,
+	"-": 1, "cathode": 1, "negative": 1, "base": 1, "b": 1, "gate": 1,
+	"emitter": 2, "e": 2, "source": 2,
+}
+
+// defaults are the values given to parts whose sketch leaves them unset,
+// matching Fritzing's own part defaults, in the frontend's units.
+var defaults = map[string]map[string]interface{}{
+	"resistor":     {"resistance": 220.0},
+	"capacitor":    {"capacitance": 0.1, "capacitorType": "Ceramic"},
+	"inductor":     {"inductance": 1e-3},
+	"diode":        {"forwardVoltage": 0.7, "maxCurrent": 1000.0},
+	"led":          {"forwardVoltage": 2.0, "maxCurrent": 20.0, "color": "Red"},
+	"battery":      {"voltage": 9.0},
+	"power_supply": {"voltage": 5.0},
+	"transistor":   {"transistorType": "NPN", "gain": 100.0},
+}
+
+// valueProperties maps Fritzing property names onto the frontend's, with
+// the factor from SI to the frontend's unit.
+var valueProperties = map[string][]struct {
+	from, to string
+	scale    float64
+}{
+	"resistor":     {{"resistance", "resistance", 1}, {"power", "powerRating", 1}, {"tolerance", "tolerance", 1}},
+	"capacitor":    {{"capacitance", "capacitance", 1e6}, {"voltage", "voltageRating", 1}},
+	"inductor":     {{"inductance", "inductance", 1}, {"current", "currentRating", 1}},
+	"battery":      {{"voltage", "voltage", 1}},
+	"power_supply": {{"voltage", "voltage", 1}, {"current", "maxCurrent", 1}},
+}
+
+// ParseSketch imports a bare .fz sketch, using the core library's pin
+// order for every part.
+func ParseSketch(r io.Reader) (*Project, error) {
+	return parseSketch(r, nil)
+}
+
+// parseSketch imports a sketch. parts holds the definitions found in its
+// archive, keyed by module ID.
+func parseSketch(r io.Reader, parts map[string]partDefinition) (*Project, error) {
+	var sk sketch
+	if err := xml.NewDecoder(r).Decode(&sk); err != nil {
+		return nil, fmt.Errorf("reading sketch: %w", err)
+	}
+
+	project := &Project{FritzingVersion: sk.FritzingVersion, State: &breadboard.State{}}
+	nets := make(unionFind)
+	type placed struct {
+		comp       *breadboard.Component
+		modelIndex string
+		pins       map[string]int
+		x, y       float64
+	}
+	var board []placed
+	usedIDs := make(map[string]bool)
+
+	for _, in := range sk.Instances {
+		if in.Breadboard == nil {
+			continue // schematic- or PCB-only, such as a ground symbol
+		}
+		k, ok := classify(in.ModuleIDRef)
+		def, hasDef := parts[in.ModuleIDRef]
+
+		for _, conn := range in.Breadboard.Connectors {
+			for _, other := range conn.Connects {
+				nets.union(pinKey(in.ModelIndex, conn.ID), pinKey(other.ModelIndex, other.ConnectorID))
+			}
+		}
+		if hasDef {
+			for _, bus := range def.Buses {
+				for _, member := range bus.Members {
+					nets.union(pinKey(in.ModelIndex, bus.Members[0].ConnectorID), pinKey(in.ModelIndex, member.ConnectorID))
+				}
+			}
+		}
+
+		switch {
+		case ok && k.match == "wire":
+			if in.Breadboard.Geometry.WireFlags&ratsnestFlag == 0 {
+				nets.union(pinKey(in.ModelIndex, "connector0"), pinKey(in.ModelIndex, "connector1"))
+			}
+			continue
+		case ok && k.match == "breadboard":
+			if !hasDef {
+				for _, conn := range in.Breadboard.Connectors {
+					if strip := breadboardStrip(conn.ID); strip != "" {
+						nets.union(pinKey(in.ModelIndex, conn.ID), pinKey(in.ModelIndex, strip))
+					}
+				}
+			}
+			continue
+		}
+
+		id := uniqueID(in, usedIDs)
+		if !ok {
+			project.Unsupported = append(project.Unsupported, Part{ID: id, Title: in.Title, ModuleID: in.ModuleIDRef})
+			continue
+		}
+		props, err := properties(k.typ, in)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", id, err)
+		}
+		comp := &breadboard.Component{
+			ID:               id,
+			Type:             k.typ,
+			Properties:       props,
+			ConnectionPoints: breadboard.DefaultConnectionPoints(k.pins),
+		}
+		board = append(board, placed{comp, in.ModelIndex, pinMap(k, in, def, hasDef), in.Breadboard.Geometry.X, in.Breadboard.Geometry.Y})
+	}
+
+	// positions are kept relative to the top-left part
+	minX, minY := math.Inf(1), math.Inf(1)
+	for _, p := range board {
+		minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+	}
+	for _, p := range board {
+		p.comp.Position = breadboard.Position{
+			X: 1 + math.Round((p.x-minX)/breadboard.GridSize),
+			Y: 1 + math.Round((p.y-minY)/breadboard.GridSize),
+		}
+		project.State.Components = append(project.State.Components, *p.comp)
+	}
+
+	// join the pins on each net with a chain of connections
+	first := make(map[string]breadboard.Endpoint)
+	for _, p := range board {
+		connectors := make([]string, 0, len(p.pins))
+		for conn := range p.pins {
+			connectors = append(connectors, conn)
+		}
+		sort.Slice(connectors, func(a, b int) bool { return p.pins[connectors[a]] < p.pins[connectors[b]] })
+		for _, conn := range connectors {
+			root := nets.find(pinKey(p.modelIndex, conn))
+			end := breadboard.Endpoint{ComponentID: p.comp.ID, PointIndex: p.pins[conn]}
+			if prev, ok := first[root]; ok {
+				project.State.Connections = append(project.State.Connections, breadboard.Connection{From: prev, To: end})
+			} else {
+				first[root] = end
+			}
+		}
+	}
+
+	c, _, err := project.State.Netlist()
+	if err != nil {
+		return nil, err
+	}
+	project.Circuit = c
+	return project, nil
+}
+
+func classify(moduleID string) (kind, bool) {
+	lower := strings.ToLower(moduleID)
+	for _, k := range kinds {
+		if strings.Contains(lower, k.match) {
+			return k, true
+		}
+	}
+	return kind{}, false
+}
+
+// uniqueID names a part after its title, R1 or LED2, falling back to the
+// model index when titles repeat.
+func uniqueID(in instance, used map[string]bool) string {
+	id := in.Title
+	if id == "" || used[id] {
+		id = fmt.Sprintf("%s-%s", strings.ToLower(strings.TrimSuffix(in.ModuleIDRef, "ModuleID")), in.ModelIndex)
+	}
+	used[id] = true
+	return id
+}
+
+func properties(typ string, in instance) (map[string]interface{}, error) {
+	props := make(map[string]interface{})
+	for key, v := range defaults[typ] {
+		props[key] = v
+	}
+	for _, p := range valueProperties[typ] {
+		raw, ok := in.property(p.from)
+		if !ok {
+			continue
+		}
+		v, err := circuit.ParseValue(strings.Trim(raw, " ±%"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.from, err)
+		}
+		props[p.to] = v * p.scale
+	}
+
+	lower := strings.ToLower(in.ModuleIDRef)
+	switch typ {
+	case "capacitor":
+		if strings.Contains(lower, "electrolytic") {
+			props["capacitorType"] = "Electrolytic"
+		}
+	case "led":
+		if color, ok := in.property("color"); ok {
+			props["color"] = color
+		}
+	case "transistor":
+		if t, ok := in.property("type"); ok && strings.Contains(strings.ToLower(t), "pnp") || strings.Contains(lower, "pnp") {
+			props["transistorType"] = "PNP"
+		}
+	}
+	return props, nil
+}
+
+// pinMap gives the frontend pin index of each of a part's connectors.
+func pinMap(k kind, in instance, def partDefinition, hasDef bool) map[string]int {
+	pins := make(map[string]int)
+	if hasDef {
+		for _, conn := range def.Connectors {
+			if pin, ok := pinNames[strings.ToLower(conn.Name)]; ok && pin < k.pins {
+				pins[conn.ID] = pin
+			}
+		}
+		if len(pins) == k.pins {
+			return pins
+		}
+		pins = make(map[string]int)
+	}
+	for pin := 0; pin < k.pins; pin++ {
+		conn := "connector" + strconv.Itoa(pin)
+		if mapped, ok := defaultPins[k.typ][conn]; ok {
+			pins[conn] = mapped
+		} else {
+			pins[conn] = pin
+		}
+	}
+	return pins
+}
+
+var stripPattern = regexp.MustCompile(`^pin(\d+)([A-Za-z])$`)
+
+// breadboardStrip names the strip a core breadboard socket belongs to:
+// rows A-E and F-J of each column are joined, and the W, X, Y and Z power
+// rails run the length of the board.
+func breadboardStrip(connectorID string) string {
+	m := stripPattern.FindStringSubmatch(connectorID)
+	if m == nil {
+		return ""
+	}
+	switch row := strings.ToUpper(m[2]); {
+	case row >= "A" && row <= "E":
+		return "strip" + m[1] + "top"
+	case row >= "F" && row <= "J":
+		return "strip" + m[1] + "bottom"
+	case row >= "W" && row <= "Z":
+		return "rail" + row
+	}
+	return ""
+}
+
+func pinKey(modelIndex, connectorID string) string {
+	return modelIndex + ":" + connectorID
+}
+
+type unionFind map[string]string
+
+func (u unionFind) find(x string) string {
+	parent, ok := u[x]
+	if !ok || parent == x {
+		return x
+	}
+	root := u.find(parent)
+	u[x] = root
+	return root
+}
+
+func (u unionFind) union(a, b string) {
+	ra, rb := u.find(a), u.find(b)
+	if ra != rb {
+		u[ra] = rb
+	}
+}
@@ -5,7 +5,12 @@ go 1.22
 toolchain go1.22.6
 
 require (
-	github.com/go-resty/resty/v2 v2.14.0 // indirect
-	golang.org/x/net v0.27.0 // indirect
-	gonum.org/v1/gonum v0.15.1 // indirect
+	go.etcd.io/bbolt v1.3.11
+	golang.org/x/net v0.27.0
+	gonum.org/v1/gonum v0.15.1
+)
+
+require (
+	golang.org/x/sync v0.7.0 // indirect
+	golang.org/x/sys v0.22.0 // indirect
 )
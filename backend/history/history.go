@@ -0,0 +1,201 @@
+// Package history keeps the revisions of a breadboard as it is edited.
+//
+// Every save commits a revision whose parent is the revision being
+// edited, the head. Undo moves the head back to its parent and redo
+// forward again; checking out an older revision and saving from there
+// starts a branch, so no revision is ever lost by editing. Reverting
+// commits a copy of an old revision on top of the head instead.
+package history
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"breadboard-simulator/breadboard"
+)
+
+// DefaultLimit is the number of revisions a History keeps when its Limit
+// is zero.
+const DefaultLimit = 100
+
+// Errors returned when there is nothing to move the head to.
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+	ErrNoRevision    = errors.New("no such revision")
+)
+
+// Revision is one saved state of the breadboard. Parent is the revision
+// it was made from, or 0 for the first one; Message says how it was made
+// when that was not an ordinary save.
+type Revision struct {
+	Number  int              `json:"number"`
+	Parent  int              `json:"parent"`
+	Created time.Time        `json:"created"`
+	Message string           `json:"message,omitempty"`
+	State   breadboard.State `json:"state"`
+}
+
+// Summary describes a revision without its state.
+type Summary struct {
+	Number     int       `json:"number"`
+	Parent     int       `json:"parent"`
+	Created    time.Time `json:"created"`
+	Message    string    `json:"message,omitempty"`
+	Components int       `json:"components"`
+	Head       bool      `json:"head,omitempty"`
+}
+
+// History is the revision graph of one breadboard. Its zero value is an
+// empty history. When more than Limit revisions accumulate, the oldest
+// are dropped and revisions whose parent was dropped become roots.
+type History struct {
+	Revisions []Revision `json:"revisions"`
+	Head      int        `json:"head"`
+	// RedoStack holds the revisions undone from the head, most recent
+	// last; any commit clears it.
+	RedoStack []int `json:"redo,omitempty"`
+	Limit     int   `json:"limit,omitempty"`
+	// Next is the number the next revision gets.
+	Next int `json:"next"`
+}
+
+// Get returns revision n.
+func (h *History) Get(n int) (*Revision, error) {
+	for k := range h.Revisions {
+		if h.Revisions[k].Number == n {
+			return &h.Revisions[k], nil
+		}
+	}
+	return nil, fmt.Errorf("%w %d", ErrNoRevision, n)
+}
+
+// Current returns the head revision, or nil for an empty history.
+func (h *History) Current() *Revision {
+	r, err := h.Get(h.Head)
+	if err != nil {
+		return nil
+	}
+	return r
+}
+
+// List summarizes the revisions, oldest first.
+func (h *History) List() []Summary {
+	list := make([]Summary, len(h.Revisions))
+	for k, r := range h.Revisions {
+		list[k] = Summary{r.Number, r.Parent, r.Created, r.Message, len(r.State.Components), r.Number == h.Head}
+	}
+	return list
+}
+
+// Commit records state as a child of the head and makes it the head. A
+// state identical to the head's is not recorded again; Commit returns the
+// head and false.
+func (h *History) Commit(state breadboard.State, t time.Time, message string) (*Revision, bool) {
+	if cur := h.Current(); cur != nil && breadboard.Compare(cur.State, state).Empty() {
+		return cur, false
+	}
+	if h.Next < 1 {
+		h.Next = 1
+	}
+	h.Revisions = append(h.Revisions, Revision{
+		Number:  h.Next,
+		Parent:  h.Head,
+		Created: t,
+		Message: message,
+		State:   state,
+	})
+	h.Head = h.Next
+	h.Next++
+	h.RedoStack = nil
+	h.prune()
+	return h.Current(), true
+}
+
+// prune drops the oldest revisions beyond the limit, never the head.
+func (h *History) prune() {
+	limit := h.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if len(h.Revisions) <= limit {
+		return
+	}
+	dropped := make(map[int]bool)
+	var kept []Revision
+	excess := len(h.Revisions) - limit
+	for _, r := range h.Revisions {
+		if excess > 0 && r.Number != h.Head {
+			dropped[r.Number] = true
+			excess--
+			continue
+		}
+		kept = append(kept, r)
+	}
+	for k := range kept {
+		if dropped[kept[k].Parent] {
+			kept[k].Parent = 0
+		}
+	}
+	h.Revisions = kept
+
+	var redo []int
+	for _, n := range h.RedoStack {
+		if !dropped[n] {
+			redo = append(redo, n)
+		}
+	}
+	h.RedoStack = redo
+}
+
+// Undo moves the head to its parent.
+func (h *History) Undo() (*Revision, error) {
+	cur := h.Current()
+	if cur == nil || cur.Parent == 0 {
+		return nil, ErrNothingToUndo
+	}
+	parent, err := h.Get(cur.Parent)
+	if err != nil {
+		return nil, ErrNothingToUndo
+	}
+	h.RedoStack = append(h.RedoStack, h.Head)
+	h.Head = parent.Number
+	return parent, nil
+}
+
+// Redo moves the head forward to the revision last undone.
+func (h *History) Redo() (*Revision, error) {
+	if len(h.RedoStack) == 0 {
+		return nil, ErrNothingToRedo
+	}
+	n := h.RedoStack[len(h.RedoStack)-1]
+	h.RedoStack = h.RedoStack[:len(h.RedoStack)-1]
+	r, err := h.Get(n)
+	if err != nil {
+		return nil, ErrNothingToRedo
+	}
+	h.Head = n
+	return r, nil
+}
+
+// Checkout makes revision n the head; the next commit branches from it.
+func (h *History) Checkout(n int) (*Revision, error) {
+	r, err := h.Get(n)
+	if err != nil {
+		return nil, err
+	}
+	h.Head = n
+	h.RedoStack = nil
+	return r, nil
+}
+
+// Revert commits a copy of revision n's state on top of the head.
+func (h *History) Revert(n int, t time.Time) (*Revision, error) {
+	r, err := h.Get(n)
+	if err != nil {
+		return nil, err
+	}
+	rev, _ := h.Commit(r.State, t, fmt.Sprintf("revert to revision %d", n))
+	return rev, nil
+}
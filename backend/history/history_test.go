@@ -0,0 +1,139 @@
+package history
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"breadboard-simulator/breadboard"
+)
+
+func board(ids ...string) breadboard.State {
+	var s breadboard.State
+	for _, id := range ids {
+		s.Components = append(s.Components, breadboard.Component{ID: id, Type: "resistor"})
+	}
+	return s
+}
+
+func ids(s breadboard.State) string {
+	var out string
+	for _, c := range s.Components {
+		out += c.ID
+	}
+	return out
+}
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestUndoRedo(t *testing.T) {
+	var h History
+	if _, err := h.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("undo on empty history: %v", err)
+	}
+	h.Commit(board("a"), t0, "")
+	h.Commit(board("a", "b"), t0, "")
+	h.Commit(board("a", "b", "c"), t0, "")
+	if _, added := h.Commit(board("a", "b", "c"), t0, ""); added {
+		t.Error("an unchanged state was committed")
+	}
+
+	r, err := h.Undo()
+	if err != nil || ids(r.State) != "ab" {
+		t.Fatalf("undo = %v, %v", r, err)
+	}
+	r, _ = h.Undo()
+	if ids(r.State) != "a" {
+		t.Fatalf("second undo = %s", ids(r.State))
+	}
+	if _, err := h.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("undo past the first revision: %v", err)
+	}
+	r, _ = h.Redo()
+	if ids(r.State) != "ab" {
+		t.Fatalf("redo = %s", ids(r.State))
+	}
+
+	// a new commit after undoing branches and clears redo
+	r, _ = h.Commit(board("a", "b", "x"), t0, "")
+	if r.Parent != 2 || r.Number != 4 {
+		t.Errorf("branch = %+v", r)
+	}
+	if _, err := h.Redo(); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("redo after a commit: %v", err)
+	}
+	if len(h.Revisions) != 4 {
+		t.Errorf("%d revisions, want all 4 kept", len(h.Revisions))
+	}
+}
+
+func TestCheckoutAndRevert(t *testing.T) {
+	var h History
+	h.Commit(board("a"), t0, "")
+	h.Commit(board("a", "b"), t0, "")
+
+	r, err := h.Revert(1, t0)
+	if err != nil || r.Number != 3 || r.Parent != 2 || ids(r.State) != "a" {
+		t.Fatalf("revert = %+v, %v", r, err)
+	}
+	if r.Message != "revert to revision 1" {
+		t.Errorf("message = %q", r.Message)
+	}
+
+	if _, err := h.Checkout(2); err != nil {
+		t.Fatal(err)
+	}
+	r, _ = h.Commit(board("a", "b", "c"), t0, "")
+	if r.Parent != 2 {
+		t.Errorf("commit after checkout has parent %d, want 2", r.Parent)
+	}
+	if _, err := h.Checkout(99); !errors.Is(err, ErrNoRevision) {
+		t.Errorf("checkout 99: %v", err)
+	}
+
+	heads := 0
+	for _, s := range h.List() {
+		if s.Head {
+			heads++
+			if s.Number != 4 {
+				t.Errorf("head = %d", s.Number)
+			}
+		}
+	}
+	if heads != 1 {
+		t.Errorf("%d heads listed", heads)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	h := History{Limit: 3}
+	for _, s := range []string{"a", "b", "c", "d", "e"} {
+		h.Commit(board(s), t0, "")
+	}
+	if len(h.Revisions) != 3 || h.Revisions[0].Number != 3 {
+		t.Fatalf("revisions = %+v", h.List())
+	}
+	if h.Revisions[0].Parent != 0 {
+		t.Errorf("oldest kept revision still points at dropped parent %d", h.Revisions[0].Parent)
+	}
+	h.Undo()
+	h.Undo()
+	if _, err := h.Undo(); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("undo into dropped revisions: %v", err)
+	}
+}
+
+func TestCommitParams(t *testing.T) {
+	var h History
+	h.Commit(board("a"), t0, "")
+	state := board("a")
+	state.Params = map[string]interface{}{"VCC": 5.0}
+	if _, added := h.Commit(state, t0, ""); !added {
+		t.Fatal("setting a parameter was not committed")
+	}
+	state = board("a")
+	state.Params = map[string]interface{}{"VCC": 9.0}
+	if r, added := h.Commit(state, t0, ""); !added || r.State.Params["VCC"] != 9.0 {
+		t.Errorf("changing a parameter: %v, %v", r, added)
+	}
+}
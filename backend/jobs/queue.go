@@ -0,0 +1,345 @@
+// Package jobs runs long simulations in the background. A job is submitted
+// to a Queue, which runs it on a bounded pool of workers under a time
+// limit; its status and progress can be polled, it can be cancelled, and
+// once finished its result is kept for a while before being discarded.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for a job the queue does not have, or no
+	// longer has.
+	ErrNotFound = errors.New("job not found")
+	// ErrQueueFull is returned by Submit when MaxQueued jobs are waiting.
+	ErrQueueFull = errors.New("too many jobs are waiting")
+	// ErrNotFinished is returned for the result of a job still waiting or
+	// running.
+	ErrNotFinished = errors.New("job has not finished")
+	// ErrClosed is returned by Submit after Close.
+	ErrClosed = errors.New("job queue is closed")
+)
+
+// State is where a job is in its life.
+type State string
+
+const (
+	Queued    State = "queued"
+	Running   State = "running"
+	Done      State = "done"
+	Failed    State = "failed"
+	Cancelled State = "cancelled"
+)
+
+// Finished reports whether a job in state s will not change any more.
+func (s State) Finished() bool {
+	return s == Done || s == Failed || s == Cancelled
+}
+
+// Func is the work of a job. It should return soon after ctx is done, and
+// may report how far it has got through progress as a fraction from 0 to
+// 1.
+type Func func(ctx context.Context, progress func(done float64)) (interface{}, error)
+
+// Job describes a job. Expires is set once it has finished.
+type Job struct {
+	ID       string     `json:"id"`
+	Kind     string     `json:"kind"`
+	State    State      `json:"state"`
+	Progress float64    `json:"progress"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+}
+
+// Options configure a Queue. Zero values take the defaults.
+type Options struct {
+	// Workers is how many jobs run at once, 1 by default.
+	Workers int
+	// MaxQueued bounds the jobs waiting for a worker, 100 by default.
+	MaxQueued int
+	// Timeout limits how long a job may run, 5 minutes by default. A job
+	// that runs out of time fails with context.DeadlineExceeded.
+	Timeout time.Duration
+	// TTL is how long a finished job and its result are kept, an hour by
+	// default.
+	TTL time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	if o.Workers <= 0 {
+		o.Workers = 1
+	}
+	if o.MaxQueued <= 0 {
+		o.MaxQueued = 100
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Minute
+	}
+	if o.TTL <= 0 {
+		o.TTL = time.Hour
+	}
+	return o
+}
+
+type entry struct {
+	Job
+	fn     Func
+	result interface{}
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Queue runs jobs on a pool of workers.
+type Queue struct {
+	opts    Options
+	now     func() time.Time
+	pending chan *entry
+	ctx     context.Context
+	stop    context.CancelFunc
+	workers sync.WaitGroup
+
+	mu     sync.Mutex
+	jobs   map[string]*entry
+	closed bool
+}
+
+// NewQueue starts a queue's workers.
+func NewQueue(opts Options) *Queue {
+	opts = opts.withDefaults()
+	q := &Queue{
+		opts:    opts,
+		now:     time.Now,
+		pending: make(chan *entry, opts.MaxQueued),
+		jobs:    make(map[string]*entry),
+	}
+	q.ctx, q.stop = context.WithCancel(context.Background())
+	for k := 0; k < opts.Workers; k++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Submit queues fn to run as a job of the given kind.
+func (q *Queue) Submit(kind string, fn Func) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return Job{}, ErrClosed
+	}
+	e := &entry{
+		Job: Job{ID: newID(), Kind: kind, State: Queued, Created: q.now()},
+		fn:  fn,
+	}
+	e.ctx, e.cancel = context.WithCancel(q.ctx)
+	select {
+	case q.pending <- e:
+	default:
+		e.cancel()
+		return Job{}, ErrQueueFull
+	}
+	q.jobs[e.ID] = e
+	return e.Job, nil
+}
+
+// Get returns a job's status.
+func (q *Queue) Get(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e, ok := q.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return e.Job, nil
+}
+
+// List returns every job the queue holds, newest first.
+func (q *Queue) List() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	list := make([]Job, 0, len(q.jobs))
+	for _, e := range q.jobs {
+		list = append(list, e.Job)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.After(list[j].Created) })
+	return list
+}
+
+// Result returns the result of a job that is done. For a job that failed
+// or was cancelled it returns the job's error.
+func (q *Queue) Result(id string) (interface{}, Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e, ok := q.jobs[id]
+	switch {
+	case !ok:
+		return nil, Job{}, ErrNotFound
+	case !e.State.Finished():
+		return nil, e.Job, ErrNotFinished
+	case e.State != Done:
+		return nil, e.Job, errors.New(e.Error)
+	}
+	return e.result, e.Job, nil
+}
+
+// Cancel stops a job. A waiting job is cancelled at once; a running one
+// once its Func returns. Cancelling a finished job changes nothing.
+func (q *Queue) Cancel(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	e, ok := q.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	e.cancel()
+	if e.State == Queued {
+		q.finish(e, nil, context.Canceled)
+	}
+	return e.Job, nil
+}
+
+// Expire discards the jobs whose results have been kept for TTL and
+// reports how many there were.
+func (q *Queue) Expire() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.now()
+	n := 0
+	for id, e := range q.jobs {
+		if e.Expires != nil && !now.Before(*e.Expires) {
+			delete(q.jobs, id)
+			n++
+		}
+	}
+	return n
+}
+
+// Cleanup expires jobs every interval until ctx is done.
+func (q *Queue) Cleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := q.Expire(); n > 0 {
+				log.Printf("Expired %d finished jobs\n", n)
+			}
+		}
+	}
+}
+
+// Close cancels every job and waits for the running ones to return. Jobs
+// still waiting fail with ErrClosed.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.stop()
+	q.workers.Wait()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		select {
+		case e := <-q.pending:
+			if e.State == Queued {
+				q.finish(e, nil, ErrClosed)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case e := <-q.pending:
+			q.run(e)
+		}
+	}
+}
+
+func (q *Queue) run(e *entry) {
+	q.mu.Lock()
+	if e.State != Queued {
+		// cancelled while waiting
+		q.mu.Unlock()
+		return
+	}
+	if q.closed {
+		// taken from the queue as it closed
+		q.finish(e, nil, ErrClosed)
+		q.mu.Unlock()
+		return
+	}
+	started := q.now()
+	e.State, e.Started = Running, &started
+	q.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(e.ctx, q.opts.Timeout)
+	defer cancel()
+	result, err := q.call(ctx, e)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	q.finish(e, result, err)
+}
+
+// call runs a job's Func, turning a panic into the job's error.
+func (q *Queue) call(ctx context.Context, e *entry) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return e.fn(ctx, func(done float64) {
+		q.mu.Lock()
+		if done > e.Progress && done <= 1 {
+			e.Progress = done
+		}
+		q.mu.Unlock()
+	})
+}
+
+// finish records how a job ended. q.mu must be held.
+func (q *Queue) finish(e *entry, result interface{}, err error) {
+	now := q.now()
+	expires := now.Add(q.opts.TTL)
+	e.Finished, e.Expires = &now, &expires
+	switch {
+	case err == nil:
+		e.State, e.Progress, e.result = Done, 1, result
+	case errors.Is(err, context.Canceled):
+		e.State, e.Error = Cancelled, err.Error()
+	default:
+		e.State, e.Error = Failed, err.Error()
+	}
+	e.cancel()
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,156 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func wait(t *testing.T, q *Queue, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := q.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.State.Finished() {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Job{}
+}
+
+// blocker is a job that runs until released or cancelled.
+func blocker(release <-chan struct{}) Func {
+	return func(ctx context.Context, progress func(float64)) (interface{}, error) {
+		progress(0.5)
+		select {
+		case <-release:
+			return "released", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func TestQueue(t *testing.T) {
+	q := NewQueue(Options{Workers: 1, MaxQueued: 2})
+	defer q.Close()
+
+	release := make(chan struct{})
+	first, err := q.Submit("test", blocker(release))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.State != Queued || first.ID == "" {
+		t.Fatalf("submitted job = %+v", first)
+	}
+	if _, _, err := q.Result(first.ID); !errors.Is(err, ErrNotFinished) {
+		t.Errorf("result of a waiting job: %v", err)
+	}
+
+	// the only worker is busy: the next job waits and can be cancelled
+	for {
+		if job, _ := q.Get(first.ID); job.State == Running && job.Progress == 0.5 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	second, _ := q.Submit("test", blocker(release))
+	third, _ := q.Submit("test", blocker(release))
+	if _, err := q.Submit("test", blocker(release)); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("submitting to a full queue: %v", err)
+	}
+	if job, err := q.Cancel(second.ID); err != nil || job.State != Cancelled {
+		t.Errorf("cancelling a waiting job: %+v, %v", job, err)
+	}
+
+	release <- struct{}{}
+	if job := wait(t, q, first.ID); job.State != Done || job.Progress != 1 || job.Expires == nil {
+		t.Errorf("first job = %+v", job)
+	}
+	if result, _, err := q.Result(first.ID); err != nil || result != "released" {
+		t.Errorf("result = %v, %v", result, err)
+	}
+
+	// the cancelled job is skipped and the third runs
+	for {
+		if job, _ := q.Get(third.ID); job.State == Running {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	q.Cancel(third.ID)
+	if job := wait(t, q, third.ID); job.State != Cancelled {
+		t.Errorf("third job = %+v", job)
+	}
+	if _, _, err := q.Result(third.ID); err == nil {
+		t.Error("a cancelled job has a result")
+	}
+	if n := len(q.List()); n != 3 {
+		t.Errorf("%d jobs listed, want 3", n)
+	}
+	if _, err := q.Get("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of an unknown job: %v", err)
+	}
+}
+
+func TestQueueTimeoutAndExpiry(t *testing.T) {
+	q := NewQueue(Options{Timeout: 10 * time.Millisecond, TTL: time.Hour})
+	defer q.Close()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+
+	slow, _ := q.Submit("slow", blocker(nil))
+	if job := wait(t, q, slow.ID); job.State != Failed || job.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("timed out job = %+v", job)
+	}
+	panicky, _ := q.Submit("panic", func(context.Context, func(float64)) (interface{}, error) {
+		panic("boom")
+	})
+	if job := wait(t, q, panicky.ID); job.State != Failed {
+		t.Errorf("panicking job = %+v", job)
+	}
+
+	if n := q.Expire(); n != 0 {
+		t.Errorf("expired %d jobs early", n)
+	}
+	now = now.Add(time.Hour)
+	if n := q.Expire(); n != 2 {
+		t.Errorf("expired %d jobs, want 2", n)
+	}
+	if _, err := q.Get(slow.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired job: %v", err)
+	}
+
+	q.Close()
+	if _, err := q.Submit("late", blocker(nil)); !errors.Is(err, ErrClosed) {
+		t.Errorf("submitting after Close: %v", err)
+	}
+}
+
+func TestCloseFinishesWaitingJobs(t *testing.T) {
+	q := NewQueue(Options{Workers: 1})
+	release := make(chan struct{})
+	running, _ := q.Submit("op", blocker(release))
+	waiting, err := q.Submit("op", blocker(release))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for job, _ := q.Get(running.ID); job.State != Running; job, _ = q.Get(running.ID) {
+		time.Sleep(time.Millisecond)
+	}
+	q.Close()
+
+	if job, _ := q.Get(running.ID); job.State != Cancelled {
+		t.Errorf("running job %+v", job)
+	}
+	job, _ := q.Get(waiting.ID)
+	if job.State != Failed || job.Error != ErrClosed.Error() || job.Expires == nil {
+		t.Errorf("waiting job %+v", job)
+	}
+}
@@ -0,0 +1,328 @@
+// Package kicad imports netlists exported from KiCad's schematic editor
+// (File > Export > Netlist, the default "KiCad" S-expression format).
+//
+// Each schematic symbol becomes a circuit.Component named after its
+// reference designator, and each net becomes the net name on the pins it
+// joins. Symbols are mapped to simulator elements by library and symbol
+// name; the mapping can be extended or overridden per library.
+package kicad
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"breadboard-simulator/circuit"
+)
+
+// Element describes how a symbol maps onto a simulator component. Pins
+// lists the symbol's pin numbers in the component's terminal order,
+// positive terminal first. When empty, pins are ordered by their pin
+// function (A before K, + before -, C B E, D G S), then by number.
+type Element struct {
+	Type       circuit.ComponentType  `json:"type"`
+	Pins       []string               `json:"pins,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Mapping maps symbols to elements. Keys are "Library:Symbol" for one
+// symbol, "Library:*" for a whole library or a bare "Symbol" from any
+// library; the most specific key wins. A nil Element skips the symbol.
+type Mapping map[string]*Element
+
+// DefaultMapping covers the symbols of KiCad's Device and Simulation_SPICE
+// libraries that the simulator has models for.
+var DefaultMapping = Mapping{
+	"Device:R":             {Type: circuit.Resistor},
+	"Device:R_Small":       {Type: circuit.Resistor},
+	"Device:R_US":          {Type: circuit.Resistor},
+	"Device:C":             {Type: circuit.Capacitor},
+	"Device:C_Small":       {Type: circuit.Capacitor},
+	"Device:C_Polarized":   {Type: circuit.Capacitor, Pins: []string{"1", "2"}, Properties: map[string]interface{}{"capacitorType": "electrolytic"}},
+	"Device:CP":            {Type: circuit.Capacitor, Pins: []string{"1", "2"}, Properties: map[string]interface{}{"capacitorType": "electrolytic"}},
+	"Device:L":             {Type: circuit.Inductor},
+	"Device:L_Small":       {Type: circuit.Inductor},
+	"Device:D":             {Type: circuit.Diode, Pins: []string{"2", "1"}},
+	"Device:D_Small":       {Type: circuit.Diode, Pins: []string{"2", "1"}},
+	"Device:D_Zener":       {Type: circuit.Diode, Pins: []string{"2", "1"}},
+	"Device:LED":           {Type: circuit.LED, Pins: []string{"2", "1"}},
+	"Device:LED_Small":     {Type: circuit.LED, Pins: []string{"2", "1"}},
+	"Device:Battery":       {Type: circuit.Battery, Pins: []string{"1", "2"}},
+	"Device:Battery_Cell":  {Type: circuit.Battery, Pins: []string{"1", "2"}},
+	"Device:Q_NPN_BCE":     {Type: circuit.Transistor, Pins: []string{"2", "1", "3"}, Properties: map[string]interface{}{"transistorType": "npn"}},
+	"Device:Q_NPN_CBE":     {Type: circuit.Transistor, Pins: []string{"1", "2", "3"}, Properties: map[string]interface{}{"transistorType": "npn"}},
+	"Device:Q_NPN_EBC":     {Type: circuit.Transistor, Pins: []string{"3", "2", "1"}, Properties: map[string]interface{}{"transistorType": "npn"}},
+	"Device:Q_PNP_BCE":     {Type: circuit.Transistor, Pins: []string{"2", "1", "3"}, Properties: map[string]interface{}{"transistorType": "pnp"}},
+	"Device:Q_PNP_CBE":     {Type: circuit.Transistor, Pins: []string{"1", "2", "3"}, Properties: map[string]interface{}{"transistorType": "pnp"}},
+	"Device:Q_PNP_EBC":     {Type: circuit.Transistor, Pins: []string{"3", "2", "1"}, Properties: map[string]interface{}{"transistorType": "pnp"}},
+	"Simulation_SPICE:VDC": {Type: circuit.Battery, Pins: []string{"1", "2"}},
+	"Simulation_SPICE:IDC": {Type: circuit.CurrentSource, Pins: []string{"2", "1"}},
+	"Simulation_SPICE:D":   {Type: circuit.Diode, Pins: []string{"2", "1"}},
+}
+
+// refPrefixes guesses the element of an unmapped symbol from its
+// reference designator.
+var refPrefixes = []struct {
+	prefix string
+	typ    circuit.ComponentType
+}{
+	{"BT", circuit.Battery},
+	{"R", circuit.Resistor},
+	{"C", circuit.Capacitor},
+	{"L", circuit.Inductor},
+	{"D", circuit.Diode},
+	{"Q", circuit.Transistor},
+}
+
+// pinFunctions orders pins by their function when a mapping gives no
+// explicit order.
+var pinFunctions = map[string]int{
+	"+": 0, "A": 0, "C": 0, "D": 0,
+	"-": 1, "K": 1, "B": 1, "G": 1,
+	"E": 2, "S": 2,
+}
+
+// valued lists the elements whose symbol value is their component value.
+var valued = map[circuit.ComponentType]bool{
+	circuit.Resistor:      true,
+	circuit.Capacitor:     true,
+	circuit.Inductor:      true,
+	circuit.Battery:       true,
+	circuit.CurrentSource: true,
+}
+
+// Skipped is a symbol left out of the circuit.
+type Skipped struct {
+	Ref    string `json:"ref"`
+	Symbol string `json:"symbol"`
+	Reason string `json:"reason"`
+}
+
+// Netlist is an imported KiCad netlist.
+type Netlist struct {
+	Source  string           `json:"source"`
+	Tool    string           `json:"tool"`
+	Circuit *circuit.Circuit `json:"circuit"`
+	Skipped []Skipped        `json:"skipped,omitempty"`
+	// Warnings notes what was imported with a guess, such as a value
+	// that did not parse and was replaced by its element's default.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// LoadMapping reads a JSON object of mapping entries, such as
+// {"MyLib:LED_RGB": {"type": "led", "pins": ["2", "1"]}}, and merges it
+// over DefaultMapping.
+func LoadMapping(r io.Reader) (Mapping, error) {
+	var extra Mapping
+	if err := json.NewDecoder(r).Decode(&extra); err != nil {
+		return nil, fmt.Errorf("reading symbol mapping: %w", err)
+	}
+	m := make(Mapping, len(DefaultMapping)+len(extra))
+	for key, el := range DefaultMapping {
+		m[key] = el
+	}
+	for key, el := range extra {
+		m[key] = el
+	}
+	return m, nil
+}
+
+// lookup finds the element for a symbol and reports whether the mapping
+// decided it, as opposed to falling back on the reference designator.
+func (m Mapping) lookup(lib, part string) (*Element, bool) {
+	for _, key := range []string{lib + ":" + part, lib + ":*", part} {
+		if el, ok := m[key]; ok {
+			return el, true
+		}
+	}
+	return nil, false
+}
+
+// Parse reads a netlist using DefaultMapping.
+func Parse(r io.Reader) (*Netlist, error) {
+	return DefaultMapping.Parse(r)
+}
+
+// ParseFile reads the netlist at path using DefaultMapping.
+func ParseFile(path string) (*Netlist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads a netlist, mapping symbols with m.
+func (m Mapping) Parse(r io.Reader) (*Netlist, error) {
+	root, err := parseSexpr(r)
+	if err != nil {
+		return nil, err
+	}
+	switch root.keyword() {
+	case "export":
+	case "kicad_sch", "eeschema":
+		return nil, fmt.Errorf("this is a schematic, not a netlist; export one with File > Export > Netlist")
+	default:
+		return nil, fmt.Errorf("not a KiCad netlist: top-level list is %q", root.keyword())
+	}
+
+	nl := &Netlist{Circuit: &circuit.Circuit{}}
+	if design := root.child("design"); design != nil {
+		nl.Source = design.text("source")
+		nl.Tool = design.text("tool")
+	}
+
+	// pin -> net, and pin -> function, per reference
+	pinNets := make(map[string]map[string]string)
+	pinFuncs := make(map[string]map[string]string)
+	if nets := root.child("nets"); nets != nil {
+		for _, net := range nets.children("net") {
+			name := netName(net.text("name"))
+			for _, node := range net.children("node") {
+				ref, pin := node.text("ref"), node.text("pin")
+				if pinNets[ref] == nil {
+					pinNets[ref] = make(map[string]string)
+					pinFuncs[ref] = make(map[string]string)
+				}
+				pinNets[ref][pin] = name
+				pinFuncs[ref][pin] = node.text("pinfunction")
+			}
+		}
+	}
+
+	comps := root.child("components")
+	if comps == nil {
+		return nl, nil
+	}
+	for _, comp := range comps.children("comp") {
+		ref := comp.text("ref")
+		var lib, part string
+		if src := comp.child("libsource"); src != nil {
+			lib, part = src.text("lib"), src.text("part")
+		}
+		symbol := lib + ":" + part
+		skip := func(reason string) {
+			nl.Skipped = append(nl.Skipped, Skipped{Ref: ref, Symbol: symbol, Reason: reason})
+		}
+
+		el, mapped := m.lookup(lib, part)
+		if !mapped {
+			el = guess(ref, part)
+		}
+		if el == nil {
+			if mapped {
+				skip("excluded by the symbol mapping")
+			} else {
+				skip("no simulation model for this symbol")
+			}
+			continue
+		}
+		if len(pinNets[ref]) == 0 {
+			skip("not connected to any net")
+			continue
+		}
+
+		c := circuit.Component{ID: ref, Type: el.Type, Properties: make(map[string]interface{})}
+		for key, v := range el.Properties {
+			c.Properties[key] = v
+		}
+		value := comp.text("value")
+		if valued[el.Type] {
+			v, err := circuit.ParseValue(value)
+			if err != nil {
+				// symbols are often left at their name, such as "Battery"
+				v = defaultValue(el.Type)
+				nl.Warnings = append(nl.Warnings, fmt.Sprintf("%s: value %q is not a number, using %s", ref, value, circuit.FormatValue(v, "")))
+			}
+			c.Value = v
+		} else if value != "" && value != part {
+			c.Properties["partNumber"] = value
+		}
+		if footprint := comp.text("footprint"); footprint != "" {
+			c.Properties["footprint"] = footprint
+		}
+
+		for _, pin := range orderPins(el.Pins, pinNets[ref], pinFuncs[ref]) {
+			c.Nodes = append(c.Nodes, pinNets[ref][pin])
+		}
+		if len(c.Properties) == 0 {
+			c.Properties = nil
+		}
+		nl.Circuit.Components = append(nl.Circuit.Components, c)
+	}
+	return nl, nil
+}
+
+// defaultValue is the registry's default value of an element type.
+func defaultValue(t circuit.ComponentType) float64 {
+	el, _ := circuit.Lookup(t)
+	p, _ := el.Param(el.Value)
+	v, _ := p.Default.(float64)
+	return v
+}
+
+// guess maps an unlisted symbol by its reference designator, so a
+// resistor from a third-party library still imports.
+func guess(ref, part string) *Element {
+	letters := strings.TrimRight(strings.ToUpper(ref), "0123456789?")
+	for _, p := range refPrefixes {
+		if letters != p.prefix {
+			continue
+		}
+		el := &Element{Type: p.typ}
+		upper := strings.ToUpper(part)
+		switch {
+		case p.typ == circuit.Diode && strings.Contains(upper, "LED"):
+			el.Type = circuit.LED
+		case p.typ == circuit.Transistor && strings.Contains(upper, "PNP"):
+			el.Properties = map[string]interface{}{"transistorType": "pnp"}
+		case p.typ == circuit.Transistor:
+			el.Properties = map[string]interface{}{"transistorType": "npn"}
+		}
+		return el
+	}
+	return nil
+}
+
+// orderPins returns the pins in terminal order. An explicit pin missing
+// from the netlist is unconnected and maps to no net.
+func orderPins(explicit []string, nets map[string]string, funcs map[string]string) []string {
+	if len(explicit) > 0 {
+		return explicit
+	}
+
+	pins := make([]string, 0, len(nets))
+	for pin := range nets {
+		pins = append(pins, pin)
+	}
+	rank := func(pin string) int {
+		if r, ok := pinFunctions[strings.ToUpper(funcs[pin])]; ok {
+			return r
+		}
+		return len(pinFunctions)
+	}
+	sort.Slice(pins, func(a, b int) bool {
+		ra, rb := rank(pins[a]), rank(pins[b])
+		if ra != rb {
+			return ra < rb
+		}
+		if len(pins[a]) != len(pins[b]) {
+			return len(pins[a]) < len(pins[b])
+		}
+		return pins[a] < pins[b]
+	})
+	return pins
+}
+
+// netName strips the root sheet's "/" from a net name, so "/GND" is the
+// simulator's ground.
+func netName(name string) string {
+	if trimmed := strings.TrimPrefix(name, "/"); !strings.Contains(trimmed, "/") {
+		return trimmed
+	}
+	return name
+}
@@ -0,0 +1,133 @@
+package kicad
+
+import (
+	"strings"
+	"testing"
+
+	"breadboard-simulator/circuit"
+)
+
+const ledNetlist = `(export (version "E")
+  (design
+    (source "/home/hw/blink/blink.kicad_sch")
+    (tool "Eeschema 7.0.10"))
+  (components
+    (comp (ref "BT1")
+      (value "9V")
+      (libsource (lib "Device") (part "Battery") (description "Multiple-cell battery")))
+    (comp (ref "R1")
+      (value "4k7")
+      (footprint "Resistor_THT:R_Axial_DIN0207_L6.3mm_D2.5mm_P10.16mm_Horizontal")
+      (libsource (lib "Device") (part "R")))
+    (comp (ref "D1")
+      (value "RED")
+      (libsource (lib "Device") (part "LED")))
+    (comp (ref "R2")
+      (value "1.5 kΩ")
+      (libsource (lib "Vendor_Passives") (part "RES_0603")))
+    (comp (ref "J1")
+      (value "Conn_01x02")
+      (libsource (lib "Connector") (part "Conn_01x02"))))
+  (nets
+    (net (code "1") (name "/VCC")
+      (node (ref "BT1") (pin "1") (pinfunction "+") (pintype "passive"))
+      (node (ref "R1") (pin "1") (pintype "passive"))
+      (node (ref "J1") (pin "1") (pintype "passive")))
+    (net (code "2") (name "Net-(D1-A)")
+      (node (ref "R1") (pin "2") (pintype "passive"))
+      (node (ref "D1") (pin "2") (pinfunction "A") (pintype "passive")))
+    (net (code "3") (name "GND")
+      (node (ref "BT1") (pin "2") (pinfunction "-") (pintype "passive"))
+      (node (ref "D1") (pin "1") (pinfunction "K") (pintype "passive"))
+      (node (ref "R2") (pin "2") (pintype "passive")))
+    (net (code "4") (name "/VCC")
+      (node (ref "R2") (pin "1") (pintype "passive")))))
+`
+
+func TestParse(t *testing.T) {
+	nl, err := Parse(strings.NewReader(ledNetlist))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nl.Tool != "Eeschema 7.0.10" {
+		t.Errorf("tool = %q", nl.Tool)
+	}
+	if len(nl.Skipped) != 1 || nl.Skipped[0].Ref != "J1" {
+		t.Errorf("skipped = %+v", nl.Skipped)
+	}
+
+	comps := make(map[string]circuit.Component)
+	for _, comp := range nl.Circuit.Components {
+		comps[comp.ID] = comp
+	}
+	if r1 := comps["R1"]; r1.Value != 4700 || r1.Nodes[0] != "VCC" || r1.Nodes[1] != "Net-(D1-A)" {
+		t.Errorf("R1 = %+v", r1)
+	}
+	// an unmapped vendor symbol falls back on its reference designator
+	if r2 := comps["R2"]; r2.Type != circuit.Resistor || r2.Value != 1500 {
+		t.Errorf("R2 = %+v", r2)
+	}
+	if d1 := comps["D1"]; d1.Type != circuit.LED || d1.Nodes[0] != "Net-(D1-A)" || d1.Nodes[1] != "GND" || d1.Properties["partNumber"] != "RED" {
+		t.Errorf("D1 = %+v", d1)
+	}
+
+	sol, err := circuit.Solve(nl.Circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := sol.NodeVoltages["VCC"]; v != 9 {
+		t.Errorf("VCC = %v, want 9", v)
+	}
+}
+
+func TestMapping(t *testing.T) {
+	m, err := LoadMapping(strings.NewReader(`{
+		"Vendor_Passives:*": {"type": "inductor"},
+		"Conn_01x02": null
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nl, err := m.Parse(strings.NewReader(ledNetlist))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, comp := range nl.Circuit.Components {
+		if comp.ID == "R2" && comp.Type != circuit.Inductor {
+			t.Errorf("R2 mapped to %s, want inductor", comp.Type)
+		}
+	}
+	if len(nl.Skipped) != 1 || nl.Skipped[0].Reason != "excluded by the symbol mapping" {
+		t.Errorf("skipped = %+v", nl.Skipped)
+	}
+}
+
+func TestParseDefaultValue(t *testing.T) {
+	// a symbol left at its name imports with the element's default value
+	in := `(export (components (comp (ref BT1) (value "Battery_Cell") (libsource (lib Device) (part Battery_Cell))))
+	  (nets (net (code 1) (name a) (node (ref BT1) (pin 1))) (net (code 2) (name GND) (node (ref BT1) (pin 2)))))`
+	nl, err := Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nl.Circuit.Components) != 1 || nl.Circuit.Components[0].Value != 9 {
+		t.Errorf("components = %+v", nl.Circuit.Components)
+	}
+	if len(nl.Warnings) != 1 || !strings.Contains(nl.Warnings[0], `BT1: value "Battery_Cell"`) {
+		t.Errorf("warnings = %v", nl.Warnings)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		`(kicad_sch (version 20230121))`:    "this is a schematic",
+		`(export (design (source "a.sch")`:  "unclosed list opened on line 1",
+		`(export (design (source "a.sch)))`: "unterminated string",
+	}
+	for in, want := range tests {
+		_, err := Parse(strings.NewReader(in))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Parse(%q) error = %v, want %q", in, err, want)
+		}
+	}
+}
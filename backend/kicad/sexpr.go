@@ -0,0 +1,192 @@
+package kicad
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxNesting bounds list depth so a malformed file cannot exhaust the
+// stack.
+const maxNesting = 256
+
+// sexpr is an S-expression: an atom, or a list whose first element is
+// usually its keyword.
+type sexpr struct {
+	atom string
+	list []*sexpr
+	line int
+}
+
+func (e *sexpr) isList() bool {
+	return e.list != nil
+}
+
+// keyword returns the atom heading a list, as in (comp ...).
+func (e *sexpr) keyword() string {
+	if len(e.list) == 0 || e.list[0].isList() {
+		return ""
+	}
+	return e.list[0].atom
+}
+
+// children returns the sub-lists headed by keyword.
+func (e *sexpr) children(keyword string) []*sexpr {
+	var out []*sexpr
+	for _, item := range e.list {
+		if item.isList() && item.keyword() == keyword {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// child returns the first sub-list headed by keyword, or nil.
+func (e *sexpr) child(keyword string) *sexpr {
+	for _, item := range e.list {
+		if item.isList() && item.keyword() == keyword {
+			return item
+		}
+	}
+	return nil
+}
+
+// text returns the atom following the keyword of the sub-list headed by
+// keyword, as "R1" in (ref "R1").
+func (e *sexpr) text(keyword string) string {
+	if c := e.child(keyword); c != nil && len(c.list) > 1 && !c.list[1].isList() {
+		return c.list[1].atom
+	}
+	return ""
+}
+
+// parseSexpr reads a single S-expression. Quoted atoms may use backslash
+// escapes; both KiCad 5's bare atoms and later versions' quoted ones are
+// accepted.
+func parseSexpr(r io.Reader) (*sexpr, error) {
+	p := &sexprParser{r: bufio.NewReader(r), line: 1}
+	p.skipSpace()
+	e, err := p.parse(0)
+	if err != nil {
+		return nil, err
+	}
+	if !e.isList() {
+		return nil, fmt.Errorf("line %d: expected a list", e.line)
+	}
+	return e, nil
+}
+
+type sexprParser struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (p *sexprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *sexprParser) skipSpace() {
+	for {
+		ch, _, err := p.r.ReadRune()
+		if err != nil {
+			return
+		}
+		if ch == '\n' {
+			p.line++
+		}
+		if !strings.ContainsRune(" \t\r\n", ch) {
+			p.r.UnreadRune()
+			return
+		}
+	}
+}
+
+func (p *sexprParser) parse(depth int) (*sexpr, error) {
+	if depth > maxNesting {
+		return nil, p.errorf("lists nested too deeply")
+	}
+	ch, _, err := p.r.ReadRune()
+	if err == io.EOF {
+		return nil, p.errorf("unexpected end of file")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch ch {
+	case '(':
+		e := &sexpr{list: []*sexpr{}, line: p.line}
+		for {
+			p.skipSpace()
+			next, _, err := p.r.ReadRune()
+			if err == io.EOF {
+				return nil, p.errorf("unclosed list opened on line %d", e.line)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if next == ')' {
+				return e, nil
+			}
+			p.r.UnreadRune()
+			item, err := p.parse(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			e.list = append(e.list, item)
+		}
+	case ')':
+		return nil, p.errorf("unexpected )")
+	case '"':
+		return p.quoted()
+	}
+
+	var b strings.Builder
+	b.WriteRune(ch)
+	for {
+		next, _, err := p.r.ReadRune()
+		if err != nil {
+			break
+		}
+		if strings.ContainsRune(" \t\r\n()", next) {
+			p.r.UnreadRune()
+			break
+		}
+		b.WriteRune(next)
+	}
+	return &sexpr{atom: b.String(), line: p.line}, nil
+}
+
+func (p *sexprParser) quoted() (*sexpr, error) {
+	line := p.line
+	var b strings.Builder
+	for {
+		ch, _, err := p.r.ReadRune()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: unterminated string", line)
+		}
+		switch ch {
+		case '"':
+			return &sexpr{atom: b.String(), line: line}, nil
+		case '\\':
+			next, _, err := p.r.ReadRune()
+			if err != nil {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			switch next {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			default:
+				b.WriteRune(next)
+			}
+		case '\n':
+			p.line++
+			b.WriteRune(ch)
+		default:
+			b.WriteRune(ch)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package live
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"breadboard-simulator/circuit"
+)
+
+func rcCircuit() []circuit.Component {
+	return []circuit.Component{
+		{ID: "B1", Type: circuit.Battery, Value: 5, Nodes: []string{"vcc", "0"}},
+		{ID: "S1", Type: circuit.Switch, Nodes: []string{"vcc", "a"}},
+		{ID: "R1", Type: circuit.Resistor, Value: 1000, Nodes: []string{"a", "out"}},
+		{ID: "C1", Type: circuit.Capacitor, Value: 1e-6, Nodes: []string{"out", "0"}},
+	}
+}
+
+func newTestSession(t *testing.T) (*Session, <-chan Message) {
+	t.Helper()
+	out := make(chan Message, 1000)
+	s := NewSession(context.Background(), func(m Message) error {
+		out <- m
+		return nil
+	})
+	t.Cleanup(s.Close)
+	return s, out
+}
+
+func next(t *testing.T, out <-chan Message) Message {
+	t.Helper()
+	select {
+	case m := <-out:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message")
+		return Message{}
+	}
+}
+
+// skipBatches returns the next message that is not a batch.
+func skipBatches(t *testing.T, out <-chan Message) Message {
+	t.Helper()
+	for {
+		if m := next(t, out); m.Type != BatchMessage {
+			return m
+		}
+	}
+}
+
+func TestSessionRunsToStop(t *testing.T) {
+	s, out := newTestSession(t)
+	components := rcCircuit()
+	components[1].Properties = map[string]interface{}{"closed": true}
+	components[3].Properties = map[string]interface{}{"initialVoltage": 0.0, "voltageRating": 3.0}
+	s.Handle(Message{Type: StartMessage, Components: components, Options: &Options{Step: 1e-4, Stop: 5e-3, Batch: 10}})
+
+	m := next(t, out)
+	if m.Type != StartedMessage || m.Run != 1 || m.Options.Rate != 0 {
+		t.Fatalf("first message = %+v", m)
+	}
+	var samples []Sample
+	for m = next(t, out); m.Type == BatchMessage; m = next(t, out) {
+		if len(m.Samples) > 10 {
+			t.Errorf("batch of %d samples", len(m.Samples))
+		}
+		// C1 passes its 3 V rating at 1 ms, in the second batch
+		if len(samples) == 0 && (len(m.Stress) != 1 || m.Stress[0].Level != circuit.StressWarning) {
+			t.Errorf("first batch stress = %+v", m.Stress)
+		}
+		samples = append(samples, m.Samples...)
+	}
+	if m.Type != DoneMessage {
+		t.Fatalf("run ended with %+v", m)
+	}
+	if len(m.Stress) != 1 || m.Stress[0].FirstExceeded == nil || math.Abs(*m.Stress[0].FirstExceeded-1e-3) > 1e-9 {
+		t.Errorf("stress at the end = %+v", m.Stress)
+	}
+	if len(samples) != 51 || samples[0].Time != 0 || math.Abs(samples[50].Time-5e-3) > 1e-9 {
+		t.Fatalf("got %d samples from %v to %v", len(samples), samples[0].Time, samples[len(samples)-1].Time)
+	}
+	// five time constants in: within 1% of the supply
+	if v := samples[50].NodeVoltages["out"]; v < 4.95 || v > 5 {
+		t.Errorf("out = %v at the end", v)
+	}
+
+	s.Handle(Message{Type: PauseMessage})
+	if m := next(t, out); m.Type != ErrorMessage {
+		t.Errorf("pause after the run: %+v", m)
+	}
+}
+
+func TestSessionControls(t *testing.T) {
+	s, out := newTestSession(t)
+	s.Handle(Message{Type: StartMessage, Components: rcCircuit(), Options: &Options{Step: 1e-4, Batch: 5, Rate: 1}})
+	if m := next(t, out); m.Type != StartedMessage {
+		t.Fatalf("first message = %+v", m)
+	}
+	if m := next(t, out); m.Type != BatchMessage || m.Samples[0].NodeVoltages["out"] != 0 {
+		t.Fatalf("second message = %+v", m)
+	}
+
+	s.Handle(Message{Type: PauseMessage})
+	if m := skipBatches(t, out); m.Type != PausedMessage {
+		t.Fatalf("pause: %+v", m)
+	}
+	s.Handle(Message{Type: SetMessage, Change: &Change{ID: "S1", Properties: map[string]interface{}{"closed": true}}})
+	if m := skipBatches(t, out); m.Type != ChangedMessage {
+		t.Fatalf("set: %+v", m)
+	}
+	s.Handle(Message{Type: SetMessage, Change: &Change{ID: "nope"}})
+	if m := skipBatches(t, out); m.Type != ErrorMessage {
+		t.Fatalf("set on a missing part: %+v", m)
+	}
+	s.Handle(Message{Type: ResumeMessage})
+	if m := skipBatches(t, out); m.Type != ResumedMessage {
+		t.Fatalf("resume: %+v", m)
+	}
+
+	// the capacitor charges now the switch is closed
+	m := next(t, out)
+	for m.Type == BatchMessage && m.Samples[len(m.Samples)-1].NodeVoltages["out"] == 0 {
+		m = next(t, out)
+	}
+	if m.Type != BatchMessage {
+		t.Fatalf("after resuming: %+v", m)
+	}
+
+	s.Handle(Message{Type: CancelMessage})
+	if m := skipBatches(t, out); m.Type != CancelledMessage || m.Run != 1 {
+		t.Fatalf("cancel: %+v", m)
+	}
+}
+
+func TestSessionRestart(t *testing.T) {
+	s, out := newTestSession(t)
+	s.Handle(Message{Type: StartMessage, Components: rcCircuit()})
+	if m := next(t, out); m.Type != StartedMessage || m.Options.Rate != 1 || m.Options.Step != DefaultStep {
+		t.Fatalf("first message = %+v", m)
+	}
+	s.Handle(Message{Type: StartMessage, Components: rcCircuit()})
+
+	var ended, started bool
+	for !ended || !started {
+		m := next(t, out)
+		switch {
+		case m.Type == CancelledMessage && m.Run == 1:
+			ended = true
+		case m.Type == StartedMessage && m.Run == 2:
+			started = true
+		}
+	}
+
+	s.Handle(Message{Type: StartMessage, Components: []circuit.Component{
+		{ID: "B1", Type: circuit.Battery, Value: 5, Nodes: []string{"vcc", "0"}},
+		{ID: "W1", Type: circuit.Wire, Nodes: []string{"vcc", "0"}},
+	}})
+	// the cancellation and the failed start may arrive in either order
+	var cancelled, failed bool
+	for k := 0; k < 2; k++ {
+		switch m := skipBatches(t, out); {
+		case m.Type == CancelledMessage && m.Run == 2:
+			cancelled = true
+		case m.Type == ErrorMessage && m.Run == 0:
+			failed = true
+		default:
+			t.Fatalf("restart with a short: %+v", m)
+		}
+	}
+	if !cancelled || !failed {
+		t.Errorf("cancelled %v, failed %v", cancelled, failed)
+	}
+}
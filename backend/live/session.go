@@ -0,0 +1,207 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"breadboard-simulator/breadboard"
+	"breadboard-simulator/circuit"
+	"breadboard-simulator/parts"
+)
+
+// Message types a client sends.
+const (
+	StartMessage  = "start"
+	PauseMessage  = "pause"
+	ResumeMessage = "resume"
+	CancelMessage = "cancel"
+	SetMessage    = "set"
+)
+
+// Message types sent to the client.
+const (
+	StartedMessage   = "started"
+	BatchMessage     = "batch"
+	PausedMessage    = "paused"
+	ResumedMessage   = "resumed"
+	ChangedMessage   = "changed"
+	DoneMessage      = "done"
+	CancelledMessage = "cancelled"
+	ErrorMessage     = "error"
+)
+
+// Message is one JSON message of the protocol. A client starts a
+// simulation with a start message holding the circuit, as components and
+// connections or as a breadboard, and its options. It then receives the
+// samples in batch messages until a done, cancelled or error message ends
+// the run; each of these carries the stress findings of the run so far.
+// Meanwhile it may send pause, resume and cancel, and set with a Change,
+// each acknowledged with paused, resumed, cancelled or changed, or an
+// error. Starting again cancels the running simulation; Run numbers the
+// simulations of a session so late messages of an earlier one can be told
+// apart.
+type Message struct {
+	Type        string                  `json:"type"`
+	Run         int                     `json:"run,omitempty"`
+	Components  []circuit.Component     `json:"components,omitempty"`
+	Connections []circuit.Connection    `json:"connections,omitempty"`
+	Subcircuits []circuit.Subcircuit    `json:"subcircuits,omitempty"`
+	Params      map[string]interface{}  `json:"params,omitempty"`
+	Breadboard  *breadboard.State       `json:"breadboard,omitempty"`
+	Options     *Options                `json:"options,omitempty"`
+	Change      *Change                 `json:"change,omitempty"`
+	Time        float64                 `json:"time,omitempty"`
+	Samples     []Sample                `json:"samples,omitempty"`
+	Stress      []circuit.StressFinding `json:"stress,omitempty"`
+	Error       string                  `json:"error,omitempty"`
+}
+
+var errNotRunning = errors.New("no simulation is running")
+
+// Session runs the simulations of one client. Messages are sent through
+// the function given to NewSession, never more than one at a time.
+type Session struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	sendMu sync.Mutex
+	send   func(Message) error
+
+	mu   sync.Mutex
+	sim  *Simulation
+	runs int
+	wg   sync.WaitGroup
+}
+
+// NewSession starts a session whose simulations end with ctx.
+func NewSession(ctx context.Context, send func(Message) error) *Session {
+	s := &Session{send: send}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	return s
+}
+
+// Handle acts on a message from the client.
+func (s *Session) Handle(m Message) {
+	if m.Type == StartMessage {
+		s.start(m)
+		return
+	}
+
+	s.mu.Lock()
+	sim, run := s.sim, s.runs
+	s.mu.Unlock()
+	if sim == nil {
+		s.reply(Message{Type: ErrorMessage, Error: errNotRunning.Error()})
+		return
+	}
+
+	switch m.Type {
+	case PauseMessage:
+		sim.Pause()
+		s.reply(Message{Type: PausedMessage, Run: run, Time: sim.Time()})
+	case ResumeMessage:
+		sim.Resume()
+		s.reply(Message{Type: ResumedMessage, Run: run, Time: sim.Time()})
+	case CancelMessage:
+		// the stream reports the cancellation when it ends
+		sim.Cancel()
+	case SetMessage:
+		if m.Change == nil {
+			s.reply(Message{Type: ErrorMessage, Run: run, Error: "set needs a change"})
+			return
+		}
+		if err := sim.Apply(*m.Change); err != nil {
+			s.reply(Message{Type: ErrorMessage, Run: run, Error: err.Error()})
+			return
+		}
+		s.reply(Message{Type: ChangedMessage, Run: run, Change: m.Change, Time: sim.Time()})
+	default:
+		s.reply(Message{Type: ErrorMessage, Error: fmt.Sprintf("unknown message type %q", m.Type)})
+	}
+}
+
+// Reject reports a message that could not be read.
+func (s *Session) Reject(err error) {
+	s.reply(Message{Type: ErrorMessage, Error: err.Error()})
+}
+
+// Close cancels the running simulation and waits for its stream to end.
+func (s *Session) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Session) start(m Message) {
+	c := &circuit.Circuit{Components: m.Components, Connections: m.Connections, Subcircuits: m.Subcircuits, Params: m.Params}
+	var err error
+	if m.Breadboard != nil {
+		c, _, err = m.Breadboard.Netlist()
+	}
+	if err == nil {
+		c, err = circuit.Flatten(c)
+	}
+	if err == nil {
+		c, err = parts.Default.Resolve(c)
+	}
+	if err != nil {
+		s.reply(Message{Type: ErrorMessage, Error: err.Error()})
+		return
+	}
+	var opts Options
+	if m.Options != nil {
+		opts = *m.Options
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sim != nil {
+		s.sim.Cancel()
+	}
+	sim, err := Start(s.ctx, c, opts)
+	if err != nil {
+		s.sim = nil
+		s.reply(Message{Type: ErrorMessage, Error: err.Error()})
+		return
+	}
+	s.runs++
+	s.sim = sim
+	opts = sim.Options()
+	s.reply(Message{Type: StartedMessage, Run: s.runs, Options: &opts})
+
+	s.wg.Add(1)
+	go s.stream(sim, s.runs)
+}
+
+// stream forwards a simulation's batches to the client and reports how
+// it ended.
+func (s *Session) stream(sim *Simulation, run int) {
+	defer s.wg.Done()
+	for batch := range sim.Batches() {
+		if err := s.reply(Message{Type: BatchMessage, Run: run, Samples: batch.Samples, Stress: batch.Stress}); err != nil {
+			sim.Cancel()
+		}
+	}
+
+	end := Message{Type: DoneMessage, Run: run, Time: sim.Time(), Stress: sim.Stress()}
+	switch err := sim.Err(); {
+	case errors.Is(err, context.Canceled):
+		end.Type = CancelledMessage
+	case err != nil:
+		end.Type, end.Error = ErrorMessage, err.Error()
+	}
+	s.reply(end)
+
+	s.mu.Lock()
+	if s.sim == sim {
+		s.sim = nil
+	}
+	s.mu.Unlock()
+}
+
+func (s *Session) reply(m Message) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	return s.send(m)
+}
@@ -0,0 +1,298 @@
+// Package live runs transient simulations whose results are streamed as
+// they are computed. A running simulation can be paused, resumed and
+// cancelled, and its parts changed, such as flipping a switch or turning
+// a potentiometer; a change takes effect from the next time step.
+//
+// Simulation is the simulation itself; Session speaks the message
+// protocol a client uses to drive simulations over a connection.
+package live
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"breadboard-simulator/circuit"
+)
+
+// Defaults for Options left zero.
+const (
+	DefaultStep  = 1e-3
+	DefaultBatch = 50
+)
+
+// Options control a simulation. Step is the time step in seconds and
+// Batch the number of samples sent together. The simulation ends at Stop
+// seconds, or runs until cancelled when Stop is zero. Rate paces it to
+// that many simulated seconds per second of real time; zero runs as fast
+// as the results are consumed, except that a simulation without Stop
+// runs in real time.
+type Options struct {
+	Step  float64 `json:"step"`
+	Stop  float64 `json:"stop"`
+	Batch int     `json:"batch"`
+	Rate  float64 `json:"rate"`
+}
+
+func (o Options) withDefaults() (Options, error) {
+	if o.Step == 0 {
+		o.Step = DefaultStep
+	}
+	if o.Batch <= 0 {
+		o.Batch = DefaultBatch
+	}
+	if o.Rate == 0 && o.Stop == 0 {
+		o.Rate = 1
+	}
+	if o.Step < 0 || o.Stop < 0 || o.Rate < 0 {
+		return o, fmt.Errorf("step, stop and rate must not be negative")
+	}
+	return o, nil
+}
+
+// Sample is the state of the circuit at one time.
+type Sample struct {
+	Time         float64            `json:"time"`
+	NodeVoltages map[string]float64 `json:"nodeVoltages"`
+	Voltages     map[string]float64 `json:"voltages"`
+	Currents     map[string]float64 `json:"currents"`
+}
+
+func newSample(t float64, sol *circuit.Solution) Sample {
+	return Sample{t, sol.NodeVoltages, sol.Voltages, sol.Currents}
+}
+
+// Batch is a run of consecutive samples with the stress findings of the
+// simulation up to the last of them: the parts loaded near or beyond
+// their ratings and when each rating was first exceeded.
+type Batch struct {
+	Samples []Sample
+	Stress  []circuit.StressFinding
+}
+
+// Change alters a part of a running simulation: its value when Value is
+// set, and the given properties, such as a switch's "closed" or a
+// potentiometer's "position".
+type Change struct {
+	ID         string                 `json:"id"`
+	Value      *float64               `json:"value,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Simulation is a transient simulation running in its own goroutine.
+type Simulation struct {
+	opts    Options
+	ctx     context.Context
+	cancel  context.CancelFunc
+	batches chan Batch
+
+	mu       sync.Mutex
+	wake     *sync.Cond
+	tr       *circuit.Transient
+	paused   bool
+	finished bool
+	err      error
+}
+
+// Start solves the operating point of c and starts stepping it from
+// there. The simulation ends when ctx is done or it is cancelled.
+func Start(ctx context.Context, c *circuit.Circuit, opts Options) (*Simulation, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	tr, err := circuit.NewTransient(c, opts.Step)
+	if err != nil {
+		return nil, err
+	}
+	s := &Simulation{
+		opts:    opts,
+		batches: make(chan Batch, 4),
+		tr:      tr,
+	}
+	s.wake = sync.NewCond(&s.mu)
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	context.AfterFunc(s.ctx, func() {
+		s.mu.Lock()
+		s.wake.Broadcast()
+		s.mu.Unlock()
+	})
+	go s.run()
+	return s, nil
+}
+
+// Options returns the options the simulation runs with, defaults filled
+// in.
+func (s *Simulation) Options() Options {
+	return s.opts
+}
+
+// Batches delivers the samples in batches as they are computed, the first
+// holding the operating point at time zero. It is closed when the
+// simulation ends.
+func (s *Simulation) Batches() <-chan Batch {
+	return s.batches
+}
+
+// Stress returns the stress findings of the steps made so far.
+func (s *Simulation) Stress() []circuit.StressFinding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tr.Stress()
+}
+
+// Err reports why the simulation ended once Batches is closed: nil when
+// it reached its stop time, the context's error when it was cancelled, or
+// the error that stopped the solver.
+func (s *Simulation) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil || s.finished {
+		return s.err
+	}
+	return s.ctx.Err()
+}
+
+// Time returns the simulated time reached.
+func (s *Simulation) Time() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tr.Time()
+}
+
+// Pause stops stepping after the current step. The samples computed
+// before the pause are delivered.
+func (s *Simulation) Pause() {
+	s.mu.Lock()
+	s.paused = true
+	s.mu.Unlock()
+}
+
+// Resume continues a paused simulation.
+func (s *Simulation) Resume() {
+	s.mu.Lock()
+	s.paused = false
+	s.wake.Broadcast()
+	s.mu.Unlock()
+}
+
+// Paused reports whether the simulation is paused.
+func (s *Simulation) Paused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// Cancel ends the simulation.
+func (s *Simulation) Cancel() {
+	s.cancel()
+}
+
+// Apply makes a change to the circuit, effective from the next step.
+func (s *Simulation) Apply(ch Change) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tr.Update(ch.ID, func(comp *circuit.Component) {
+		if ch.Value != nil {
+			comp.Value = *ch.Value
+		}
+		for name, v := range ch.Properties {
+			comp.Properties[name] = v
+		}
+	})
+}
+
+func (s *Simulation) run() {
+	defer s.cancel()
+	defer close(s.batches)
+
+	batch := []Sample{newSample(0, s.tr.Solution())}
+	// pacing is measured from the last start or resume
+	pacedAt, pacedFrom := time.Now(), 0.0
+	for {
+		if len(batch) > 0 && s.Paused() {
+			if !s.send(batch) {
+				return
+			}
+			batch = nil
+		}
+		resumed, ok := s.waitWhilePaused()
+		if !ok {
+			return
+		}
+
+		s.mu.Lock()
+		if resumed {
+			pacedAt, pacedFrom = time.Now(), s.tr.Time()
+		}
+		sol, err := s.tr.Step()
+		t := s.tr.Time()
+		if err != nil {
+			s.err = err
+		}
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+
+		batch = append(batch, newSample(t, sol))
+		done := s.opts.Stop > 0 && t >= s.opts.Stop-s.opts.Step/2
+		if len(batch) < s.opts.Batch && !done {
+			continue
+		}
+		if !s.send(batch) {
+			return
+		}
+		batch = nil
+		if done {
+			s.mu.Lock()
+			s.finished = true
+			s.mu.Unlock()
+			return
+		}
+		if s.opts.Rate > 0 {
+			ahead := time.Duration((t - pacedFrom) / s.opts.Rate * float64(time.Second))
+			if !s.sleep(time.Until(pacedAt.Add(ahead))) {
+				return
+			}
+		}
+	}
+}
+
+// waitWhilePaused blocks until the simulation is not paused. It reports
+// whether it had to wait, and false for ok when the simulation ended.
+func (s *Simulation) waitWhilePaused() (waited, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.paused && s.ctx.Err() == nil {
+		waited = true
+		s.wake.Wait()
+	}
+	return waited, s.ctx.Err() == nil
+}
+
+func (s *Simulation) send(samples []Sample) bool {
+	// no step is made until the batch is sent, so the findings are those
+	// of its last sample
+	select {
+	case s.batches <- Batch{samples, s.Stress()}:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+func (s *Simulation) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return s.ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,371 @@
+// Package parts is the library of real parts, such as the 1N4148 diode or
+// the 2N2222 transistor, that components can be built from instead of raw
+// values. Each part maps a part number to a simulator element and its
+// parameters, typically a SPICE model taken from the datasheet.
+//
+// The library is file based. The bundled parts are JSON files embedded in
+// the binary; more can be loaded from a directory of JSON part files and
+// SPICE model libraries (.lib, .mod), and models imported at run time are
+// saved there. A component uses a part by naming it in its "part"
+// property, and Resolve fills in the part's value and properties.
+package parts
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"breadboard-simulator/circuit"
+	"breadboard-simulator/spice"
+)
+
+// PartProperty is the component property naming the part a component is.
+const PartProperty = "part"
+
+// ErrNotFound is returned for a part number the library does not have.
+var ErrNotFound = errors.New("part not found")
+
+// Part is one part of the library. Its properties are those of its model,
+// if it has one, overridden by Properties.
+type Part struct {
+	Number      string                 `json:"number"`
+	Element     circuit.ComponentType  `json:"element"`
+	Category    string                 `json:"category"`
+	Description string                 `json:"description,omitempty"`
+	Value       float64                `json:"value,omitempty"`
+	Model       *spice.Model           `json:"model,omitempty"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+	// Source is the file the part was loaded from.
+	Source string `json:"source,omitempty"`
+}
+
+// ComponentProperties returns the properties a component made from the
+// part takes, including PartProperty.
+func (p Part) ComponentProperties() map[string]interface{} {
+	props := make(map[string]interface{})
+	if p.Model != nil {
+		for key, v := range p.Model.Properties() {
+			props[key] = v
+		}
+	}
+	for key, v := range p.Properties {
+		props[key] = v
+	}
+	props[PartProperty] = p.Number
+	return props
+}
+
+func (p Part) validate() error {
+	if p.Number == "" {
+		return fmt.Errorf("part has no number")
+	}
+	if _, ok := circuit.Lookup(p.Element); !ok {
+		return fmt.Errorf("part %s: unknown element %q", p.Number, p.Element)
+	}
+	if p.Model != nil {
+		if t := p.Model.ElementType(); t != p.Element && !(t == circuit.Diode && p.Element == circuit.LED) {
+			return fmt.Errorf("part %s: a %s model does not fit a %s", p.Number, p.Model.Type, p.Element)
+		}
+	}
+	return nil
+}
+
+// Library holds parts by number. Part numbers are matched without regard
+// to case. It is safe for concurrent use.
+type Library struct {
+	mu    sync.RWMutex
+	parts map[string]Part
+	dir   string
+}
+
+// New returns an empty library.
+func New() *Library {
+	return &Library{parts: make(map[string]Part)}
+}
+
+//go:embed library
+var bundled embed.FS
+
+// Bundled returns a library of the parts shipped with the simulator.
+func Bundled() (*Library, error) {
+	l := New()
+	sub, _ := fs.Sub(bundled, "library")
+	if err := l.Load(sub); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Default is the library requests are resolved against: the bundled parts,
+// extended by the server with its own directory.
+var Default = mustBundled()
+
+func mustBundled() *Library {
+	l, err := Bundled()
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// modelExtensions are the file extensions read as SPICE model libraries.
+var modelExtensions = map[string]bool{".lib": true, ".mod": true, ".model": true}
+
+// Load adds the parts in every JSON part file and SPICE model library of
+// fsys, in file name order, so a later file replaces a part of an earlier
+// one.
+func (l *Library) Load(fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		ext := strings.ToLower(path.Ext(name))
+		if ext != ".json" && !modelExtensions[ext] {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		if ext == ".json" {
+			return l.loadJSON(data, name)
+		}
+		_, err = l.importModels(data, name, "")
+		return err
+	})
+}
+
+// LoadDir loads the parts in dir, creating it if need be, and makes it the
+// directory imported models are saved to.
+func (l *Library) LoadDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := l.Load(os.DirFS(dir)); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.dir = dir
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *Library) loadJSON(data []byte, name string) error {
+	var list []Part
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	for _, p := range list {
+		p.Source = name
+		if err := l.Add(p); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Add puts a part in the library, replacing any part with its number.
+func (l *Library) Add(p Part) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.parts[strings.ToLower(p.Number)] = p
+	return nil
+}
+
+// Get returns the part with the given number.
+func (l *Library) Get(number string) (Part, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	p, ok := l.parts[strings.ToLower(number)]
+	if !ok {
+		return Part{}, fmt.Errorf("%w: %s", ErrNotFound, number)
+	}
+	return p, nil
+}
+
+// Search returns the parts in category, or in every category when it is
+// empty, whose number, model name or description contains query, ignoring
+// case. They are ordered by number.
+func (l *Library) Search(query, category string) []Part {
+	query = strings.ToLower(strings.TrimSpace(query))
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	found := []Part{}
+	for _, p := range l.parts {
+		if category != "" && !strings.EqualFold(p.Category, category) {
+			continue
+		}
+		if query != "" && !p.matches(query) {
+			continue
+		}
+		found = append(found, p)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Number < found[j].Number })
+	return found
+}
+
+func (p Part) matches(query string) bool {
+	fields := []string{p.Number, p.Description}
+	if p.Model != nil {
+		fields = append(fields, p.Model.Name)
+	}
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// Categories returns the categories of the library's parts, sorted.
+func (l *Library) Categories() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	seen := make(map[string]bool)
+	list := []string{}
+	for _, p := range l.parts {
+		if !seen[p.Category] {
+			seen[p.Category] = true
+			list = append(list, p.Category)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// modelCategories files imported models by element.
+var modelCategories = map[circuit.ComponentType]string{
+	circuit.Diode:      "diodes",
+	circuit.LED:        "leds",
+	circuit.Transistor: "transistors",
+	circuit.Mosfet:     "mosfets",
+}
+
+// Import adds a part for every .model card of a SPICE model library read
+// from r, named after its model, and returns them. Models of types the
+// simulator has no element for are skipped. category files the parts;
+// when empty, they are filed by element. If the library has a directory
+// the file is saved there as name, so the parts are loaded again on the
+// next start, filed by element.
+func (l *Library) Import(r io.Reader, name, category string) ([]Part, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Base(name)
+	if base == "." || base == ".." || base == string(filepath.Separator) {
+		base = "imported"
+	}
+	if !modelExtensions[strings.ToLower(filepath.Ext(base))] {
+		base += ".lib"
+	}
+	added, err := l.importModels(data, base, category)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.RLock()
+	dir := l.dir
+	l.mu.RUnlock()
+	if dir != "" && len(added) > 0 {
+		if err := os.WriteFile(filepath.Join(dir, base), data, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return added, nil
+}
+
+func (l *Library) importModels(data []byte, name, category string) ([]Part, error) {
+	// model libraries are self-contained: .include is not followed
+	p := &spice.Parser{Open: func(string) (io.ReadCloser, error) {
+		return nil, errors.New(".include is not allowed in a parts library")
+	}}
+	deck, err := p.ParseLibrary(bytes.NewReader(data), name)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(deck.Models))
+	for key := range deck.Models {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	added := []Part{}
+	for _, key := range names {
+		m := deck.Models[key]
+		element := m.ElementType()
+		if element == "" {
+			continue
+		}
+		part := Part{
+			Number:      m.Name,
+			Element:     element,
+			Category:    category,
+			Description: fmt.Sprintf("%s model imported from %s", strings.ToUpper(m.Type), name),
+			Model:       &m,
+			Source:      name,
+		}
+		if part.Category == "" {
+			part.Category = modelCategories[element]
+		}
+		if err := l.Add(part); err != nil {
+			return nil, err
+		}
+		added = append(added, part)
+	}
+	return added, nil
+}
+
+// Resolve returns c with every component that names a part filled in from
+// the library, or c itself when none does. The part's properties are the
+// defaults the component's own override, and its value is used when the
+// component has neither a value nor an expression. A component must be of
+// its part's element type.
+func (l *Library) Resolve(c *circuit.Circuit) (*circuit.Circuit, error) {
+	var out *circuit.Circuit
+	for k, comp := range c.Components {
+		number := comp.Text(PartProperty)
+		if number == "" {
+			continue
+		}
+		p, err := l.Get(number)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", comp.ID, err)
+		}
+		if comp.Type != p.Element {
+			return nil, fmt.Errorf("%s is a %s but part %s is a %s", comp.ID, comp.Type, p.Number, p.Element)
+		}
+
+		if out == nil {
+			work := *c
+			work.Components = append([]circuit.Component(nil), c.Components...)
+			out = &work
+		}
+		props := p.ComponentProperties()
+		for key, v := range comp.Properties {
+			props[key] = v
+		}
+		comp.Properties = props
+		if comp.Value == 0 && comp.Expression == "" {
+			comp.Value = p.Value
+		}
+		out.Components[k] = comp
+	}
+	if out == nil {
+		return c, nil
+	}
+	return out, nil
+}
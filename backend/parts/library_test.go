@@ -0,0 +1,131 @@
+package parts
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"breadboard-simulator/circuit"
+)
+
+func TestBundled(t *testing.T) {
+	lib, err := Bundled()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := lib.Get("1n4148")
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := d.ComponentProperties()
+	if d.Element != circuit.Diode || props["model"] != "D1N4148" || props["part"] != "1N4148" {
+		t.Errorf("1N4148 = %+v, properties %v", d, props)
+	}
+	if vf, _ := props["forwardVoltage"].(float64); vf < 0.6 || vf > 0.8 {
+		t.Errorf("1N4148 forward voltage = %v", vf)
+	}
+	if q, _ := lib.Get("2N2222"); q.ComponentProperties()["transistorType"] != "npn" {
+		t.Errorf("2N2222 = %+v", q)
+	}
+
+	if found := lib.Search("switching", ""); len(found) < 2 {
+		t.Errorf("search for switching found %v", found)
+	}
+	for _, p := range lib.Search("", "leds") {
+		if p.Element != circuit.LED {
+			t.Errorf("%s in leds is a %s", p.Number, p.Element)
+		}
+	}
+	if found := lib.Search("2n", "diodes"); len(found) != 0 {
+		t.Errorf("found %d diodes matching 2n", len(found))
+	}
+	if _, err := lib.Get("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of an unknown part: %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	lib, _ := Bundled()
+	c := &circuit.Circuit{Components: []circuit.Component{
+		{ID: "B1", Type: circuit.Battery, Nodes: []string{"vcc", "0"}, Properties: map[string]interface{}{"part": "6LR61"}},
+		{ID: "R1", Type: circuit.Resistor, Value: 330, Nodes: []string{"vcc", "a"}},
+		{ID: "D1", Type: circuit.LED, Nodes: []string{"a", "0"}, Properties: map[string]interface{}{"part": "LED-5MM-RED", "color": "orange"}},
+	}}
+	resolved, err := lib.Resolve(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Components[0].Value != 0 || len(c.Components[2].Properties) != 2 {
+		t.Error("Resolve changed the original circuit")
+	}
+	b, d := resolved.Components[0], resolved.Components[2]
+	if b.Value != 9 || b.Text("chemistry") != "alkaline" {
+		t.Errorf("battery = %+v", b)
+	}
+	if d.Properties["forwardVoltage"] != 2.0 || d.Text("color") != "orange" {
+		t.Errorf("LED = %+v", d)
+	}
+
+	sol, err := circuit.Solve(resolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := sol.Currents["R1"]; i < 0.015 || i > 0.025 {
+		t.Errorf("LED current = %v", i)
+	}
+
+	c.Components[2].Type = circuit.Diode
+	if _, err := lib.Resolve(c); err == nil {
+		t.Error("resolved an LED part onto a diode")
+	}
+	c.Components[2].Properties["part"] = "1N9999"
+	if _, err := lib.Resolve(c); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown part: %v", err)
+	}
+}
+
+const modelLibrary = `* vendor models
+.model BAT54 D(IS=2e-7 N=1.03 RS=2.1 BV=30)
+.model BC337 NPN(IS=4.13e-14 BF=292.4
++ VAF=100)
+.model SW1 SW(RON=1)
+`
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	lib := New()
+	if err := lib.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	added, err := lib.Import(strings.NewReader(modelLibrary), "../vendor", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 2 || added[0].Number != "BAT54" || added[0].Category != "diodes" || added[1].Category != "transistors" {
+		t.Fatalf("imported %+v", added)
+	}
+	if gain := added[1].ComponentProperties()["gain"]; gain != 292.4 {
+		t.Errorf("BC337 gain = %v", gain)
+	}
+
+	// the library is saved and read back on the next start
+	if _, err := os.Stat(filepath.Join(dir, "vendor.lib")); err != nil {
+		t.Fatal(err)
+	}
+	again := New()
+	if err := again.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := again.Get("bc337"); err != nil || p.Source != "vendor.lib" {
+		t.Errorf("reloaded BC337 = %+v, %v", p, err)
+	}
+
+	if _, err := lib.Import(strings.NewReader(".model X D(IS=abc)\n"), "bad.lib", ""); err == nil {
+		t.Error("imported a malformed model")
+	}
+	if err := lib.Add(Part{Number: "X", Element: "flux_capacitor"}); err == nil {
+		t.Error("added a part of an unknown element")
+	}
+}
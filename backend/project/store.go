@@ -0,0 +1,346 @@
+// Package project stores named breadboard projects.
+//
+// Each project is a save file (see package savefile) named after its ID in
+// the store's data directory. The project's name and description live in
+// the file's metadata, so a project directory can be copied or backed up
+// as plain files.
+package project
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"breadboard-simulator/breadboard"
+	"breadboard-simulator/savefile"
+)
+
+// ErrNotFound is returned for a project ID the store does not have.
+var ErrNotFound = errors.New("project not found")
+
+// ErrInvalidName is returned for an empty or overlong project name.
+var ErrInvalidName = errors.New("invalid project name")
+
+// maxNameLength bounds project names, in bytes.
+const maxNameLength = 200
+
+// Info summarizes a project for listings.
+type Info struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Created     time.Time `json:"created"`
+	Modified    time.Time `json:"modified"`
+}
+
+// Store is a place projects are kept. FileStore keeps them as files;
+// package storage keeps them in an embedded database with their history.
+type Store interface {
+	// List returns every project, most recently modified first.
+	List() ([]Info, error)
+	Get(id string) (*Project, error)
+	Create(name, description string, state breadboard.State) (*Info, error)
+	// Save replaces a project's breadboard.
+	Save(id string, state breadboard.State) (*Info, error)
+	Rename(id, name string) (*Info, error)
+	// Duplicate copies a project under a new name; an empty name means
+	// "Copy of" the original's.
+	Duplicate(id, name string) (*Info, error)
+	Delete(id string) error
+	// Import stores a save file as a new project named name, or by the
+	// file's own name when name is empty.
+	Import(f *savefile.File, name string) (*Info, error)
+}
+
+// Project is a stored project with its breadboard.
+type Project struct {
+	Info
+	File *savefile.File `json:"file"`
+}
+
+// NewInfo summarizes the project stored as f under id.
+func NewInfo(id string, f *savefile.File) Info {
+	return Info{
+		ID:          id,
+		Name:        f.Metadata.Name,
+		Description: f.Metadata.Description,
+		Created:     f.Created,
+		Modified:    f.Modified,
+	}
+}
+
+// FileStore keeps projects as JSON files in a directory. It is safe for
+// concurrent use; writes go to a temporary file that is renamed over the
+// old one, so a crash never leaves a half-written project.
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore opens the store in dir, creating the directory if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Dir returns the store's data directory.
+func (s *FileStore) Dir() string {
+	return s.dir
+}
+
+// List returns every project, most recently modified first.
+func (s *FileStore) List() ([]Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	projects := []Info{}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !ValidID(id) {
+			continue
+		}
+		f, err := s.read(id)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, NewInfo(id, f))
+	}
+	sort.SliceStable(projects, func(a, b int) bool {
+		if !projects[a].Modified.Equal(projects[b].Modified) {
+			return projects[a].Modified.After(projects[b].Modified)
+		}
+		return projects[a].Name < projects[b].Name
+	})
+	return projects, nil
+}
+
+// Get returns a project.
+func (s *FileStore) Get(id string) (*Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	return &Project{NewInfo(id, f), f}, nil
+}
+
+// Create stores a new project.
+func (s *FileStore) Create(name, description string, state breadboard.State) (*Info, error) {
+	name, err := CheckName(name)
+	if err != nil {
+		return nil, err
+	}
+	f := savefile.New(state, savefile.Metadata{Name: name, Description: description})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insert(f)
+}
+
+// Save replaces a project's breadboard.
+func (s *FileStore) Save(id string, state breadboard.State) (*Info, error) {
+	return s.update(id, func(f *savefile.File) error {
+		f.State = state
+		return nil
+	})
+}
+
+// Rename changes a project's name.
+func (s *FileStore) Rename(id, name string) (*Info, error) {
+	name, err := CheckName(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.update(id, func(f *savefile.File) error {
+		f.Metadata.Name = name
+		return nil
+	})
+}
+
+// Duplicate copies a project under a new name; an empty name means
+// "Copy of" the original's.
+func (s *FileStore) Duplicate(id, name string) (*Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = "Copy of " + f.Metadata.Name
+	}
+	if f.Metadata.Name, err = CheckName(name); err != nil {
+		return nil, err
+	}
+	copied := savefile.New(f.State, f.Metadata)
+	return s.insert(copied)
+}
+
+// Delete removes a project.
+func (s *FileStore) Delete(id string) error {
+	if !ValidID(id) {
+		return ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Import stores an existing save file as a new project named name.
+func (s *FileStore) Import(f *savefile.File, name string) (*Info, error) {
+	if name == "" {
+		name = f.Metadata.Name
+	}
+	name, err := CheckName(name)
+	if err != nil {
+		return nil, err
+	}
+	f.Metadata.Name = name
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insert(f)
+}
+
+func (s *FileStore) update(id string, change func(*savefile.File) error) (*Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := change(f); err != nil {
+		return nil, err
+	}
+	if err := s.write(id, f); err != nil {
+		return nil, err
+	}
+	in := NewInfo(id, f)
+	return &in, nil
+}
+
+// insert writes f under a fresh ID. The caller holds s.mu.
+func (s *FileStore) insert(f *savefile.File) (*Info, error) {
+	id, err := NewID()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.write(id, f); err != nil {
+		return nil, err
+	}
+	in := NewInfo(id, f)
+	return &in, nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// read loads a project, migrating older save formats. The caller holds
+// s.mu.
+func (s *FileStore) read(id string) (*savefile.File, error) {
+	if !ValidID(id) {
+		return nil, ErrNotFound
+	}
+	file, err := os.Open(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	f, err := savefile.Load(file)
+	if err != nil {
+		return nil, fmt.Errorf("project %s: %w", id, err)
+	}
+	return f, nil
+}
+
+// write saves a project atomically. The caller holds s.mu.
+func (s *FileStore) write(id string, f *savefile.File) error {
+	var buf bytes.Buffer
+	if err := savefile.Save(&buf, f); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path(id), buf.Bytes())
+}
+
+// writeFileAtomic writes data to a temporary file next to path, syncs it
+// and renames it into place.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// NewID returns a random 16-hex-digit project ID.
+func NewID() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// ValidID reports whether id has the form NewID produces, which also keeps
+// IDs from naming files outside the store.
+func ValidID(id string) bool {
+	if len(id) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
+
+// CheckName trims a project name and checks it is usable.
+func CheckName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return "", fmt.Errorf("%w: the name is empty", ErrInvalidName)
+	case len(name) > maxNameLength:
+		return "", fmt.Errorf("%w: the name is longer than %d bytes", ErrInvalidName, maxNameLength)
+	}
+	return name, nil
+}
@@ -0,0 +1,129 @@
+package project
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"breadboard-simulator/breadboard"
+)
+
+func testState(id string) breadboard.State {
+	return breadboard.State{Components: []breadboard.Component{{ID: id, Type: "resistor"}}}
+}
+
+func TestStoreLifecycle(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := s.Create(" Torch ", "LED and battery", testState("r1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "Torch" || !ValidID(a.ID) {
+		t.Errorf("created %+v", a)
+	}
+	b, err := s.Duplicate(a.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.ID == a.ID || b.Name != "Copy of Torch" || b.Description != "LED and battery" {
+		t.Errorf("duplicate %+v", b)
+	}
+
+	if _, err := s.Save(a.ID, testState("r2")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Rename(a.ID, "Night light"); err != nil {
+		t.Fatal(err)
+	}
+	p, err := s.Get(a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Night light" || p.File.State.Components[0].ID != "r2" {
+		t.Errorf("got %+v", p)
+	}
+	copied, _ := s.Get(b.ID)
+	if copied.File.State.Components[0].ID != "r1" {
+		t.Errorf("saving the original changed the copy")
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("list = %+v", list)
+	}
+
+	if err := s.Delete(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(b.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get deleted: err = %v", err)
+	}
+	if err := s.Delete(b.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete twice: err = %v", err)
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(s.Dir(), ".tmp-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestStoreRejectsBadInput(t *testing.T) {
+	s, _ := NewFileStore(t.TempDir())
+	if _, err := s.Create("  ", "", breadboard.State{}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("empty name: err = %v", err)
+	}
+	if _, err := s.Create(strings.Repeat("x", maxNameLength+1), "", breadboard.State{}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("long name: err = %v", err)
+	}
+	for _, id := range []string{"../../etc/passwd", "", "0123456789ABCDEF", "0123"} {
+		if _, err := s.Get(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q): err = %v, want ErrNotFound", id, err)
+		}
+	}
+}
+
+func TestStoreMigratesLegacyFiles(t *testing.T) {
+	dir := t.TempDir()
+	legacy := `{"components": [{"id": "bat", "type": "battery", "position": {"x": 0, "y": 0}}], "connections": []}`
+	if err := os.WriteFile(filepath.Join(dir, "00000000000000ff.json"), []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := NewFileStore(dir)
+	p, err := s.Get("00000000000000ff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.File.State.Components) != 1 {
+		t.Errorf("state = %+v", p.File.State)
+	}
+}
+
+func TestStoreConcurrentSaves(t *testing.T) {
+	s, _ := NewFileStore(t.TempDir())
+	in, _ := s.Create("p", "", breadboard.State{})
+	var wg sync.WaitGroup
+	for k := 0; k < 20; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Save(in.ID, testState("r")); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := s.Get(in.ID); err != nil {
+		t.Error(err)
+	}
+}
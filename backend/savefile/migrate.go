@@ -0,0 +1,122 @@
+package savefile
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A migration upgrades a decoded file by one schema version. Migrations
+// work on the generic JSON document rather than on File, so they keep
+// working however the Go types change later.
+type migration func(doc map[string]interface{}) (map[string]interface{}, error)
+
+// migrations[k] upgrades version k+1 to version k+2. Add a migration here,
+// bump SchemaVersion and publish a new schema.json whenever the format
+// changes.
+var migrations = []migration{
+	wrapState, // 1 -> 2
+}
+
+// Version reports the schema version of a decoded file. Files without a
+// schemaVersion field are the bare breadboard state saved before the
+// envelope, version 1.
+func Version(doc map[string]interface{}) (int, error) {
+	raw, ok := doc["schemaVersion"]
+	if !ok {
+		return 1, nil
+	}
+	var v float64
+	switch n := raw.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		if err != nil {
+			return 0, fmt.Errorf("invalid schemaVersion %q", n)
+		}
+		v = f
+	case float64:
+		v = n
+	default:
+		return 0, fmt.Errorf("invalid schemaVersion %v", raw)
+	}
+	if v != float64(int(v)) || v < 1 {
+		return 0, fmt.Errorf("invalid schemaVersion %v", v)
+	}
+	return int(v), nil
+}
+
+// Migrate upgrades a decoded file to SchemaVersion.
+func Migrate(doc map[string]interface{}) (map[string]interface{}, error) {
+	v, err := Version(doc)
+	if err != nil {
+		return nil, err
+	}
+	if v > SchemaVersion {
+		return nil, fmt.Errorf("save file has schema version %d; this version of the simulator reads up to %d", v, SchemaVersion)
+	}
+	for ; v < SchemaVersion; v++ {
+		doc, err = migrations[v-1](doc)
+		if err != nil {
+			return nil, fmt.Errorf("migrating save file from version %d: %w", v, err)
+		}
+		doc["schemaVersion"] = v + 1
+	}
+	return doc, nil
+}
+
+// wrapState moves a bare version 1 state into the envelope. Version 1
+// allowed connection endpoints written as "componentId:pin" strings and
+// numeric custom point IDs; version 2 only has endpoint objects and
+// string IDs.
+func wrapState(doc map[string]interface{}) (map[string]interface{}, error) {
+	if conns, ok := doc["connections"].([]interface{}); ok {
+		for k, c := range conns {
+			conn, ok := c.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("connection %d is not an object", k)
+			}
+			for _, end := range []string{"from", "to"} {
+				if s, ok := conn[end].(string); ok {
+					conn[end] = endpointObject(s)
+				} else if obj, ok := conn[end].(map[string]interface{}); ok {
+					if id, ok := obj["customPointId"]; ok {
+						obj["customPointId"] = idString(id)
+					}
+				}
+			}
+		}
+	}
+	if points, ok := doc["customConnectionPoints"].([]interface{}); ok {
+		for _, p := range points {
+			if point, ok := p.(map[string]interface{}); ok {
+				point["id"] = idString(point["id"])
+			}
+		}
+	}
+	return map[string]interface{}{
+		"appVersion": "",
+		"metadata":   map[string]interface{}{},
+		"state":      doc,
+	}, nil
+}
+
+func endpointObject(s string) map[string]interface{} {
+	obj := map[string]interface{}{"componentId": s, "pointIndex": 0}
+	if k := strings.LastIndex(s, ":"); k >= 0 {
+		if pin, err := strconv.Atoi(s[k+1:]); err == nil {
+			obj["componentId"], obj["pointIndex"] = s[:k], pin
+		}
+	}
+	return obj
+}
+
+func idString(id interface{}) interface{} {
+	switch v := id.(type) {
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return id
+}
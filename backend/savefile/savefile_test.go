@@ -0,0 +1,145 @@
+package savefile
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"breadboard-simulator/breadboard"
+)
+
+const legacy = `{
+  "components": [
+    {"id": "bat", "type": "battery", "position": {"x": 1, "y": 2}, "properties": {"voltage": 9}},
+    {"id": "r1", "type": "resistor", "position": {"x": 6, "y": 2}}
+  ],
+  "connections": [
+    {"from": "bat:0", "to": {"componentId": "r1", "pointIndex": 0}},
+    {"from": {"customPointId": 1700000000000, "pointIndex": 0}, "to": "r1:1"}
+  ],
+  "customConnectionPoints": [{"id": 1700000000000, "x": 40, "y": 60}]
+}`
+
+func TestLoadMigratesLegacyState(t *testing.T) {
+	f, err := Load(strings.NewReader(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.SchemaVersion != SchemaVersion {
+		t.Errorf("schemaVersion = %d, want %d", f.SchemaVersion, SchemaVersion)
+	}
+	s := f.State
+	if len(s.Components) != 2 || s.Components[0].ID != "bat" {
+		t.Fatalf("components = %+v", s.Components)
+	}
+	want := []breadboard.Connection{
+		{From: breadboard.Endpoint{ComponentID: "bat"}, To: breadboard.Endpoint{ComponentID: "r1"}},
+		{From: breadboard.Endpoint{CustomPointID: "1700000000000"}, To: breadboard.Endpoint{ComponentID: "r1", PointIndex: 1}},
+	}
+	for k, c := range want {
+		if s.Connections[k] != c {
+			t.Errorf("connection %d = %+v, want %+v", k, s.Connections[k], c)
+		}
+	}
+	if len(s.CustomConnectionPoints) != 1 || s.CustomConnectionPoints[0].ID != "1700000000000" {
+		t.Errorf("custom points = %+v", s.CustomConnectionPoints)
+	}
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	defer func(orig func() time.Time) { now = orig }(now)
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return created }
+
+	f, err := Load(strings.NewReader(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Metadata = Metadata{Name: "Torch", Tags: []string{"led"}}
+	var buf bytes.Buffer
+	if err := Save(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+
+	modified := created.Add(time.Hour)
+	now = func() time.Time { return modified }
+	g, err := Load(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := Save(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	h, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.Created.Equal(created) || !h.Modified.Equal(modified) {
+		t.Errorf("created %v modified %v, want %v and %v", h.Created, h.Modified, created, modified)
+	}
+	if h.AppVersion != AppVersion || h.Schema != SchemaID {
+		t.Errorf("appVersion %q $schema %q", h.AppVersion, h.Schema)
+	}
+	if h.Metadata.Name != "Torch" || len(h.State.Connections) != 2 {
+		t.Errorf("file = %+v", h)
+	}
+}
+
+func TestLoadRejectsNewerVersion(t *testing.T) {
+	_, err := Load(strings.NewReader(`{"schemaVersion": 99, "state": {}}`))
+	if err == nil || !strings.Contains(err.Error(), "99") {
+		t.Errorf("err = %v, want a newer-version error", err)
+	}
+	if _, err := Load(strings.NewReader(`{"schemaVersion": "two"}`)); err == nil {
+		t.Error("accepted a non-numeric schemaVersion")
+	}
+}
+
+func TestMigrationChainCoversEveryVersion(t *testing.T) {
+	if len(migrations) != SchemaVersion-1 {
+		t.Errorf("%d migrations for schema version %d", len(migrations), SchemaVersion)
+	}
+}
+
+// TestSchemaDescribesFile checks the published schema against what Save
+// writes, so the two are updated together.
+func TestSchemaDescribesFile(t *testing.T) {
+	var schema struct {
+		ID         string                     `json:"$id"`
+		Required   []string                   `json:"required"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(Schema, &schema); err != nil {
+		t.Fatalf("schema.json: %v", err)
+	}
+	if schema.ID != SchemaID {
+		t.Errorf("$id = %q, want %q", schema.ID, SchemaID)
+	}
+	var version struct {
+		Const int `json:"const"`
+	}
+	json.Unmarshal(schema.Properties["schemaVersion"], &version)
+	if version.Const != SchemaVersion {
+		t.Errorf("schema is for version %d, want %d", version.Const, SchemaVersion)
+	}
+
+	var buf bytes.Buffer
+	if err := Save(&buf, New(breadboard.State{}, Metadata{Name: "x"})); err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]interface{}
+	json.Unmarshal(buf.Bytes(), &saved)
+	for key := range saved {
+		if _, ok := schema.Properties[key]; !ok {
+			t.Errorf("saved field %q is not in the schema", key)
+		}
+	}
+	for _, key := range schema.Required {
+		if _, ok := saved[key]; !ok {
+			t.Errorf("required field %q is not saved", key)
+		}
+	}
+}
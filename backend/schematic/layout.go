@@ -0,0 +1,195 @@
+// Package schematic draws a circuit as a schematic diagram in SVG.
+//
+// Every net that a part's main terminals touch becomes a horizontal rail,
+// ordered top to bottom by voltage when a solution is given and by
+// distance from ground otherwise. Parts stand in columns between the rails
+// of their two main terminals; any further terminals, such as a
+// transistor's base, end in a label naming their net, and a label joins
+// every pin with that name.
+package schematic
+
+import (
+	"math"
+	"sort"
+
+	"breadboard-simulator/circuit"
+)
+
+// Layout dimensions, in SVG user units.
+const (
+	columnWidth  = 120
+	levelSpacing = 110
+	margin       = 40
+	railLabel    = 110 // room left of the first column for rail names
+	bodyHalf     = 30  // half the length of a symbol body
+)
+
+// Options controls rendering.
+type Options struct {
+	Title string
+	// Solution, when set, annotates rails with node voltages and parts
+	// with branch currents.
+	Solution *circuit.Solution
+}
+
+// part is a component placed on the diagram.
+type part struct {
+	comp   circuit.Component
+	sym    symbol
+	nodes  []string
+	column int
+	x      float64
+	top    int // level of the rail the upper main pin joins, or -1
+	bottom int // level of the lower main pin, or -1
+	flip   bool
+}
+
+// diagram is a laid-out circuit.
+type diagram struct {
+	parts  []*part
+	levels []string       // rail nets, top to bottom
+	level  map[string]int // net -> level
+	width  float64
+	height float64
+}
+
+func (d *diagram) railY(level int) float64 {
+	return margin + 30 + float64(level)*levelSpacing
+}
+
+// layout places the components of c.
+func layout(c *circuit.Circuit, sol *circuit.Solution) *diagram {
+	nodes := circuit.ComponentNodes(c)
+	d := &diagram{level: make(map[string]int)}
+
+	for _, comp := range c.Components {
+		d.parts = append(d.parts, &part{comp: comp, sym: symbolFor(comp), nodes: nodes[comp.ID], top: -1, bottom: -1})
+	}
+
+	// rails are the nets main pins connect to
+	seen := make(map[string]bool)
+	var rails []string
+	for _, p := range d.parts {
+		for _, k := range p.sym.main {
+			if net := p.net(k); net != "" && !seen[net] {
+				seen[net] = true
+				rails = append(rails, net)
+			}
+		}
+	}
+	order := railOrder(d.parts, rails, sol)
+	sort.SliceStable(rails, func(a, b int) bool { return order[rails[a]] > order[rails[b]] })
+	d.levels = rails
+	for k, net := range rails {
+		d.level[net] = k
+	}
+
+	for _, p := range d.parts {
+		if len(p.sym.main) == 2 {
+			a, b := p.net(p.sym.main[0]), p.net(p.sym.main[1])
+			la, lb := d.levelOf(a), d.levelOf(b)
+			p.flip = la >= 0 && lb >= 0 && la > lb || la < 0 && lb >= 0
+			p.top, p.bottom = la, lb
+			if p.flip {
+				p.top, p.bottom = lb, la
+			}
+		}
+	}
+
+	// parts spanning the top rails come first, label-only parts last
+	sort.SliceStable(d.parts, func(a, b int) bool {
+		pa, pb := d.parts[a], d.parts[b]
+		ka, kb := sortKey(pa), sortKey(pb)
+		if ka[0] != kb[0] {
+			return ka[0] < kb[0]
+		}
+		return ka[1] < kb[1]
+	})
+	for k, p := range d.parts {
+		p.column = k
+		p.x = margin + railLabel + float64(k)*columnWidth + columnWidth/2
+	}
+
+	levels := len(d.levels)
+	if levels < 2 {
+		levels = 2
+	}
+	d.width = margin*2 + railLabel + float64(len(d.parts))*columnWidth
+	d.height = d.railY(levels-1) + levelSpacing/2 + margin
+	return d
+}
+
+func sortKey(p *part) [2]int {
+	if p.top < 0 && p.bottom < 0 {
+		return [2]int{math.MaxInt32, math.MaxInt32}
+	}
+	top, bottom := p.top, p.bottom
+	if top < 0 {
+		top = bottom
+	}
+	if bottom < 0 {
+		bottom = top
+	}
+	return [2]int{top, bottom}
+}
+
+func (d *diagram) levelOf(net string) int {
+	if level, ok := d.level[net]; ok {
+		return level
+	}
+	return -1
+}
+
+// net returns the net on terminal k, or "" if it is unconnected.
+func (p *part) net(k int) string {
+	if k < len(p.nodes) {
+		return p.nodes[k]
+	}
+	return ""
+}
+
+// railOrder scores nets so that higher scores are drawn higher: the node
+// voltage when solved, otherwise the number of parts between the net and
+// ground, so supplies end up on top.
+func railOrder(parts []*part, rails []string, sol *circuit.Solution) map[string]float64 {
+	order := make(map[string]float64)
+	if sol != nil {
+		for _, net := range rails {
+			order[net] = sol.NodeVoltages[net]
+		}
+		return order
+	}
+
+	adjacent := make(map[string][]string)
+	for _, p := range parts {
+		if len(p.sym.main) != 2 {
+			continue
+		}
+		a, b := p.net(p.sym.main[0]), p.net(p.sym.main[1])
+		if a != "" && b != "" && a != b {
+			adjacent[a] = append(adjacent[a], b)
+			adjacent[b] = append(adjacent[b], a)
+		}
+	}
+	dist := map[string]int{"ground": 0}
+	queue := []string{"ground"}
+	for len(queue) > 0 {
+		net := queue[0]
+		queue = queue[1:]
+		for _, next := range adjacent[net] {
+			if _, ok := dist[next]; !ok {
+				dist[next] = dist[net] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	for k, net := range rails {
+		if d, ok := dist[net]; ok {
+			order[net] = float64(d)
+		} else {
+			// unreachable from ground: keep them above, in first-seen order
+			order[net] = float64(len(rails) + len(rails) - k)
+		}
+	}
+	return order
+}
@@ -0,0 +1,213 @@
+package schematic
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"breadboard-simulator/circuit"
+)
+
+// valueUnits gives the unit a part's value is labelled with.
+var valueUnits = map[circuit.ComponentType]string{
+	circuit.Resistor:      "Ω",
+	circuit.Capacitor:     "F",
+	circuit.Inductor:      "H",
+	circuit.Battery:       "V",
+	circuit.CurrentSource: "A",
+}
+
+const style = `<style>
+path, circle, rect, line { fill: none; stroke: #222; stroke-width: 1.5; stroke-linecap: round; stroke-linejoin: round; }
+.fill { fill: #222; }
+.dot { fill: #222; stroke: none; }
+.open { fill: #fff; }
+text { font-family: sans-serif; font-size: 11px; fill: #222; }
+.net { fill: #2458a6; }
+.current { fill: #b3261e; }
+.title { font-size: 14px; font-weight: bold; }
+</style>`
+
+// Render writes c as an SVG schematic.
+func Render(w io.Writer, c *circuit.Circuit, opts Options) error {
+	d := layout(c, opts.Solution)
+	r := &renderer{w: bufio.NewWriter(w), d: d, opts: opts}
+	r.render()
+	return r.w.Flush()
+}
+
+type renderer struct {
+	w    *bufio.Writer
+	d    *diagram
+	opts Options
+}
+
+// railSpan is the horizontal extent of a rail and the points on it where
+// leads join.
+type railSpan struct {
+	start, end float64
+	contacts   []float64
+}
+
+func (r *renderer) printf(format string, args ...interface{}) {
+	fmt.Fprintf(r.w, format, args...)
+}
+
+func (r *renderer) text(x, y float64, s, anchor, class string) {
+	r.printf(`<text x="%g" y="%g" text-anchor="%s"`, round(x), round(y), anchor)
+	if class != "" {
+		r.printf(` class="%s"`, class)
+	}
+	r.printf(`>%s</text>`+"\n", escape(s))
+}
+
+func (r *renderer) render() {
+	d := r.d
+	r.printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g">`+"\n",
+		d.width, d.height, d.width, d.height)
+	r.printf("%s\n", style)
+	if r.opts.Title != "" {
+		r.text(margin, margin-14, r.opts.Title, "start", "title")
+	}
+
+	spans := make([]railSpan, len(d.levels))
+	railStart := float64(margin + railLabel - 4)
+	for k := range spans {
+		spans[k] = railSpan{start: railStart, end: railStart}
+	}
+	touch := func(level int, x float64) {
+		if level < 0 {
+			return
+		}
+		spans[level].contacts = append(spans[level].contacts, x)
+		spans[level].end = math.Max(spans[level].end, x)
+	}
+
+	for _, p := range d.parts {
+		r.part(p, touch)
+	}
+
+	for k, net := range d.levels {
+		y := d.railY(k)
+		span := spans[k]
+		r.printf(`<line x1="%g" y1="%g" x2="%g" y2="%g"/>`+"\n", round(span.start), y, round(span.end), y)
+		for _, x := range span.contacts {
+			if x < span.end {
+				r.printf(`<circle cx="%g" cy="%g" r="3" class="dot"/>`+"\n", round(x), y)
+			}
+		}
+		label := net
+		if net == "ground" {
+			r.printf(`<path d="M%g,%g L%g,%g M%g,%g L%g,%g M%g,%g L%g,%g M%g,%g L%g,%g"/>`+"\n",
+				span.start, y, span.start, y+10,
+				span.start-10, y+10, span.start+10, y+10,
+				span.start-6, y+14, span.start+6, y+14,
+				span.start-2, y+18, span.start+2, y+18)
+		}
+		r.text(span.start-8, y-4, label, "end", "net")
+		if r.opts.Solution != nil {
+			r.text(span.start-8, y+10, circuit.FormatValue(r.opts.Solution.NodeVoltages[net], "V"), "end", "net")
+		}
+	}
+	r.printf("</svg>\n")
+}
+
+// part draws one part, its leads and its labels, reporting the rail
+// contacts it makes through touch.
+func (r *renderer) part(p *part, touch func(level int, x float64)) {
+	d := r.d
+	x := p.x
+	var cy float64
+	switch {
+	case p.top >= 0 && p.bottom >= 0 && p.top != p.bottom:
+		cy = (d.railY(p.top) + d.railY(p.bottom)) / 2
+	case p.top >= 0:
+		cy = d.railY(p.top) + levelSpacing/2
+	case p.bottom >= 0:
+		cy = d.railY(p.bottom) - levelSpacing/2
+	default:
+		cy = d.railY(0) + levelSpacing/2
+	}
+	sign := 1.0
+	if p.flip {
+		sign = -1
+	}
+
+	shapes, texts := p.sym.body(p.comp)
+	r.printf(`<g transform="translate(%g,%g) scale(1,%g)">%s</g>`+"\n", round(x), round(cy), sign, shapes)
+	for _, t := range texts {
+		r.text(x+t.x, cy+sign*t.y, t.s, t.anchor, "")
+	}
+
+	// leads from the main pins to their rails
+	if len(p.sym.main) == 2 {
+		upper, lower := cy-bodyHalf, cy+bodyHalf
+		switch {
+		case p.top >= 0 && p.top == p.bottom:
+			// both ends on one net: loop the lower lead back up
+			rail := d.railY(p.top)
+			side := x + columnWidth/2 - 12
+			r.printf(`<path d="M%g,%g L%g,%g M%g,%g L%g,%g L%g,%g L%g,%g"/>`+"\n",
+				x, upper, x, rail, x, lower, x, lower+10, side, lower+10, side, rail)
+			touch(p.top, x)
+			touch(p.top, side)
+		default:
+			for _, end := range []struct {
+				level int
+				y     float64
+			}{{p.top, upper}, {p.bottom, lower}} {
+				if end.level < 0 {
+					r.printf(`<circle cx="%g" cy="%g" r="3" class="open"/>`+"\n", round(x), round(end.y))
+					continue
+				}
+				r.printf(`<line x1="%g" y1="%g" x2="%g" y2="%g"/>`+"\n", round(x), round(end.y), round(x), d.railY(end.level))
+				touch(end.level, x)
+			}
+		}
+	}
+
+	// labels on the remaining pins
+	for k, pn := range p.sym.pins {
+		if !pn.label {
+			continue
+		}
+		net := p.net(k)
+		if net == "" {
+			net = "nc"
+		}
+		lx, anchor := x+pn.x-4, "end"
+		if pn.x > 0 {
+			lx, anchor = x+pn.x+4, "start"
+		}
+		r.text(lx, cy+sign*pn.y+4, net, anchor, "net")
+	}
+
+	// name, value and current to the right of the body
+	label := p.comp.ID
+	if unit, ok := valueUnits[p.comp.Type]; ok {
+		label += " " + circuit.FormatValue(p.comp.Value, unit)
+	}
+	r.text(x+22, cy-4, label, "start", "")
+	if r.opts.Solution != nil {
+		if i, ok := r.opts.Solution.Currents[p.comp.ID]; ok && len(p.sym.main) == 2 {
+			arrow := "↓"
+			if (i < 0) != p.flip {
+				arrow = "↑"
+			}
+			r.text(x+22, cy+10, arrow+" "+circuit.FormatValue(math.Abs(i), "A"), "start", "current")
+		}
+	}
+}
+
+func round(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
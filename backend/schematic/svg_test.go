@@ -0,0 +1,85 @@
+package schematic
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"breadboard-simulator/circuit"
+)
+
+var divider = &circuit.Circuit{Components: []circuit.Component{
+	{ID: "V1", Type: circuit.Battery, Value: 9, Nodes: []string{"vcc", "0"}},
+	{ID: "R1", Type: circuit.Resistor, Value: 4700, Nodes: []string{"vcc", "mid"}},
+	{ID: "R2", Type: circuit.Resistor, Value: 2200, Nodes: []string{"mid", "0"}},
+	{ID: "D1", Type: circuit.LED, Nodes: []string{"0", "mid"}},
+}}
+
+func render(t *testing.T, c *circuit.Circuit, opts Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Render(&buf, c, opts); err != nil {
+		t.Fatal(err)
+	}
+	// the output must be well-formed XML
+	dec := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid SVG: %v\n%s", err, buf.String())
+		}
+	}
+	return buf.String()
+}
+
+func TestLayoutOrdersRails(t *testing.T) {
+	d := layout(divider, nil)
+	want := []string{"vcc", "mid", "ground"}
+	if strings.Join(d.levels, " ") != strings.Join(want, " ") {
+		t.Errorf("rails = %v, want %v", d.levels, want)
+	}
+	for _, p := range d.parts {
+		// the reversed LED is drawn upside down between mid and ground
+		if p.comp.ID == "D1" && (!p.flip || p.top != 1 || p.bottom != 2) {
+			t.Errorf("D1 placed at %d-%d flip=%v", p.top, p.bottom, p.flip)
+		}
+	}
+}
+
+func TestRenderAnnotations(t *testing.T) {
+	sol, err := circuit.Solve(divider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := render(t, divider, Options{Title: "Divider & LED", Solution: sol})
+	for _, want := range []string{
+		"Divider &amp; LED",
+		">R1 4.7kΩ<",
+		">V1 9V<",
+		">2.87V<", // mid
+		"↓ 1.3mA", // through R1
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("SVG lacks %q", want)
+		}
+	}
+	if plain := render(t, divider, Options{}); strings.Contains(plain, `class="current"`) {
+		t.Error("currents drawn without a solution")
+	}
+}
+
+func TestRenderLabelsExtraPins(t *testing.T) {
+	c := &circuit.Circuit{Components: []circuit.Component{
+		{ID: "Q1", Type: circuit.Transistor, Nodes: []string{"vcc", "base", "0"}},
+		{ID: "U1", Type: "opamp", Nodes: []string{"in", "fb", "out"}},
+	}}
+	out := render(t, c, Options{})
+	for _, want := range []string{">base<", ">in<", ">fb<", ">out<"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("SVG lacks net label %q", want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package schematic
+
+import (
+	"fmt"
+
+	"breadboard-simulator/circuit"
+)
+
+// pin is a terminal of a symbol, relative to the centre of its body.
+// Label pins end in a net label instead of joining a rail.
+type pin struct {
+	x, y  float64
+	label bool
+}
+
+// text is a label drawn with a symbol. Its position follows the symbol
+// when it is flipped, but it is never drawn upside down.
+type text struct {
+	x, y   float64
+	s      string
+	anchor string
+}
+
+// symbol is how a part is drawn. Main lists the terminals that join
+// rails, upper first; body draws the symbol with its upper main pin at
+// (0, -bodyHalf) and its lower one at (0, bodyHalf).
+type symbol struct {
+	pins []pin
+	main []int
+	body func(comp circuit.Component) (string, []text)
+}
+
+var twoTerminal = []pin{{0, -bodyHalf, false}, {0, bodyHalf, false}}
+
+func fixed(shapes string, texts ...text) func(circuit.Component) (string, []text) {
+	return func(circuit.Component) (string, []text) { return shapes, texts }
+}
+
+var (
+	resistorSymbol = symbol{twoTerminal, []int{0, 1}, fixed(
+		`<path d="M0,-30 L0,-24 L8,-20 L-8,-12 L8,-4 L-8,4 L8,12 L-8,20 L0,24 L0,30"/>`)}
+
+	inductorSymbol = symbol{twoTerminal, []int{0, 1}, fixed(
+		`<path d="M0,-30 L0,-20 A5,5 0 0 1 0,-10 A5,5 0 0 1 0,0 A5,5 0 0 1 0,10 A5,5 0 0 1 0,20 L0,30"/>`)}
+
+	batterySymbol = symbol{twoTerminal, []int{0, 1}, fixed(
+		`<path d="M0,-30 L0,-6 M-16,-6 L16,-6 M0,6 L0,30"/><path d="M-8,6 L8,6" stroke-width="4"/>`,
+		text{12, -12, "+", "start"})}
+
+	currentSourceSymbol = symbol{twoTerminal, []int{0, 1}, fixed(
+		`<path d="M0,-30 L0,-16 M0,16 L0,30"/><circle r="16"/><path d="M0,10 L0,-10 M-5,-4 L0,-10 L5,-4"/>`)}
+
+	diodeSymbol = symbol{twoTerminal, []int{0, 1}, fixed(
+		`<path d="M0,-30 L0,-8 M0,8 L0,30 M-10,8 L10,8"/><path d="M-10,-8 L10,-8 L0,8 Z" class="fill"/>`)}
+
+	ledSymbol = symbol{twoTerminal, []int{0, 1}, fixed(
+		`<path d="M0,-30 L0,-8 M0,8 L0,30 M-10,8 L10,8"/><path d="M-10,-8 L10,-8 L0,8 Z" class="fill"/>` +
+			`<path d="M12,-4 L22,-12 M18,-12 L22,-12 L22,-8 M12,4 L22,-4 M18,-4 L22,-4 L22,0"/>`)}
+
+	wireSymbol = symbol{twoTerminal, []int{0, 1}, fixed(`<path d="M0,-30 L0,30"/>`)}
+
+	opAmpSymbol = symbol{
+		pins: []pin{{-40, -12, true}, {-40, 12, true}, {40, 0, true}},
+		body: fixed(`<path d="M-25,-25 L-25,25 L25,0 Z M-40,-12 L-25,-12 M-40,12 L-25,12 M25,0 L40,0"/>`,
+			text{-21, -8, "+", "start"}, text{-21, 16, "−", "start"}),
+	}
+)
+
+// symbolFor picks the symbol for a part. A part whose type is "opamp" is
+// drawn as an op-amp, with nodes in+, in- and out.
+func symbolFor(comp circuit.Component) symbol {
+	switch comp.Type {
+	case circuit.Resistor:
+		return resistorSymbol
+	case circuit.Capacitor:
+		return capacitorSymbol(comp.Text("capacitorType") == "electrolytic")
+	case circuit.Inductor:
+		return inductorSymbol
+	case circuit.Battery:
+		return batterySymbol
+	case circuit.CurrentSource:
+		return currentSourceSymbol
+	case circuit.Diode:
+		return diodeSymbol
+	case circuit.LED:
+		return ledSymbol
+	case circuit.Wire:
+		return wireSymbol
+	case circuit.Transistor:
+		return transistorSymbol(comp.Text("transistorType") == "pnp")
+	case circuit.Mosfet:
+		return mosfetSymbol(comp.Text("mosfetType") == "pmos")
+	case circuit.VCVS, circuit.VCCS, circuit.CCCS, circuit.CCVS:
+		return controlledSourceSymbol(comp)
+	case "opamp":
+		return opAmpSymbol
+	}
+	return boxSymbol(comp)
+}
+
+func capacitorSymbol(polarized bool) symbol {
+	if !polarized {
+		return symbol{twoTerminal, []int{0, 1}, fixed(
+			`<path d="M0,-30 L0,-4 M-14,-4 L14,-4 M-14,4 L14,4 M0,4 L0,30"/>`)}
+	}
+	return symbol{twoTerminal, []int{0, 1}, fixed(
+		`<path d="M0,-30 L0,-4 M-14,-4 L14,-4 M-14,8 Q0,0 14,8 M0,4 L0,30"/>`,
+		text{10, -10, "+", "start"})}
+}
+
+func transistorSymbol(pnp bool) symbol {
+	arrow := `<path d="M-3,13 L0,14 L-1,10" class="fill"/>` // out of the emitter
+	if pnp {
+		arrow = `<path d="M-5,4 L-8,6 L-4,9" class="fill"/>` // into the base
+	}
+	return symbol{
+		pins: []pin{{0, -bodyHalf, false}, {-40, 0, true}, {0, bodyHalf, false}},
+		main: []int{0, 2},
+		body: fixed(`<circle r="18"/><path d="M-8,-12 L-8,12 M-40,0 L-8,0 M-8,-6 L0,-14 L0,-30 M-8,6 L0,14 L0,30"/>` + arrow),
+	}
+}
+
+func mosfetSymbol(pmos bool) symbol {
+	arrow := `<path d="M-2,0 L4,-3 L4,3 Z" class="fill"/>`
+	if pmos {
+		arrow = `<path d="M10,0 L4,-3 L4,3 Z" class="fill"/>`
+	}
+	return symbol{
+		pins: []pin{{0, -bodyHalf, false}, {-40, 0, true}, {0, bodyHalf, false}, {40, 0, true}},
+		main: []int{0, 2},
+		body: fixed(`<path d="M-40,0 L-12,0 M-12,-12 L-12,12 M-6,-14 L-6,-6 M-6,-4 L-6,4 M-6,6 L-6,14` +
+			` M-6,-10 L0,-10 L0,-30 M-6,10 L0,10 L0,30 M-6,0 L12,0 L12,10 L0,10 M12,0 L40,0"/>` + arrow),
+	}
+}
+
+func controlledSourceSymbol(comp circuit.Component) symbol {
+	shapes := `<path d="M0,-30 L0,-16 M0,16 L0,30 M0,-16 L16,0 L0,16 L-16,0 Z"/>`
+	var texts []text
+	if comp.Type == circuit.VCVS || comp.Type == circuit.CCVS {
+		texts = append(texts, text{0, -4, "+", "middle"}, text{0, 10, "−", "middle"})
+	} else {
+		shapes += `<path d="M0,10 L0,-10 M-4,-5 L0,-10 L4,-5"/>`
+	}
+
+	pins := append([]pin{}, twoTerminal...)
+	if comp.Type == circuit.VCVS || comp.Type == circuit.VCCS {
+		pins = append(pins, pin{-40, -10, true}, pin{-40, 10, true})
+		shapes += `<path d="M-40,-10 L-20,-10 M-40,10 L-20,10"/>`
+	} else if control, ok := comp.Properties["control"]; ok {
+		texts = append(texts, text{-20, 4, fmt.Sprintf("i(%v)", control), "end"})
+	}
+	body := fixed(shapes, texts...)
+	return symbol{pins: pins, main: []int{0, 1}, body: body}
+}
+
+// boxSymbol draws a part with no standard symbol as a labelled box: its
+// first two terminals join rails, any others are labelled on the left.
+func boxSymbol(comp circuit.Component) symbol {
+	pins := append([]pin{}, twoTerminal...)
+	shapes := `<path d="M0,-30 L0,-18 M0,18 L0,30"/><rect x="-18" y="-18" width="36" height="36"/>`
+	for k := 2; k < len(comp.Nodes); k++ {
+		y := -12 + float64(k-2)*12
+		pins = append(pins, pin{-40, y, true})
+		shapes += fmt.Sprintf(`<path d="M-40,%g L-18,%g"/>`, y, y)
+	}
+	return symbol{pins, []int{0, 1}, fixed(shapes, text{0, 4, string(comp.Type), "middle"})}
+}
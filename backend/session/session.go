@@ -0,0 +1,247 @@
+// Package session gives each client of the server its own working
+// breadboard.
+//
+// A client is identified by a random token, sent back on every request in
+// the breadboard_session cookie or, for clients that cannot use cookies
+// across origins, the X-Session-Token header. The server answers with the
+// token in both whenever it issues a new one. Tokens are stored only as
+// hashes, and sessions idle for longer than the manager's timeout are
+// deleted.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"breadboard-simulator/breadboard"
+	"breadboard-simulator/history"
+	"breadboard-simulator/storage"
+)
+
+// Names of the cookie and header that carry the session token.
+const (
+	CookieName = "breadboard_session"
+	HeaderName = "X-Session-Token"
+)
+
+// touchInterval is how stale a session's last-seen time may get before a
+// request updates it, to spare the database a write per request.
+const touchInterval = time.Minute
+
+type contextKey struct{}
+
+// Manager issues sessions and keeps them in a store.
+type Manager struct {
+	store storage.Store
+	idle  time.Duration
+	now   func() time.Time
+	mu    sync.Mutex // serializes read-modify-write updates of sessions
+}
+
+// NewManager returns a manager whose sessions expire after being idle
+// for the given time.
+func NewManager(store storage.Store, idle time.Duration) *Manager {
+	return &Manager{store: store, idle: idle, now: time.Now}
+}
+
+// key derives a session's storage key from its token.
+func key(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newToken() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:]), nil
+}
+
+// requestToken returns the token a request carries, preferring the header.
+func requestToken(r *http.Request) string {
+	if token := r.Header.Get(HeaderName); token != "" {
+		return token
+	}
+	if c, err := r.Cookie(CookieName); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// Handler runs next within the request's session, starting a new one when
+// the request has none or its session has expired.
+func (m *Manager) Handler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		k, err := m.resume(w, requestToken(r))
+		if errors.Is(err, storage.ErrNotFound) {
+			k, err = m.start(w)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, k)))
+	}
+}
+
+// resume finds the session for token and marks it as seen, sending the
+// cookie again so its expiry follows the session's.
+func (m *Manager) resume(w http.ResponseWriter, token string) (string, error) {
+	if token == "" {
+		return "", storage.ErrNotFound
+	}
+	k := key(token)
+	sess, err := m.store.Session(k)
+	if err != nil {
+		return "", err
+	}
+	t := m.now()
+	if t.Sub(sess.LastSeen) > m.idle {
+		m.store.DeleteSession(k)
+		return "", storage.ErrNotFound
+	}
+	if t.Sub(sess.LastSeen) > touchInterval {
+		sess.LastSeen = t
+		if err := m.store.SaveSession(k, sess); err != nil {
+			return "", err
+		}
+		m.setCookie(w, token)
+	}
+	return k, nil
+}
+
+// start creates a session and hands its token to the client.
+func (m *Manager) start(w http.ResponseWriter) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	k := key(token)
+	t := m.now()
+	if err := m.store.SaveSession(k, &storage.Session{Created: t, LastSeen: t}); err != nil {
+		return "", err
+	}
+	m.setCookie(w, token)
+	w.Header().Set(HeaderName, token)
+	return k, nil
+}
+
+// setCookie sends token in a cookie that lasts as long as an idle session.
+func (m *Manager) setCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(m.idle / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// requestKey returns the key of the session Handler attached to r.
+func requestKey(r *http.Request) (string, error) {
+	k, ok := r.Context().Value(contextKey{}).(string)
+	if !ok {
+		return "", errors.New("request has no session")
+	}
+	return k, nil
+}
+
+// State returns the breadboard of the request's session.
+func (m *Manager) State(r *http.Request) (breadboard.State, error) {
+	k, err := requestKey(r)
+	if err != nil {
+		return breadboard.State{}, err
+	}
+	sess, err := m.store.Session(k)
+	if err != nil {
+		return breadboard.State{}, err
+	}
+	return sess.State, nil
+}
+
+// SetState saves a breadboard into the request's session, recording it
+// as a revision in the session's history.
+func (m *Manager) SetState(r *http.Request, state breadboard.State) error {
+	return m.update(r, func(sess *storage.Session) error {
+		sess.History.Commit(state, m.now(), "")
+		return nil
+	})
+}
+
+// History returns the history of the request's session.
+func (m *Manager) History(r *http.Request) (*history.History, error) {
+	k, err := requestKey(r)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := m.store.Session(k)
+	if err != nil {
+		return nil, err
+	}
+	return &sess.History, nil
+}
+
+// Move changes the head of the session's history with move, such as
+// (*history.History).Undo, and makes the revision it returns the session's
+// breadboard.
+func (m *Manager) Move(r *http.Request, move func(h *history.History) (*history.Revision, error)) (*history.Revision, error) {
+	var rev *history.Revision
+	err := m.update(r, func(sess *storage.Session) error {
+		var err error
+		rev, err = move(&sess.History)
+		return err
+	})
+	return rev, err
+}
+
+// update applies change to the request's session and stores it, keeping
+// the session's breadboard at the head of its history.
+func (m *Manager) update(r *http.Request, change func(sess *storage.Session) error) error {
+	k, err := requestKey(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sess, err := m.store.Session(k)
+	if err != nil {
+		return err
+	}
+	if err := change(sess); err != nil {
+		return err
+	}
+	if cur := sess.History.Current(); cur != nil {
+		sess.State = cur.State
+	}
+	sess.LastSeen = m.now()
+	return m.store.SaveSession(k, sess)
+}
+
+// Cleanup deletes idle sessions every interval until ctx is done.
+func (m *Manager) Cleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := m.store.ExpireSessions(m.now().Add(-m.idle))
+			if err != nil {
+				log.Printf("Expiring sessions: %v\n", err)
+			} else if n > 0 {
+				log.Printf("Expired %d idle sessions\n", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"breadboard-simulator/breadboard"
+	"breadboard-simulator/history"
+	"breadboard-simulator/storage"
+)
+
+func newTestManager(t *testing.T) (*Manager, *time.Time) {
+	t.Helper()
+	db, err := storage.Open(filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	clock := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	m := NewManager(db, time.Hour)
+	m.now = func() time.Time { return clock }
+	return m, &clock
+}
+
+// server saves the request body as the session's breadboard on POST and
+// returns the breadboard on GET.
+func server(m *Manager) http.HandlerFunc {
+	return m.Handler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var state breadboard.State
+			json.NewDecoder(r.Body).Decode(&state)
+			if err := m.SetState(r, state); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		state, err := m.State(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(state)
+	})
+}
+
+func do(h http.HandlerFunc, method, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/api/load", strings.NewReader(body))
+	if token != "" {
+		r.Header.Set(HeaderName, token)
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func TestSessionsAreIsolated(t *testing.T) {
+	m, _ := newTestManager(t)
+	h := server(m)
+
+	alice := do(h, "GET", "", "").Header().Get(HeaderName)
+	bob := do(h, "GET", "", "").Header().Get(HeaderName)
+	if alice == "" || bob == "" || alice == bob {
+		t.Fatalf("tokens %q and %q", alice, bob)
+	}
+
+	do(h, "POST", alice, `{"components": [{"id": "r1", "type": "resistor"}]}`)
+	if got := do(h, "GET", alice, "").Body.String(); !strings.Contains(got, "r1") {
+		t.Errorf("alice's breadboard = %s", got)
+	}
+	if got := do(h, "GET", bob, "").Body.String(); strings.Contains(got, "r1") {
+		t.Errorf("bob sees alice's breadboard: %s", got)
+	}
+
+	w := do(h, "GET", alice, "")
+	if w.Header().Get(HeaderName) != "" || len(w.Result().Cookies()) != 0 {
+		t.Error("a known session was issued a new token")
+	}
+}
+
+func TestCookieCarriesSession(t *testing.T) {
+	m, _ := newTestManager(t)
+	h := server(m)
+	cookies := do(h, "GET", "", "").Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != CookieName || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %+v", cookies)
+	}
+
+	r := httptest.NewRequest("POST", "/api/save", strings.NewReader(`{"components": [{"id": "led"}]}`))
+	r.AddCookie(cookies[0])
+	h(httptest.NewRecorder(), r)
+
+	r = httptest.NewRequest("GET", "/api/load", nil)
+	r.AddCookie(cookies[0])
+	w := httptest.NewRecorder()
+	h(w, r)
+	if !strings.Contains(w.Body.String(), "led") {
+		t.Errorf("breadboard = %s", w.Body.String())
+	}
+}
+
+func TestIdleSessionsExpire(t *testing.T) {
+	m, clock := newTestManager(t)
+	h := server(m)
+	token := do(h, "GET", "", "").Header().Get(HeaderName)
+	do(h, "POST", token, `{"components": [{"id": "r1"}]}`)
+
+	*clock = clock.Add(50 * time.Minute)
+	w := do(h, "GET", token, "")
+	if w.Header().Get(HeaderName) != "" {
+		t.Fatal("session expired before its idle timeout")
+	}
+	// the cookie is renewed along with the session
+	if c := w.Result().Cookies(); len(c) != 1 || c[0].Value != token || c[0].MaxAge != 3600 {
+		t.Errorf("cookies on resume = %+v", c)
+	}
+	*clock = clock.Add(50 * time.Minute)
+	if w := do(h, "GET", token, ""); w.Header().Get(HeaderName) != "" {
+		t.Fatal("using a session did not keep it alive")
+	}
+
+	*clock = clock.Add(2 * time.Hour)
+	w = do(h, "GET", token, "")
+	if w.Header().Get(HeaderName) == "" || strings.Contains(w.Body.String(), "r1") {
+		t.Errorf("expired session was resumed: %s", w.Body.String())
+	}
+}
+
+func TestUnknownTokenGetsFreshSession(t *testing.T) {
+	m, _ := newTestManager(t)
+	w := do(server(m), "GET", "made-up-token", "")
+	if token := w.Header().Get(HeaderName); token == "" || token == "made-up-token" {
+		t.Errorf("token = %q, want a newly issued one", token)
+	}
+}
+
+func TestSavesRecordHistory(t *testing.T) {
+	m, _ := newTestManager(t)
+	h := server(m)
+	token := do(h, "GET", "", "").Header().Get(HeaderName)
+	do(h, "POST", token, `{"components": [{"id": "r1"}]}`)
+	do(h, "POST", token, `{"components": [{"id": "r1"}, {"id": "r2"}]}`)
+
+	undo := m.Handler(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := m.Move(r, (*history.History).Undo); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+		}
+	})
+	if w := do(undo, "POST", token, ""); w.Code != http.StatusOK {
+		t.Fatalf("undo: %d %s", w.Code, w.Body)
+	}
+	if got := do(h, "GET", token, "").Body.String(); strings.Contains(got, "r2") {
+		t.Errorf("after undo the breadboard is %s", got)
+	}
+	if w := do(undo, "POST", token, ""); w.Code != http.StatusConflict {
+		t.Errorf("undo past the first save: %d", w.Code)
+	}
+}
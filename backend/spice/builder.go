@@ -0,0 +1,457 @@
+package spice
+
+import (
+	"strings"
+
+	"breadboard-simulator/circuit"
+)
+
+// directiveCards are analysis and output cards kept verbatim.
+var directiveCards = map[string]bool{
+	".op": true, ".tran": true, ".ac": true, ".dc": true, ".noise": true,
+	".options": true, ".option": true, ".temp": true, ".print": true,
+	".plot": true, ".probe": true, ".save": true, ".ic": true,
+	".nodeset": true, ".meas": true, ".measure": true, ".global": true,
+}
+
+// thermalVoltage at room temperature, used to estimate a diode's forward
+// voltage from its saturation current.
+const thermalVoltage = 0.02585
+
+type builder struct {
+	deck       *Deck
+	subckts    map[string]*subckt
+	modelCards []card
+	global     *scope
+}
+
+// collect sorts the top-level cards into parameters, models, subcircuit
+// definitions and directives, returning the element cards to instantiate.
+func (b *builder) collect(cards []card) ([]card, error) {
+	var top []card
+	var stack []*subckt
+	inControl := false
+
+	for _, cd := range cards {
+		kw := cd.keyword()
+
+		if inControl {
+			b.deck.Directives = append(b.deck.Directives, cd.text)
+			if kw == ".endc" {
+				inControl = false
+			}
+			continue
+		}
+
+		switch {
+		case kw == ".subckt":
+			s, err := newSubckt(cd)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, s)
+		case kw == ".ends":
+			if len(stack) == 0 {
+				return nil, cd.errorf(".ends without .subckt")
+			}
+			s := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			b.subckts[strings.ToLower(s.name)] = s
+		case kw == ".model":
+			b.modelCards = append(b.modelCards, cd)
+		case len(stack) > 0:
+			// element and .param cards belong to the open definition
+			stack[len(stack)-1].cards = append(stack[len(stack)-1].cards, cd)
+		case kw == ".param":
+			if err := b.global.define(cd); err != nil {
+				return nil, err
+			}
+		case kw == ".title":
+			b.deck.Title = strings.TrimSpace(cd.text[len(".title"):])
+		case kw == ".control":
+			inControl = true
+			b.deck.Directives = append(b.deck.Directives, cd.text)
+		case directiveCards[kw]:
+			b.deck.Directives = append(b.deck.Directives, cd.text)
+		case strings.HasPrefix(kw, "."):
+			return nil, cd.errorf("unsupported control card %s", cd.tokens[0])
+		default:
+			top = append(top, cd)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, &Error{File: cards[len(cards)-1].file, Line: cards[len(cards)-1].line,
+			Msg: "missing .ends for subcircuit " + stack[len(stack)-1].name}
+	}
+	return top, nil
+}
+
+func newSubckt(cd card) (*subckt, error) {
+	if len(cd.tokens) < 2 {
+		return nil, cd.errorf(".subckt needs a name")
+	}
+	s := &subckt{name: cd.tokens[1], params: make(map[string]string)}
+	ports, params := splitParams(cd.tokens[2:])
+	for _, port := range ports {
+		if strings.EqualFold(port, "params:") {
+			break
+		}
+		s.ports = append(s.ports, port)
+	}
+	for key, value := range params {
+		s.params[key] = value
+	}
+	return s, nil
+}
+
+// model parses a .model card: .model name type(param=value ...).
+func (b *builder) model(cd card) error {
+	if len(cd.tokens) < 3 {
+		return cd.errorf(".model needs a name and a type")
+	}
+	m := Model{
+		Name:   cd.tokens[1],
+		Type:   strings.ToLower(cd.tokens[2]),
+		Params: make(map[string]float64),
+	}
+	_, params := splitParams(cd.tokens[3:])
+	for key, raw := range params {
+		v, err := b.global.value(raw)
+		if err != nil {
+			return cd.errorf("model %s parameter %s: %v", m.Name, key, err)
+		}
+		m.Params[key] = v
+	}
+	b.deck.Models[strings.ToLower(m.Name)] = m
+	return nil
+}
+
+// instantiate adds the element cards of one level of the hierarchy.
+// prefix is prepended to element and internal net names; ports maps the
+// subcircuit's port names (lower-cased) to the nets they connect to.
+func (b *builder) instantiate(cards []card, prefix string, ports map[string]string, sc *scope, depth int) error {
+	for _, cd := range cards {
+		if cd.keyword() == ".param" {
+			if err := sc.define(cd); err != nil {
+				return err
+			}
+		}
+	}
+	for _, cd := range cards {
+		if cd.keyword() == ".param" {
+			continue
+		}
+		if err := b.element(cd, prefix, ports, sc, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *builder) element(cd card, prefix string, ports map[string]string, sc *scope, depth int) error {
+	positional, params := splitParams(cd.tokens)
+	if len(positional) == 0 {
+		return cd.errorf("expected an element name")
+	}
+	name := positional[0]
+	net := func(node string) string {
+		if mapped, ok := ports[strings.ToLower(node)]; ok {
+			return mapped
+		}
+		if n := strings.ToLower(node); n == "0" || n == "gnd" || n == "ground" {
+			return "0"
+		}
+		return prefix + node
+	}
+	need := func(n int, usage string) error {
+		if len(positional) < n {
+			return cd.errorf("%s: expected %s", name, usage)
+		}
+		return nil
+	}
+	value := func(raw string) (float64, error) {
+		v, err := sc.value(raw)
+		if err != nil {
+			return 0, cd.errorf("%s: %v", name, err)
+		}
+		return v, nil
+	}
+
+	comp := circuit.Component{ID: prefix + name, Properties: make(map[string]interface{})}
+
+	switch letter := strings.ToLower(name[:1]); letter {
+	case "r", "c", "l":
+		if err := need(3, "two nodes and a value"); err != nil {
+			return err
+		}
+		raw := params[letter]
+		if len(positional) > 3 {
+			raw = positional[3]
+		}
+		if raw == "" {
+			return cd.errorf("%s: missing value", name)
+		}
+		v, err := value(raw)
+		if err != nil {
+			return err
+		}
+		comp.Type = map[string]circuit.ComponentType{"r": circuit.Resistor, "c": circuit.Capacitor, "l": circuit.Inductor}[letter]
+		comp.Value = v
+		comp.Nodes = []string{net(positional[1]), net(positional[2])}
+		if ic, ok := params["ic"]; ok {
+			v, err := value(ic)
+			if err != nil {
+				return err
+			}
+			if letter == "c" {
+				comp.Properties["initialVoltage"] = v
+			} else {
+				comp.Properties["initialCurrent"] = v
+			}
+		}
+
+	case "v", "i":
+		if err := need(3, "two nodes"); err != nil {
+			return err
+		}
+		dc, err := b.source(cd, positional[3:], comp.Properties, value)
+		if err != nil {
+			return err
+		}
+		comp.Value = dc
+		if letter == "v" {
+			comp.Type = circuit.Battery
+			comp.Nodes = []string{net(positional[1]), net(positional[2])}
+		} else {
+			// SPICE current flows into the source at n+ and out at n-,
+			// the simulator's current source pushes out of its first node
+			comp.Type = circuit.CurrentSource
+			comp.Nodes = []string{net(positional[2]), net(positional[1])}
+		}
+
+	case "d":
+		if err := need(4, "two nodes and a model"); err != nil {
+			return err
+		}
+		m, err := b.lookupModel(cd, positional[3], "d")
+		if err != nil {
+			return err
+		}
+		comp.Type = m.ElementType()
+		comp.Nodes = []string{net(positional[1]), net(positional[2])}
+		applyModel(&comp, m)
+
+	case "q":
+		if err := need(5, "collector, base, emitter and a model"); err != nil {
+			return err
+		}
+		nodeCount := len(positional) - 2
+		if nodeCount > 4 {
+			nodeCount = 4
+		}
+		m, err := b.lookupModel(cd, positional[1+nodeCount], "npn", "pnp")
+		if err != nil {
+			return err
+		}
+		comp.Type = circuit.Transistor
+		for _, node := range positional[1 : 1+nodeCount] {
+			comp.Nodes = append(comp.Nodes, net(node))
+		}
+		applyModel(&comp, m)
+
+	case "m":
+		if err := need(6, "drain, gate, source, bulk and a model"); err != nil {
+			return err
+		}
+		m, err := b.lookupModel(cd, positional[5], "nmos", "pmos")
+		if err != nil {
+			return err
+		}
+		comp.Type = circuit.Mosfet
+		for _, node := range positional[1:5] {
+			comp.Nodes = append(comp.Nodes, net(node))
+		}
+		applyModel(&comp, m)
+		for _, key := range []string{"w", "l"} {
+			if raw, ok := params[key]; ok {
+				v, err := value(raw)
+				if err != nil {
+					return err
+				}
+				comp.Properties[key] = v
+			}
+		}
+
+	case "e", "g":
+		if err := need(6, "two output nodes, two controlling nodes and a gain"); err != nil {
+			return err
+		}
+		gain, err := value(positional[5])
+		if err != nil {
+			return err
+		}
+		comp.Type = circuit.VCVS
+		if letter == "g" {
+			comp.Type = circuit.VCCS
+		}
+		comp.Value = gain
+		for _, node := range positional[1:5] {
+			comp.Nodes = append(comp.Nodes, net(node))
+		}
+
+	case "f", "h":
+		if err := need(5, "two nodes, a controlling source and a gain"); err != nil {
+			return err
+		}
+		gain, err := value(positional[4])
+		if err != nil {
+			return err
+		}
+		comp.Type = circuit.CCCS
+		if letter == "h" {
+			comp.Type = circuit.CCVS
+		}
+		comp.Value = gain
+		comp.Nodes = []string{net(positional[1]), net(positional[2])}
+		comp.Properties["control"] = prefix + positional[3]
+
+	case "x":
+		return b.subcircuitInstance(cd, positional, params, prefix, net, sc, depth)
+
+	default:
+		return cd.errorf("unsupported element %s", name)
+	}
+
+	if len(comp.Properties) == 0 {
+		comp.Properties = nil
+	}
+	b.deck.Circuit.Components = append(b.deck.Circuit.Components, comp)
+	return nil
+}
+
+// source reads the value part of a V or I card: a DC value, an AC
+// specification and/or a transient waveform, which is kept as text.
+func (b *builder) source(cd card, spec []string, props map[string]interface{}, value func(string) (float64, error)) (float64, error) {
+	dc, haveDC := 0.0, false
+	for k := 0; k < len(spec); k++ {
+		switch kw := strings.ToLower(spec[k]); kw {
+		case "dc":
+			if k+1 >= len(spec) {
+				return 0, cd.errorf("%s: DC needs a value", cd.tokens[0])
+			}
+			v, err := value(spec[k+1])
+			if err != nil {
+				return 0, err
+			}
+			dc, haveDC = v, true
+			k++
+		case "ac":
+			magnitude := 1.0
+			if k+1 < len(spec) {
+				if v, err := ParseNumber(spec[k+1]); err == nil {
+					magnitude = v
+					k++
+					if k+1 < len(spec) {
+						if phase, err := ParseNumber(spec[k+1]); err == nil {
+							props["acPhase"] = phase
+							k++
+						}
+					}
+				}
+			}
+			props["acMagnitude"] = magnitude
+		case "sin", "pulse", "pwl", "exp", "sffm", "am":
+			end := k + 1
+			for end < len(spec) && !isSourceKeyword(spec[end]) {
+				end++
+			}
+			args := spec[k+1 : end]
+			props["waveform"] = strings.ToUpper(kw) + "(" + strings.Join(args, " ") + ")"
+			if !haveDC && len(args) > 0 {
+				first := args[0]
+				if kw == "pwl" && len(args) > 1 {
+					first = args[1]
+				}
+				if v, err := value(first); err == nil {
+					dc = v
+				}
+			}
+			k = end - 1
+		default:
+			v, err := value(spec[k])
+			if err != nil {
+				return 0, err
+			}
+			dc, haveDC = v, true
+		}
+	}
+	return dc, nil
+}
+
+func isSourceKeyword(tok string) bool {
+	switch strings.ToLower(tok) {
+	case "dc", "ac", "sin", "pulse", "pwl", "exp", "sffm", "am":
+		return true
+	}
+	return false
+}
+
+func (b *builder) lookupModel(cd card, name string, types ...string) (Model, error) {
+	m, ok := b.deck.Models[strings.ToLower(name)]
+	if !ok {
+		return Model{}, cd.errorf("%s: unknown model %s", cd.tokens[0], name)
+	}
+	for _, t := range types {
+		if m.Type == t {
+			return m, nil
+		}
+	}
+	return Model{}, cd.errorf("%s: model %s is type %s, want %s", cd.tokens[0], name, m.Type, strings.Join(types, " or "))
+}
+
+// applyModel records the model on the component.
+func applyModel(comp *circuit.Component, m Model) {
+	for key, v := range m.Properties() {
+		comp.Properties[key] = v
+	}
+}
+
+func (b *builder) subcircuitInstance(cd card, positional []string, params map[string]string, prefix string, net func(string) string, sc *scope, depth int) error {
+	if len(positional) == 0 {
+		return cd.errorf("expected an element name")
+	}
+	name := positional[0]
+	if len(positional) < 2 {
+		return cd.errorf("%s: expected nodes and a subcircuit name", name)
+	}
+	if depth >= maxDepth {
+		return cd.errorf("%s: subcircuits nested too deeply", name)
+	}
+	subName := positional[len(positional)-1]
+	s, ok := b.subckts[strings.ToLower(subName)]
+	if !ok {
+		return cd.errorf("%s: unknown subcircuit %s", name, subName)
+	}
+	nodes := positional[1 : len(positional)-1]
+	if len(nodes) != len(s.ports) {
+		return cd.errorf("%s: subcircuit %s has %d ports, got %d nodes", name, s.name, len(s.ports), len(nodes))
+	}
+
+	ports := make(map[string]string)
+	for k, port := range s.ports {
+		ports[strings.ToLower(port)] = net(nodes[k])
+	}
+
+	inner := newScope(b.global)
+	for key, raw := range s.params {
+		inner.raw[key] = raw
+	}
+	for key, raw := range params {
+		v, err := sc.value(raw)
+		if err != nil {
+			return cd.errorf("%s: parameter %s: %v", name, key, err)
+		}
+		inner.vals[key] = v
+	}
+	return b.instantiate(s.cards, prefix+name+".", ports, inner, depth+1)
+}
@@ -0,0 +1,414 @@
+package spice
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"breadboard-simulator/circuit"
+)
+
+// Analysis selects the analysis card written at the end of an exported
+// deck.
+type Analysis struct {
+	Type   string  `json:"type"`             // "op" (default), "tran", "dc" or "ac"
+	Start  float64 `json:"start,omitempty"`  // sweep start or AC start frequency
+	Stop   float64 `json:"stop,omitempty"`   // stop time, sweep end or AC stop frequency
+	Step   float64 `json:"step,omitempty"`   // time step or sweep increment
+	Source string  `json:"source,omitempty"` // component swept by a DC analysis
+	Points int     `json:"points,omitempty"` // AC points per decade
+}
+
+// modelParams lists, per model type, the component properties copied onto
+// an exported .model card.
+var modelParams = map[string][]string{
+	"d":    {"is", "n", "rs", "bv", "ibv", "cjo", "vj", "m", "tt", "eg", "xti"},
+	"npn":  {"is", "bf", "br", "nf", "nr", "vaf", "var", "ikf", "rb", "rc", "re", "cje", "cjc", "tf", "tr"},
+	"nmos": {"level", "vto", "kp", "lambda", "gamma", "phi", "tox", "cgso", "cgdo"},
+}
+
+// exporter tracks the names given to elements, nets and models while a
+// deck is written.
+type exporter struct {
+	w        *bufio.Writer
+	c        *circuit.Circuit
+	nodes    map[string][]string
+	names    map[string]string // component ID -> element name
+	used     map[string]bool   // element names, lower-cased
+	nets     map[string]string // circuit net -> deck node
+	netsUsed map[string]bool
+	models   map[string]string // model name, lower-cased -> card
+	order    []string          // model names in the order first used
+}
+
+// Export writes c as a SPICE deck that Parse and common SPICE simulators
+// read back. Parts with no SPICE equivalent are listed as comments.
+func Export(w io.Writer, title string, c *circuit.Circuit, analysis Analysis) error {
+	if title == "" {
+		title = "breadboard circuit"
+	}
+	e := &exporter{
+		w:        bufio.NewWriter(w),
+		c:        c,
+		nodes:    circuit.ComponentNodes(c),
+		names:    make(map[string]string),
+		used:     make(map[string]bool),
+		nets:     map[string]string{"ground": "0"},
+		netsUsed: map[string]bool{"0": true},
+		models:   make(map[string]string),
+	}
+	for _, comp := range c.Components {
+		if letter := elementLetter(comp.Type); letter != "" {
+			e.names[comp.ID] = e.elementName(letter, comp.ID)
+		}
+	}
+
+	fmt.Fprintln(e.w, strings.ReplaceAll(title, "\n", " "))
+	for _, comp := range c.Components {
+		if err := e.element(comp); err != nil {
+			return err
+		}
+	}
+	for _, name := range e.order {
+		fmt.Fprintln(e.w, e.models[strings.ToLower(name)])
+	}
+	card, err := e.analysis(analysis)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.w, card)
+	fmt.Fprintln(e.w, ".end")
+	return e.w.Flush()
+}
+
+func elementLetter(t circuit.ComponentType) string {
+	switch t {
+	case circuit.Resistor:
+		return "R"
+	case circuit.Capacitor:
+		return "C"
+	case circuit.Inductor:
+		return "L"
+	case circuit.Battery, circuit.Wire:
+		return "V"
+	case circuit.CurrentSource:
+		return "I"
+	case circuit.Diode, circuit.LED:
+		return "D"
+	case circuit.Transistor:
+		return "Q"
+	case circuit.Mosfet:
+		return "M"
+	case circuit.VCVS:
+		return "E"
+	case circuit.VCCS:
+		return "G"
+	case circuit.CCCS:
+		return "F"
+	case circuit.CCVS:
+		return "H"
+	}
+	return ""
+}
+
+// elementName turns a component ID into a unique element name starting
+// with the letter SPICE uses for its type: "R1" stays "R1" while
+// "led-1" becomes "Dled_1".
+func (e *exporter) elementName(letter, id string) string {
+	name := sanitize(id)
+	if !strings.EqualFold(name[:1], letter) {
+		name = letter + name
+	}
+	return e.unique(name, e.used)
+}
+
+func (e *exporter) unique(name string, used map[string]bool) string {
+	candidate := name
+	for k := 2; used[strings.ToLower(candidate)]; k++ {
+		candidate = name + "_" + strconv.Itoa(k)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// sanitize replaces characters SPICE would split or misread.
+func sanitize(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '_' || r < 128 && (r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// node maps a circuit net to a deck node. Unconnected terminals each get a
+// floating node of their own.
+func (e *exporter) node(comp circuit.Component, k int) string {
+	nodes := e.nodes[comp.ID]
+	net := ""
+	if k < len(nodes) {
+		net = nodes[k]
+	}
+	if net == "" {
+		return e.unique("nc_"+sanitize(comp.ID), e.netsUsed)
+	}
+	if name, ok := e.nets[net]; ok {
+		return name
+	}
+	name := e.unique(sanitize(net), e.netsUsed)
+	e.nets[net] = name
+	return name
+}
+
+func (e *exporter) skip(comp circuit.Component, reason string) {
+	fmt.Fprintf(e.w, "* skipped %s: %s\n", comp.ID, reason)
+}
+
+func (e *exporter) element(comp circuit.Component) error {
+	name, ok := e.names[comp.ID]
+	if !ok {
+		e.skip(comp, fmt.Sprintf("no SPICE equivalent for %s", comp.Type))
+		return nil
+	}
+	count := len(e.nodes[comp.ID])
+
+	switch comp.Type {
+	case circuit.Resistor:
+		fmt.Fprintf(e.w, "%s %s %s %s\n", name, e.node(comp, 0), e.node(comp, 1), FormatNumber(comp.Value))
+
+	case circuit.Capacitor, circuit.Inductor:
+		card := fmt.Sprintf("%s %s %s %s", name, e.node(comp, 0), e.node(comp, 1), FormatNumber(comp.Value))
+		ic := "initialVoltage"
+		if comp.Type == circuit.Inductor {
+			ic = "initialCurrent"
+		}
+		if v, ok := comp.Float(ic); ok {
+			card += " IC=" + FormatNumber(v)
+		}
+		fmt.Fprintln(e.w, card)
+
+	case circuit.Wire:
+		fmt.Fprintf(e.w, "%s %s %s DC 0\n", name, e.node(comp, 0), e.node(comp, 1))
+
+	case circuit.Battery:
+		pos, neg := e.node(comp, 0), e.node(comp, 1)
+		r := circuit.InternalResistance(comp)
+		inner := neg
+		if r > 0 {
+			inner = e.unique(sanitize(name)+"_int", e.netsUsed)
+		}
+		fmt.Fprintf(e.w, "%s %s %s%s\n", name, pos, inner, sourceSpec(comp, circuit.OpenCircuitVoltage(comp)))
+		if r > 0 {
+			fmt.Fprintf(e.w, "%s %s %s %s\n", e.unique("R"+name+"_int", e.used), inner, neg, FormatNumber(r))
+		}
+
+	case circuit.CurrentSource:
+		// the simulator's source pushes current out of its first node,
+		// SPICE's out of its second
+		fmt.Fprintf(e.w, "%s %s %s%s\n", name, e.node(comp, 1), e.node(comp, 0), sourceSpec(comp, comp.Value))
+
+	case circuit.Diode, circuit.LED:
+		model := e.diodeModel(comp, name)
+		fmt.Fprintf(e.w, "%s %s %s %s\n", name, e.node(comp, 0), e.node(comp, 1), model)
+
+	case circuit.Transistor:
+		if count < 3 {
+			e.skip(comp, "a transistor needs collector, base and emitter nodes")
+			return nil
+		}
+		kind := "npn"
+		if comp.Text("transistorType") == "pnp" {
+			kind = "pnp"
+		}
+		defaults := make(map[string]float64)
+		if gain, ok := comp.Float("gain"); ok {
+			defaults["bf"] = gain
+		}
+		model := e.model(comp, name, kind, "npn", defaults)
+		nodes := []string{e.node(comp, 0), e.node(comp, 1), e.node(comp, 2)}
+		if count > 3 {
+			nodes = append(nodes, e.node(comp, 3))
+		}
+		fmt.Fprintf(e.w, "%s %s %s\n", name, strings.Join(nodes, " "), model)
+
+	case circuit.Mosfet:
+		if count < 3 {
+			e.skip(comp, "a MOSFET needs drain, gate and source nodes")
+			return nil
+		}
+		kind := "nmos"
+		if comp.Text("mosfetType") == "pmos" {
+			kind = "pmos"
+		}
+		model := e.model(comp, name, kind, "nmos", nil)
+		drain, gate, source := e.node(comp, 0), e.node(comp, 1), e.node(comp, 2)
+		bulk := source
+		if count > 3 {
+			bulk = e.node(comp, 3)
+		}
+		card := fmt.Sprintf("%s %s %s %s %s %s", name, drain, gate, source, bulk, model)
+		for _, key := range []string{"w", "l"} {
+			if v, ok := comp.Float(key); ok {
+				card += fmt.Sprintf(" %s=%s", strings.ToUpper(key), FormatNumber(v))
+			}
+		}
+		fmt.Fprintln(e.w, card)
+
+	case circuit.VCVS, circuit.VCCS:
+		if count < 4 {
+			e.skip(comp, "a controlled source needs two output and two controlling nodes")
+			return nil
+		}
+		fmt.Fprintf(e.w, "%s %s %s %s %s %s\n", name,
+			e.node(comp, 0), e.node(comp, 1), e.node(comp, 2), e.node(comp, 3), FormatNumber(comp.Value))
+
+	case circuit.CCCS, circuit.CCVS:
+		control, ok := e.names[fmt.Sprint(comp.Properties["control"])]
+		if !ok {
+			e.skip(comp, "its controlling source is not in the circuit")
+			return nil
+		}
+		fmt.Fprintf(e.w, "%s %s %s %s %s\n", name, e.node(comp, 0), e.node(comp, 1), control, FormatNumber(comp.Value))
+	}
+	return nil
+}
+
+// sourceSpec renders the value part of a V or I card.
+func sourceSpec(comp circuit.Component, dc float64) string {
+	spec := " DC " + FormatNumber(dc)
+	if magnitude, ok := comp.Float("acMagnitude"); ok {
+		spec += " AC " + FormatNumber(magnitude)
+		if phase, ok := comp.Float("acPhase"); ok {
+			spec += " " + FormatNumber(phase)
+		}
+	}
+	if waveform, ok := comp.Properties["waveform"].(string); ok && waveform != "" {
+		spec += " " + waveform
+	}
+	return spec
+}
+
+// diodeModel returns the model name for a diode or LED. Without imported
+// model parameters the saturation current is chosen so the junction drops
+// the forward voltage at 10 mA, the point Parse reads it back from.
+func (e *exporter) diodeModel(comp circuit.Component, name string) string {
+	if _, ok := comp.Float("is"); ok {
+		return e.model(comp, name, "d", "d", nil)
+	}
+	n := 1.0
+	if comp.Type == circuit.LED {
+		n = 2.0
+	}
+	rs := circuit.SeriesResistance(comp)
+	vf := circuit.ForwardVoltage(comp)
+	is := 0.01 / math.Exp(vf/(n*thermalVoltage))
+	return e.model(comp, name, "d", "d", map[string]float64{"is": is, "n": n, "rs": rs})
+}
+
+// model writes a .model card, once per name, and returns the name used.
+// Parameters come from defaults, overridden by matching component
+// properties.
+func (e *exporter) model(comp circuit.Component, element, kind, family string, defaults map[string]float64) string {
+	params := make(map[string]float64)
+	for key, v := range defaults {
+		params[key] = v
+	}
+	for _, key := range modelParams[family] {
+		if v, ok := comp.Float(key); ok {
+			params[key] = v
+		}
+	}
+
+	name, named := comp.Properties["model"].(string)
+	if !named || name == "" {
+		name = element + "_model"
+	}
+	name = sanitize(name)
+	if _, seen := e.models[strings.ToLower(name)]; seen {
+		return name
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fields := make([]string, len(keys))
+	for k, key := range keys {
+		fields[k] = strings.ToUpper(key) + "=" + FormatNumber(params[key])
+	}
+	e.models[strings.ToLower(name)] = fmt.Sprintf(".model %s %s(%s)", name, strings.ToUpper(kind), strings.Join(fields, " "))
+	e.order = append(e.order, name)
+	return name
+}
+
+func (e *exporter) analysis(a Analysis) (string, error) {
+	switch strings.ToLower(a.Type) {
+	case "", "op":
+		return ".op", nil
+	case "tran":
+		if a.Stop <= 0 {
+			return "", fmt.Errorf("transient analysis needs a stop time")
+		}
+		step := a.Step
+		if step <= 0 {
+			step = a.Stop / 1000
+		}
+		return fmt.Sprintf(".tran %s %s", FormatNumber(step), FormatNumber(a.Stop)), nil
+	case "dc":
+		source, ok := e.names[a.Source]
+		if !ok || !strings.ContainsAny(source[:1], "VvIi") {
+			return "", fmt.Errorf("DC sweep source %q is not a voltage or current source in the circuit", a.Source)
+		}
+		if a.Step == 0 {
+			return "", fmt.Errorf("DC sweep needs a step")
+		}
+		return fmt.Sprintf(".dc %s %s %s %s", source, FormatNumber(a.Start), FormatNumber(a.Stop), FormatNumber(a.Step)), nil
+	case "ac":
+		points, start, stop := a.Points, a.Start, a.Stop
+		if points <= 0 {
+			points = 10
+		}
+		if start <= 0 {
+			start = 1
+		}
+		if stop <= start {
+			stop = 1e6
+		}
+		return fmt.Sprintf(".ac dec %d %s %s", points, FormatNumber(start), FormatNumber(stop)), nil
+	}
+	return "", fmt.Errorf("unknown analysis %q", a.Type)
+}
+
+// exportScales are the scale factors FormatNumber writes, by exponent.
+var exportScales = map[int]string{
+	-15: "f", -12: "p", -9: "n", -6: "u", -3: "m", 0: "", 3: "k", 6: "meg", 9: "g", 12: "t",
+}
+
+// FormatNumber writes v with a SPICE scale factor and up to six
+// significant digits, e.g. 4700 is "4.7k" and 2.2e6 is "2.2meg".
+func FormatNumber(v float64) string {
+	if v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	exp := int(math.Floor(math.Log10(math.Abs(v))/3)) * 3
+	if exp < -15 || exp > 12 {
+		return strconv.FormatFloat(v, 'g', 6, 64)
+	}
+	mantissa, _ := strconv.ParseFloat(strconv.FormatFloat(v/math.Pow10(exp), 'g', 6, 64), 64)
+	if math.Abs(mantissa) >= 1000 && exp < 12 {
+		exp += 3
+		mantissa /= 1000
+	}
+	return strconv.FormatFloat(mantissa, 'f', -1, 64) + exportScales[exp]
+}
@@ -0,0 +1,83 @@
+package spice
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"breadboard-simulator/circuit"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	c := &circuit.Circuit{Components: []circuit.Component{
+		{ID: "battery-1", Type: circuit.Battery, Value: 9, Nodes: []string{"vcc", "gnd"},
+			Properties: map[string]interface{}{"internalResistance": 0.5}},
+		{ID: "R1", Type: circuit.Resistor, Value: 4700, Nodes: []string{"vcc", "mid"}},
+		{ID: "R2", Type: circuit.Resistor, Value: 2200, Nodes: []string{"mid", "gnd"}},
+		{ID: "led 1", Type: circuit.LED, Nodes: []string{"mid", "gnd"}},
+		{ID: "sw1", Type: "switch", Nodes: []string{"mid", "gnd"}},
+	}}
+	var buf bytes.Buffer
+	if err := Export(&buf, "divider", c, Analysis{Type: "tran", Step: 1e-6, Stop: 1e-3}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"Vbattery_1 vcc Vbattery_1_int DC 9\n",
+		"RVbattery_1_int Vbattery_1_int 0 500m\n",
+		"R1 vcc mid 4.7k\n",
+		"Dled_1 mid 0 Dled_1_model\n",
+		"* skipped sw1: no SPICE equivalent for switch\n",
+		".tran 1u 1m\n.end\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("exported deck lacks %q:\n%s", want, out)
+		}
+	}
+
+	deck, err := Parse(strings.NewReader(out), "")
+	if err != nil {
+		t.Fatalf("re-parsing export: %v\n%s", err, out)
+	}
+	original, err := circuit.Solve(&circuit.Circuit{Components: c.Components[:4]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := circuit.Solve(deck.Circuit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, b := original.NodeVoltages["mid"], reparsed.NodeVoltages["mid"]; !approxWithin(a, b, 0.05) {
+		t.Errorf("mid = %v after round trip, want %v", b, a)
+	}
+}
+
+func TestExportAnalysisErrors(t *testing.T) {
+	c := &circuit.Circuit{Components: []circuit.Component{
+		{ID: "R1", Type: circuit.Resistor, Value: 1, Nodes: []string{"a", "0"}},
+	}}
+	for _, a := range []Analysis{{Type: "tran"}, {Type: "dc", Source: "R1", Step: 1}, {Type: "noise"}} {
+		if err := Export(&bytes.Buffer{}, "", c, a); err == nil {
+			t.Errorf("Export with %+v succeeded", a)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := map[float64]string{
+		4700: "4.7k", 2.2e6: "2.2meg", 1e-3: "1m", 10e-6: "10u", 0: "0", -5: "-5", 1.5e-18: "1.5e-18",
+	}
+	for in, want := range tests {
+		if got := FormatNumber(in); got != want {
+			t.Errorf("FormatNumber(%v) = %q, want %q", in, got, want)
+		}
+		if got, err := ParseNumber(FormatNumber(in)); err != nil || !approxEqual(got, in) {
+			t.Errorf("ParseNumber(FormatNumber(%v)) = %v, %v", in, got, err)
+		}
+	}
+}
+
+func approxWithin(a, b, tol float64) bool {
+	d := a - b
+	return d <= tol && d >= -tol
+}
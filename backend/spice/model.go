@@ -0,0 +1,58 @@
+package spice
+
+import (
+	"math"
+	"strings"
+
+	"breadboard-simulator/circuit"
+)
+
+// ElementType returns the element a model is for, or "" for a model type
+// the simulator has no element for. A diode model whose name mentions LED
+// is taken to be an LED.
+func (m Model) ElementType() circuit.ComponentType {
+	switch m.Type {
+	case "d":
+		if strings.Contains(strings.ToLower(m.Name), "led") {
+			return circuit.LED
+		}
+		return circuit.Diode
+	case "npn", "pnp":
+		return circuit.Transistor
+	case "nmos", "pmos":
+		return circuit.Mosfet
+	}
+	return ""
+}
+
+// Properties returns the component properties that carry the model: its
+// name, its parameters, and the simulator's own parameters derived from
+// them, such as a diode's forward voltage or a transistor's gain.
+func (m Model) Properties() map[string]interface{} {
+	props := map[string]interface{}{"model": m.Name}
+	for key, v := range m.Params {
+		props[key] = v
+	}
+	switch m.Type {
+	case "d":
+		if is, ok := m.Params["is"]; ok && is > 0 {
+			n := m.Params["n"]
+			if n == 0 {
+				n = 1
+			}
+			// forward voltage at 10 mA from the Shockley equation
+			props["forwardVoltage"] = n * thermalVoltage * math.Log(0.01/is+1)
+		}
+		if rs, ok := m.Params["rs"]; ok && rs > 0 {
+			props["seriesResistance"] = rs
+		}
+	case "npn", "pnp":
+		props["transistorType"] = m.Type
+		if bf, ok := m.Params["bf"]; ok {
+			props["gain"] = bf
+		}
+	case "nmos", "pmos":
+		props["mosfetType"] = m.Type
+	}
+	return props
+}
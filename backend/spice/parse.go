@@ -0,0 +1,316 @@
+// Package spice reads SPICE netlists into the simulator's circuit types.
+//
+// The supported subset covers the element cards R, C, L, V, I, D, Q, M, E,
+// F, G, H and X, the control cards .subckt/.ends, .model, .param, .include
+// and .title, "+" continuation lines and "*", ";" and "$" comments.
+// Values may be expressions over .param and subcircuit parameters, such as
+// {2*R1}, evaluated as circuit.ParseExpr does but with SPICE's scale
+// factors.
+// Analysis and output cards (.op, .tran, .ac, .print...) are kept verbatim
+// in Deck.Directives; anything else is rejected with its line number.
+package spice
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"breadboard-simulator/circuit"
+)
+
+// maxDepth bounds .include and subcircuit nesting.
+const maxDepth = 32
+
+// Error reports a problem with one card of a deck.
+type Error struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// Model is a .model card. Type and parameter names are lower-cased.
+type Model struct {
+	Name   string             `json:"name"`
+	Type   string             `json:"type"`
+	Params map[string]float64 `json:"params"`
+}
+
+// Deck is a parsed netlist. Subcircuit instances are flattened into
+// Circuit with hierarchical names: element R1 of instance X1 becomes
+// "X1.R1" and its internal net "mid" becomes "X1.mid".
+type Deck struct {
+	Title      string             `json:"title"`
+	Circuit    *circuit.Circuit   `json:"circuit"`
+	Models     map[string]Model   `json:"models"`
+	Params     map[string]float64 `json:"params"`
+	Directives []string           `json:"directives"`
+}
+
+// Parser reads decks. Open resolves .include file names; by default they
+// are opened relative to the including file's directory.
+type Parser struct {
+	Open func(name string) (io.ReadCloser, error)
+}
+
+// Parse reads a deck whose first line is the title. name labels errors.
+func Parse(r io.Reader, name string) (*Deck, error) {
+	return (&Parser{}).Parse(r, name)
+}
+
+// ParseLibrary reads a model library: a file of .model, .subckt and
+// .param cards, such as a vendor's .lib, which unlike a deck has no title
+// line.
+func ParseLibrary(r io.Reader, name string) (*Deck, error) {
+	return (&Parser{}).ParseLibrary(r, name)
+}
+
+// ParseFile reads the deck at path.
+func ParseFile(path string) (*Deck, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f, path)
+}
+
+// card is one logical line: a physical line plus its continuations.
+type card struct {
+	file   string
+	line   int
+	text   string
+	tokens []string
+}
+
+func (cd card) errorf(format string, args ...interface{}) error {
+	return &Error{File: cd.file, Line: cd.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// keyword returns the lower-cased first token.
+func (cd card) keyword() string {
+	if len(cd.tokens) == 0 {
+		return ""
+	}
+	return strings.ToLower(cd.tokens[0])
+}
+
+type subckt struct {
+	name   string
+	ports  []string
+	params map[string]string
+	cards  []card
+}
+
+func (p *Parser) Parse(r io.Reader, name string) (*Deck, error) {
+	return p.parse(r, name, true)
+}
+
+// ParseLibrary reads a model library, which has no title line.
+func (p *Parser) ParseLibrary(r io.Reader, name string) (*Deck, error) {
+	return p.parse(r, name, false)
+}
+
+func (p *Parser) parse(r io.Reader, name string, hasTitle bool) (*Deck, error) {
+	title, cards, err := p.readCards(r, name, 0, hasTitle)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &builder{
+		deck: &Deck{
+			Title:   title,
+			Circuit: &circuit.Circuit{},
+			Models:  make(map[string]Model),
+			Params:  make(map[string]float64),
+		},
+		subckts: make(map[string]*subckt),
+		global:  newScope(nil),
+	}
+
+	top, err := b.collect(cards)
+	if err != nil {
+		return nil, err
+	}
+	for key := range b.global.raw {
+		v, err := b.global.lookup(key)
+		if err != nil {
+			return nil, err
+		}
+		b.deck.Params[key] = v
+	}
+	for _, cd := range b.modelCards {
+		if err := b.model(cd); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.instantiate(top, "", nil, b.global, 0); err != nil {
+		return nil, err
+	}
+	return b.deck, nil
+}
+
+// readCards splits a file into cards, joining continuation lines, dropping
+// comments and splicing in .include files.
+func (p *Parser) readCards(r io.Reader, file string, depth int, hasTitle bool) (string, []card, error) {
+	var title string
+	var cards []card
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if hasTitle && lineNo == 1 {
+			title = strings.TrimSpace(line)
+			continue
+		}
+
+		line = stripComment(line)
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "*") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "+") {
+			if len(cards) == 0 {
+				return "", nil, &Error{File: file, Line: lineNo, Msg: "continuation line without a card to continue"}
+			}
+			cards[len(cards)-1].text += " " + trimmed[1:]
+			continue
+		}
+		cards = append(cards, card{file: file, line: lineNo, text: trimmed})
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, err
+	}
+
+	var out []card
+	for _, cd := range cards {
+		cd.tokens = tokenize(cd.text)
+		switch cd.keyword() {
+		case ".end":
+			return title, out, nil
+		case ".include", ".inc":
+			included, err := p.include(cd, depth)
+			if err != nil {
+				return "", nil, err
+			}
+			out = append(out, included...)
+		default:
+			out = append(out, cd)
+		}
+	}
+	return title, out, nil
+}
+
+func (p *Parser) include(cd card, depth int) ([]card, error) {
+	if depth >= maxDepth {
+		return nil, cd.errorf(".include nested too deeply")
+	}
+	if len(cd.tokens) < 2 {
+		return nil, cd.errorf(".include needs a file name")
+	}
+	name := strings.Trim(cd.tokens[1], `"'`)
+
+	var rc io.ReadCloser
+	var err error
+	if p.Open != nil {
+		rc, err = p.Open(name)
+	} else {
+		path := name
+		if !filepath.IsAbs(path) && cd.file != "" {
+			path = filepath.Join(filepath.Dir(cd.file), name)
+		}
+		name = path
+		rc, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, cd.errorf("cannot include %q: %v", name, err)
+	}
+	defer rc.Close()
+
+	_, cards, err := p.readCards(rc, name, depth+1, false)
+	return cards, err
+}
+
+// stripComment removes ";" comments and ngspice's " $ " comments.
+func stripComment(line string) string {
+	if k := strings.Index(line, ";"); k >= 0 {
+		line = line[:k]
+	}
+	for k := 1; k < len(line); k++ {
+		if line[k] == '$' && (line[k-1] == ' ' || line[k-1] == '\t') {
+			return line[:k]
+		}
+	}
+	return line
+}
+
+// tokenize splits a card on whitespace, parentheses and commas, makes "="
+// a token of its own and keeps {expressions} and quoted strings whole.
+func tokenize(s string) []string {
+	var tokens []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	for k := 0; k < len(s); k++ {
+		ch := s[k]
+		switch {
+		case ch == '{' || ch == '"' || ch == '\'':
+			closer := byte('}')
+			if ch != '{' {
+				closer = ch
+			}
+			end := strings.IndexByte(s[k+1:], closer)
+			if end < 0 {
+				cur.WriteString(s[k:])
+				k = len(s)
+				continue
+			}
+			cur.WriteString(s[k : k+end+2])
+			k += end + 1
+		case ch == ' ' || ch == '\t' || ch == '(' || ch == ')' || ch == ',':
+			flush()
+		case ch == '=':
+			flush()
+			tokens = append(tokens, "=")
+		default:
+			cur.WriteByte(ch)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// splitParams separates positional tokens from key=value pairs, keyed by
+// lower-cased name.
+func splitParams(tokens []string) ([]string, map[string]string) {
+	var positional []string
+	params := make(map[string]string)
+	for k := 0; k < len(tokens); k++ {
+		if k+2 < len(tokens) && tokens[k+1] == "=" {
+			params[strings.ToLower(tokens[k])] = tokens[k+2]
+			k += 2
+			continue
+		}
+		if tokens[k] == "=" {
+			continue
+		}
+		positional = append(positional, tokens[k])
+	}
+	return positional, params
+}
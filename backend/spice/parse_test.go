@@ -0,0 +1,209 @@
+package spice
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"breadboard-simulator/circuit"
+)
+
+const divider = `Voltage divider with an LED stage
+* supply
+.param rtop=4.7k
+.param rbot={rtop}
+V1 vcc 0 DC 9 ; nine volts
+R1 vcc mid {rtop}
+R2 mid 0 rbot
+X1 mid 0 ledstage
++ 
+.subckt ledstage in gnd params: rs=330
+R1 in a {rs}
+D1 a gnd LED_RED
+.ends ledstage
+.model LED_RED D(IS=1e-20 N=1.8
++ RS=2)
+.op
+.end
+R9 ignored after end 1
+`
+
+func TestParseDeck(t *testing.T) {
+	deck, err := Parse(strings.NewReader(divider), "divider.cir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deck.Title != "Voltage divider with an LED stage" {
+		t.Errorf("title = %q", deck.Title)
+	}
+	if len(deck.Directives) != 1 || deck.Directives[0] != ".op" {
+		t.Errorf("directives = %q, want [.op]", deck.Directives)
+	}
+
+	comps := make(map[string]circuit.Component)
+	for _, comp := range deck.Circuit.Components {
+		comps[comp.ID] = comp
+	}
+	if len(comps) != 5 {
+		t.Fatalf("got %d components, want 5: %+v", len(comps), deck.Circuit.Components)
+	}
+	if r2 := comps["R2"]; r2.Value != 4700 || r2.Nodes[0] != "mid" || r2.Nodes[1] != "0" {
+		t.Errorf("R2 = %+v", r2)
+	}
+	if r := comps["X1.R1"]; r.Value != 330 || r.Nodes[0] != "mid" || r.Nodes[1] != "X1.a" {
+		t.Errorf("X1.R1 = %+v", r)
+	}
+	d := comps["X1.D1"]
+	if d.Type != circuit.LED || d.Nodes[1] != "0" || d.Properties["model"] != "LED_RED" {
+		t.Errorf("X1.D1 = %+v", d)
+	}
+	if vf, _ := d.Float("forwardVoltage"); vf < 1.5 || vf > 2.5 {
+		t.Errorf("LED forward voltage = %v, want about 2V", vf)
+	}
+
+	if _, err := circuit.Solve(deck.Circuit); err != nil {
+		t.Errorf("solving imported deck: %v", err)
+	}
+}
+
+func TestParseSources(t *testing.T) {
+	deck, err := Parse(strings.NewReader(`sources
+V1 in 0 SIN(0 1 1k)
+V2 b 0 PULSE(0 5 1u 1n 1n 1m 2m) AC 1
+I1 a 0 2m
+E1 out 0 in 0 10
+F1 out 0 V1 2
+Q1 c b e Q2N2222
+M1 d g s s NCH W=1u L=180n
+C1 a 0 10u IC=1
+L1 a b 1MEG
+.model Q2N2222 NPN(BF=200)
+.model NCH NMOS(VTO=0.7)
+`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	comps := make(map[string]circuit.Component)
+	for _, comp := range deck.Circuit.Components {
+		comps[comp.ID] = comp
+	}
+	if comps["V1"].Properties["waveform"] != "SIN(0 1 1k)" {
+		t.Errorf("V1 = %+v", comps["V1"])
+	}
+	if v2 := comps["V2"]; v2.Value != 0 || v2.Properties["acMagnitude"] != 1.0 {
+		t.Errorf("V2 = %+v", v2)
+	}
+	if i1 := comps["I1"]; i1.Type != circuit.CurrentSource || i1.Value != 2e-3 || i1.Nodes[0] != "0" {
+		t.Errorf("I1 = %+v", i1)
+	}
+	if e1 := comps["E1"]; e1.Type != circuit.VCVS || len(e1.Nodes) != 4 || e1.Value != 10 {
+		t.Errorf("E1 = %+v", e1)
+	}
+	if f1 := comps["F1"]; f1.Type != circuit.CCCS || f1.Properties["control"] != "V1" {
+		t.Errorf("F1 = %+v", f1)
+	}
+	if q1 := comps["Q1"]; q1.Type != circuit.Transistor || q1.Properties["transistorType"] != "npn" || q1.Properties["gain"] != 200.0 {
+		t.Errorf("Q1 = %+v", q1)
+	}
+	if m1 := comps["M1"]; m1.Type != circuit.Mosfet || len(m1.Nodes) != 4 || !approxEqual(m1.Properties["l"].(float64), 180e-9) {
+		t.Errorf("M1 = %+v", m1)
+	}
+	if c1 := comps["C1"]; !approxEqual(c1.Value, 10e-6) || c1.Properties["initialVoltage"] != 1.0 {
+		t.Errorf("C1 = %+v", c1)
+	}
+	if l1 := comps["L1"]; l1.Type != circuit.Inductor || l1.Value != 1e6 {
+		t.Errorf("L1 = %+v", l1)
+	}
+}
+
+func TestParseExpressions(t *testing.T) {
+	deck, err := Parse(strings.NewReader(`expressions
+.param R1=1k R2={2*R1} tau='R2*1u'
+R1 a 0 {R2/2 + 500}
+C1 a 0 {tau/R2}
+R2 a b {sqrt(R1*1M)}
+X1 b 0 half r={R2}
+.subckt half in out params: r=1
+R1 in out {r/2}
+.ends
+`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deck.Params["r2"] != 2000 || !approxEqual(deck.Params["tau"], 2e-3) {
+		t.Errorf("params = %v", deck.Params)
+	}
+	comps := make(map[string]circuit.Component)
+	for _, comp := range deck.Circuit.Components {
+		comps[comp.ID] = comp
+	}
+	// "M" is milli in SPICE, inside an expression too
+	for id, want := range map[string]float64{"R1": 1500, "C1": 1e-6, "R2": 1, "X1.R1": 1000} {
+		if !approxEqual(comps[id].Value, want) {
+			t.Errorf("%s = %v, want %v", id, comps[id].Value, want)
+		}
+	}
+}
+
+func TestParseInclude(t *testing.T) {
+	p := &Parser{Open: func(name string) (io.ReadCloser, error) {
+		if name != "models.lib" {
+			return nil, errors.New("not found")
+		}
+		return io.NopCloser(strings.NewReader(".model D1N4148 D(IS=2.52n N=1.752 RS=0.568)\n")), nil
+	}}
+	deck, err := p.Parse(strings.NewReader("include\n.include \"models.lib\"\nD1 a 0 D1N4148\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := deck.Models["d1n4148"]; !ok {
+		t.Errorf("included model missing: %v", deck.Models)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		deck string
+		want string
+	}{
+		{"t\nR1 a 0 1k\nK1 L1 L2 0.9\n", "line 3: unsupported element K1"},
+		{"t\n.lib models.lib tt\n", "line 2: unsupported control card .lib"},
+		{"t\nD1 a 0 NOPE\n", "line 2: D1: unknown model NOPE"},
+		{"t\nR1 a 0 {2*x}\n", "line 2: R1: 2*x: unknown name x"},
+		{"t\nR1 a 0 {2*}\n", `line 2: R1: expression "2*": unexpected end`},
+		{"t\n+ R1 a 0 1k\n", "line 2: continuation line without a card to continue"},
+		{"t\nX1 a b amp\n.subckt amp in\n.ends\n", "line 2: X1: subcircuit amp has 1 ports, got 2 nodes"},
+		{"t\n.subckt amp in out\nR1 in out 1k\n", "line 3: missing .ends for subcircuit amp"},
+		{"t\nx=5\n", "line 2: expected an element name"},
+		{"t\n=\n", "line 2: expected an element name"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.deck), "")
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.deck, err, tt.want)
+		}
+	}
+
+	// a library has no title line, so its first card is checked too
+	if _, err := ParseLibrary(strings.NewReader("x=5\n"), "lib"); err == nil || !strings.Contains(err.Error(), "expected an element name") {
+		t.Errorf("ParseLibrary error = %v", err)
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := map[string]float64{
+		"4.7k": 4700, "10uF": 10e-6, "2.2MEG": 2.2e6, "1M": 1e-3, "5V": 5, "1e-9": 1e-9, "10mil": 254e-6,
+	}
+	for in, want := range tests {
+		got, err := ParseNumber(in)
+		if err != nil || !approxEqual(got, want) {
+			t.Errorf("ParseNumber(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), math.Abs(b))
+}
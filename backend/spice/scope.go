@@ -0,0 +1,143 @@
+package spice
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"breadboard-simulator/circuit"
+)
+
+// scope holds .param definitions. Values are resolved on first use, so a
+// parameter may refer to one defined later in the deck; a subcircuit
+// instance gets its own scope on top of the global one.
+type scope struct {
+	parent    *scope
+	raw       map[string]string
+	cards     map[string]card
+	vals      map[string]float64
+	resolving map[string]bool
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{
+		parent:    parent,
+		raw:       make(map[string]string),
+		cards:     make(map[string]card),
+		vals:      make(map[string]float64),
+		resolving: make(map[string]bool),
+	}
+}
+
+// define records the assignments of a .param card.
+func (s *scope) define(cd card) error {
+	positional, params := splitParams(cd.tokens[1:])
+	if len(positional) > 0 || len(params) == 0 {
+		return cd.errorf(".param expects name=value assignments")
+	}
+	for key, raw := range params {
+		s.raw[key] = raw
+		s.cards[key] = cd
+		delete(s.vals, key)
+	}
+	return nil
+}
+
+// lookup resolves a parameter by name, searching enclosing scopes.
+func (s *scope) lookup(name string) (float64, error) {
+	key := strings.ToLower(name)
+	if v, ok := s.vals[key]; ok {
+		return v, nil
+	}
+	if raw, ok := s.raw[key]; ok {
+		if s.resolving[key] {
+			return 0, s.cards[key].errorf("parameter %s refers to itself", name)
+		}
+		s.resolving[key] = true
+		v, err := s.value(raw)
+		delete(s.resolving, key)
+		if err != nil {
+			if _, located := err.(*Error); located {
+				return 0, err
+			}
+			return 0, s.cards[key].errorf("parameter %s: %v", name, err)
+		}
+		s.vals[key] = v
+		return v, nil
+	}
+	if s.parent != nil {
+		return s.parent.lookup(name)
+	}
+	return 0, fmt.Errorf("%w %s", circuit.ErrUnknownName, name)
+}
+
+// value evaluates a card field: a number, a parameter name or an
+// expression such as "2*R1", optionally in braces or quotes.
+func (s *scope) value(raw string) (float64, error) {
+	text := strings.TrimSpace(raw)
+	if strings.HasPrefix(text, "{") && strings.HasSuffix(text, "}") {
+		text = strings.TrimSpace(text[1 : len(text)-1])
+	} else if len(text) >= 2 && (text[0] == '\'' || text[0] == '"') && text[len(text)-1] == text[0] {
+		text = strings.TrimSpace(text[1 : len(text)-1])
+	}
+
+	if v, err := ParseNumber(text); err == nil {
+		return v, nil
+	}
+	if isIdentifier(text) {
+		return s.lookup(text)
+	}
+	literal, err := plainNumbers(text)
+	if err != nil {
+		return 0, err
+	}
+	e, err := circuit.ParseExpr(literal)
+	if err != nil {
+		return 0, err
+	}
+	return e.Eval(s.lookup)
+}
+
+// plainNumbers rewrites the numbers of an expression without their SPICE
+// scale factors, which circuit.ParseExpr would read as a parts list does
+// ("1M" is mega there but milli here).
+func plainNumbers(text string) (string, error) {
+	var b strings.Builder
+	for k := 0; k < len(text); {
+		ch := text[k]
+		digit := ch >= '0' && ch <= '9' || ch == '.' && k+1 < len(text) && text[k+1] >= '0' && text[k+1] <= '9'
+		if !digit || (k > 0 && isNameByte(text[k-1])) {
+			b.WriteByte(ch)
+			k++
+			continue
+		}
+		end := k + len(numberPattern.FindString(text[k:]))
+		for end < len(text) && isNameByte(text[end]) {
+			end++
+		}
+		v, err := ParseNumber(text[k:end])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		k = end
+	}
+	return b.String(), nil
+}
+
+func isNameByte(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for k, ch := range s {
+		letter := ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+		if !letter && (k == 0 || ch < '0' || ch > '9') {
+			return false
+		}
+	}
+	return true
+}
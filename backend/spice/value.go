@@ -0,0 +1,55 @@
+package spice
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var numberPattern = regexp.MustCompile(`^[+-]?(?:\d+\.?\d*|\.\d+)(?:[eE][+-]?\d+)?`)
+
+// spiceScales are SPICE's case-insensitive scale factors. Unlike a parts
+// list, "m" is always milli; mega is spelled "meg".
+var spiceScales = map[byte]float64{
+	'f': 1e-15,
+	'p': 1e-12,
+	'n': 1e-9,
+	'u': 1e-6,
+	'm': 1e-3,
+	'k': 1e3,
+	'g': 1e9,
+	't': 1e12,
+}
+
+// ParseNumber parses a SPICE number such as "4.7k", "10uF", "2.2MEG" or
+// "1e-9". Letters after the scale factor are ignored, as SPICE does.
+func ParseNumber(s string) (float64, error) {
+	text := strings.TrimSpace(s)
+	loc := numberPattern.FindStringIndex(text)
+	if loc == nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	v, err := strconv.ParseFloat(text[:loc[1]], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+
+	suffix := strings.ToLower(text[loc[1]:])
+	suffix = strings.Replace(suffix, "µ", "u", 1)
+	switch {
+	case suffix == "":
+		return v, nil
+	case strings.HasPrefix(suffix, "meg"):
+		return v * 1e6, nil
+	case strings.HasPrefix(suffix, "mil"):
+		return v * 25.4e-6, nil
+	}
+	if scale, ok := spiceScales[suffix[0]]; ok {
+		return v * scale, nil
+	}
+	if suffix[0] >= 'a' && suffix[0] <= 'z' {
+		return v, nil // a bare unit such as "5V"
+	}
+	return 0, fmt.Errorf("invalid number %q", s)
+}
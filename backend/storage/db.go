@@ -0,0 +1,539 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"breadboard-simulator/breadboard"
+	"breadboard-simulator/project"
+	"breadboard-simulator/savefile"
+)
+
+// Top-level buckets. Projects holds each project's save file by ID;
+// revisions and results hold a sub-bucket per project, keyed by sequence
+// number; sessions holds each session by its key, and modules each library
+// module by its name.
+var (
+	projectsBucket  = []byte("projects")
+	revisionsBucket = []byte("revisions")
+	resultsBucket   = []byte("results")
+	sessionsBucket  = []byte("sessions")
+	modulesBucket   = []byte("modules")
+)
+
+// now is replaced in tests.
+var now = time.Now
+
+// DB is a Store kept in a single database file.
+type DB struct {
+	db *bolt.DB
+}
+
+var _ Store = (*DB)(nil)
+
+// Open opens the database at path, creating it if it does not exist. Only
+// one process can have a database open at a time.
+func Open(path string) (*DB, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{projectsBucket, revisionsBucket, resultsBucket, sessionsBucket, modulesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &DB{db: db}, nil
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// seqKey encodes a sequence number so keys sort in numeric order.
+func seqKey(n uint64) []byte {
+	var k [8]byte
+	binary.BigEndian.PutUint64(k[:], n)
+	return k[:]
+}
+
+func readProject(tx *bolt.Tx, id string) (*savefile.File, error) {
+	if !project.ValidID(id) {
+		return nil, project.ErrNotFound
+	}
+	data := tx.Bucket(projectsBucket).Get([]byte(id))
+	if data == nil {
+		return nil, project.ErrNotFound
+	}
+	f, err := savefile.Load(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("project %s: %w", id, err)
+	}
+	return f, nil
+}
+
+// writeProject stores f and, when newRevision is set, records its state
+// as the project's next revision.
+func writeProject(tx *bolt.Tx, id string, f *savefile.File, newRevision bool) (*project.Info, error) {
+	var buf bytes.Buffer
+	if err := savefile.Save(&buf, f); err != nil {
+		return nil, err
+	}
+	if err := tx.Bucket(projectsBucket).Put([]byte(id), buf.Bytes()); err != nil {
+		return nil, err
+	}
+	if newRevision {
+		revs, err := tx.Bucket(revisionsBucket).CreateBucketIfNotExists([]byte(id))
+		if err != nil {
+			return nil, err
+		}
+		n, err := revs.NextSequence()
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(Revision{Number: int(n), Parent: int(n) - 1, Created: f.Modified, State: f.State})
+		if err != nil {
+			return nil, err
+		}
+		if err := revs.Put(seqKey(n), data); err != nil {
+			return nil, err
+		}
+	}
+	info := project.NewInfo(id, f)
+	return &info, nil
+}
+
+func (d *DB) insert(f *savefile.File) (*project.Info, error) {
+	id, err := project.NewID()
+	if err != nil {
+		return nil, err
+	}
+	var info *project.Info
+	err = d.db.Update(func(tx *bolt.Tx) error {
+		info, err = writeProject(tx, id, f, true)
+		return err
+	})
+	return info, err
+}
+
+func (d *DB) update(id string, newRevision bool, change func(*savefile.File)) (*project.Info, error) {
+	var info *project.Info
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		f, err := readProject(tx, id)
+		if err != nil {
+			return err
+		}
+		change(f)
+		info, err = writeProject(tx, id, f, newRevision)
+		return err
+	})
+	return info, err
+}
+
+// List returns every project, most recently modified first.
+func (d *DB) List() ([]project.Info, error) {
+	projects := []project.Info{}
+	err := d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(projectsBucket).ForEach(func(k, v []byte) error {
+			f, err := savefile.Load(bytes.NewReader(v))
+			if err != nil {
+				return fmt.Errorf("project %s: %w", k, err)
+			}
+			projects = append(projects, project.NewInfo(string(k), f))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(projects, func(a, b int) bool {
+		if !projects[a].Modified.Equal(projects[b].Modified) {
+			return projects[a].Modified.After(projects[b].Modified)
+		}
+		return projects[a].Name < projects[b].Name
+	})
+	return projects, nil
+}
+
+// Get returns a project.
+func (d *DB) Get(id string) (*project.Project, error) {
+	var p *project.Project
+	err := d.db.View(func(tx *bolt.Tx) error {
+		f, err := readProject(tx, id)
+		if err != nil {
+			return err
+		}
+		p = &project.Project{Info: project.NewInfo(id, f), File: f}
+		return nil
+	})
+	return p, err
+}
+
+// Create stores a new project as its first revision.
+func (d *DB) Create(name, description string, state breadboard.State) (*project.Info, error) {
+	name, err := project.CheckName(name)
+	if err != nil {
+		return nil, err
+	}
+	return d.insert(savefile.New(state, savefile.Metadata{Name: name, Description: description}))
+}
+
+// Save replaces a project's breadboard, recording a new revision.
+func (d *DB) Save(id string, state breadboard.State) (*project.Info, error) {
+	return d.update(id, true, func(f *savefile.File) { f.State = state })
+}
+
+// Rename changes a project's name. It does not record a revision.
+func (d *DB) Rename(id, name string) (*project.Info, error) {
+	name, err := project.CheckName(name)
+	if err != nil {
+		return nil, err
+	}
+	return d.update(id, false, func(f *savefile.File) { f.Metadata.Name = name })
+}
+
+// Duplicate copies a project's current breadboard into a new project; the
+// copy's history starts afresh.
+func (d *DB) Duplicate(id, name string) (*project.Info, error) {
+	p, err := d.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = "Copy of " + p.Name
+	}
+	meta := p.File.Metadata
+	if meta.Name, err = project.CheckName(name); err != nil {
+		return nil, err
+	}
+	return d.insert(savefile.New(p.File.State, meta))
+}
+
+// Delete removes a project with its revisions and results.
+func (d *DB) Delete(id string) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		if _, err := readProject(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Bucket(projectsBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+		for _, name := range [][]byte{revisionsBucket, resultsBucket} {
+			b := tx.Bucket(name)
+			if b.Bucket([]byte(id)) != nil {
+				if err := b.DeleteBucket([]byte(id)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Import stores a save file as a new project.
+func (d *DB) Import(f *savefile.File, name string) (*project.Info, error) {
+	if name == "" {
+		name = f.Metadata.Name
+	}
+	name, err := project.CheckName(name)
+	if err != nil {
+		return nil, err
+	}
+	f.Metadata.Name = name
+	return d.insert(f)
+}
+
+// ImportFileStore copies the projects of a directory store, where they
+// were kept before the database, keeping their IDs so links to them still
+// work. A project already in the database is left as it is. It returns how
+// many projects were copied.
+func (d *DB) ImportFileStore(src *project.FileStore) (int, error) {
+	list, err := src.List()
+	if err != nil {
+		return 0, err
+	}
+	imported := 0
+	for _, info := range list {
+		p, err := src.Get(info.ID)
+		if err != nil {
+			return imported, err
+		}
+		err = d.db.Update(func(tx *bolt.Tx) error {
+			if tx.Bucket(projectsBucket).Get([]byte(p.ID)) != nil {
+				return nil
+			}
+			imported++
+			_, err := writeProject(tx, p.ID, p.File, true)
+			return err
+		})
+		if err != nil {
+			return imported, err
+		}
+	}
+	return imported, nil
+}
+
+// Revisions lists a project's revisions, oldest first.
+func (d *DB) Revisions(projectID string) ([]RevisionInfo, error) {
+	revisions := []RevisionInfo{}
+	err := d.db.View(func(tx *bolt.Tx) error {
+		if _, err := readProject(tx, projectID); err != nil {
+			return err
+		}
+		revs := tx.Bucket(revisionsBucket).Bucket([]byte(projectID))
+		if revs == nil {
+			return nil
+		}
+		return revs.ForEach(func(k, v []byte) error {
+			var r Revision
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			revisions = append(revisions, RevisionInfo{r.Number, r.Parent, r.Created, len(r.State.Components)})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// Revision returns one of a project's revisions.
+func (d *DB) Revision(projectID string, number int) (*Revision, error) {
+	var r *Revision
+	err := d.db.View(func(tx *bolt.Tx) error {
+		if _, err := readProject(tx, projectID); err != nil {
+			return err
+		}
+		revs := tx.Bucket(revisionsBucket).Bucket([]byte(projectID))
+		if revs == nil || number < 1 {
+			return ErrNotFound
+		}
+		data := revs.Get(seqKey(uint64(number)))
+		if data == nil {
+			return ErrNotFound
+		}
+		r = new(Revision)
+		return json.Unmarshal(data, r)
+	})
+	return r, err
+}
+
+// AddResult stores a result for a project, assigning its ID and creation
+// time.
+func (d *DB) AddResult(r *Result) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		if _, err := readProject(tx, r.ProjectID); err != nil {
+			return err
+		}
+		results, err := tx.Bucket(resultsBucket).CreateBucketIfNotExists([]byte(r.ProjectID))
+		if err != nil {
+			return err
+		}
+		n, err := results.NextSequence()
+		if err != nil {
+			return err
+		}
+		r.ID = strconv.FormatUint(n, 10)
+		r.Created = now().UTC()
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		return results.Put(seqKey(n), data)
+	})
+}
+
+// Results lists a project's results, newest first, without their data.
+func (d *DB) Results(projectID string) ([]Result, error) {
+	list := []Result{}
+	err := d.db.View(func(tx *bolt.Tx) error {
+		if _, err := readProject(tx, projectID); err != nil {
+			return err
+		}
+		results := tx.Bucket(resultsBucket).Bucket([]byte(projectID))
+		if results == nil {
+			return nil
+		}
+		c := results.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var r Result
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			r.Data = nil
+			list = append(list, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Result returns one of a project's results.
+func (d *DB) Result(projectID, id string) (*Result, error) {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	var r *Result
+	err = d.db.View(func(tx *bolt.Tx) error {
+		if _, err := readProject(tx, projectID); err != nil {
+			return err
+		}
+		results := tx.Bucket(resultsBucket).Bucket([]byte(projectID))
+		if results == nil {
+			return ErrNotFound
+		}
+		data := results.Get(seqKey(n))
+		if data == nil {
+			return ErrNotFound
+		}
+		r = new(Result)
+		return json.Unmarshal(data, r)
+	})
+	return r, err
+}
+
+// Session returns a session.
+func (d *DB) Session(key string) (*Session, error) {
+	var sess *Session
+	err := d.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sessionsBucket).Get([]byte(key))
+		if data == nil {
+			return ErrNotFound
+		}
+		sess = new(Session)
+		return json.Unmarshal(data, sess)
+	})
+	return sess, err
+}
+
+// SaveSession stores a session.
+func (d *DB) SaveSession(key string, sess *Session) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	return d.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(key), data)
+	})
+}
+
+// DeleteSession removes a session; removing a missing one is not an error.
+func (d *DB) DeleteSession(key string) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(key))
+	})
+}
+
+// ExpireSessions deletes the sessions last seen before cutoff.
+func (d *DB) ExpireSessions(cutoff time.Time) (int, error) {
+	var expired int
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		var keys [][]byte
+		b := tx.Bucket(sessionsBucket)
+		err := b.ForEach(func(k, v []byte) error {
+			var sess struct {
+				LastSeen time.Time `json:"lastSeen"`
+			}
+			if json.Unmarshal(v, &sess) != nil || sess.LastSeen.Before(cutoff) {
+				keys = append(keys, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		expired = len(keys)
+		return nil
+	})
+	return expired, err
+}
+
+// Modules lists the module library, ordered by name.
+func (d *DB) Modules() ([]Module, error) {
+	modules := []Module{}
+	err := d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(modulesBucket).ForEach(func(k, v []byte) error {
+			var m Module
+			if err := json.Unmarshal(v, &m); err != nil {
+				return fmt.Errorf("module %s: %w", k, err)
+			}
+			modules = append(modules, m)
+			return nil
+		})
+	})
+	return modules, err
+}
+
+// Module returns the library module called name.
+func (d *DB) Module(name string) (*Module, error) {
+	var m *Module
+	err := d.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(modulesBucket).Get([]byte(name))
+		if data == nil {
+			return ErrNotFound
+		}
+		m = new(Module)
+		return json.Unmarshal(data, m)
+	})
+	return m, err
+}
+
+// SaveModule stores a module under its name after checking it.
+func (d *DB) SaveModule(m *Module) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	return d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(modulesBucket)
+		m.Modified = now().UTC()
+		m.Created = m.Modified
+		if data := b.Get([]byte(m.Name)); data != nil {
+			var old Module
+			if json.Unmarshal(data, &old) == nil {
+				m.Created = old.Created
+			}
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(m.Name), data)
+	})
+}
+
+// DeleteModule removes a library module.
+func (d *DB) DeleteModule(name string) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(modulesBucket)
+		if b.Get([]byte(name)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(name))
+	})
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"breadboard-simulator/breadboard"
+	"breadboard-simulator/circuit"
+	"breadboard-simulator/project"
+)
+
+func openTest(t *testing.T) (*DB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, path
+}
+
+func state(ids ...string) breadboard.State {
+	var s breadboard.State
+	for _, id := range ids {
+		s.Components = append(s.Components, breadboard.Component{ID: id, Type: "resistor"})
+	}
+	return s
+}
+
+func TestProjectsSurviveReopening(t *testing.T) {
+	db, path := openTest(t)
+	in, err := db.Create("Torch", "", state("r1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Save(in.ID, state("r1", "r2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveSession("alice", &Session{State: state("led")}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	p, err := db.Get(in.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Torch" || len(p.File.State.Components) != 2 {
+		t.Errorf("project = %+v", p)
+	}
+	s, err := db.Session("alice")
+	if err != nil || len(s.State.Components) != 1 {
+		t.Errorf("session = %+v, %v", s, err)
+	}
+	if _, err := db.Session("bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown session: err = %v", err)
+	}
+}
+
+func TestImportFileStore(t *testing.T) {
+	src, err := project.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := src.Create("Torch", "from before the database", state("r1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, _ := openTest(t)
+	for _, want := range []int{1, 0} {
+		n, err := db.ImportFileStore(src)
+		if err != nil || n != want {
+			t.Fatalf("imported %d, %v; want %d", n, err, want)
+		}
+	}
+	p, err := db.Get(old.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Torch" || p.Description != "from before the database" || len(p.File.State.Components) != 1 {
+		t.Errorf("imported %+v", p)
+	}
+	if revs, err := db.Revisions(old.ID); err != nil || len(revs) != 1 {
+		t.Errorf("revisions %v, %v", revs, err)
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	db, _ := openTest(t)
+	in, _ := db.Create("p", "", state("a"))
+	db.Save(in.ID, state("a", "b"))
+	db.Rename(in.ID, "q")
+	db.Save(in.ID, state("a", "b", "c"))
+
+	revs, err := db.Revisions(in.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 3 {
+		t.Fatalf("revisions = %+v, want 3 (renaming records none)", revs)
+	}
+	for k, r := range revs {
+		if r.Number != k+1 || r.Components != k+1 {
+			t.Errorf("revision %d = %+v", k, r)
+		}
+	}
+	r, err := db.Revision(in.ID, 2)
+	if err != nil || len(r.State.Components) != 2 {
+		t.Errorf("revision 2 = %+v, %v", r, err)
+	}
+	if _, err := db.Revision(in.ID, 4); !errors.Is(err, ErrNotFound) {
+		t.Errorf("revision 4: err = %v", err)
+	}
+
+	dup, _ := db.Duplicate(in.ID, "")
+	if revs, _ := db.Revisions(dup.ID); len(revs) != 1 {
+		t.Errorf("duplicate has %d revisions, want 1", len(revs))
+	}
+}
+
+func TestResults(t *testing.T) {
+	db, _ := openTest(t)
+	in, _ := db.Create("p", "", state("a"))
+	for _, kind := range []string{"op", "dc"} {
+		r := &Result{ProjectID: in.ID, Revision: 1, Kind: kind, Data: json.RawMessage(`{"v":1}`)}
+		if err := db.AddResult(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.AddResult(&Result{ProjectID: "0123456789abcdef"}); !errors.Is(err, project.ErrNotFound) {
+		t.Errorf("result for a missing project: err = %v", err)
+	}
+
+	list, err := db.Results(in.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Kind != "dc" || list[0].Data != nil {
+		t.Fatalf("results = %+v", list)
+	}
+	r, err := db.Result(in.ID, list[1].ID)
+	if err != nil || r.Kind != "op" || string(r.Data) != `{"v":1}` {
+		t.Errorf("result = %+v, %v", r, err)
+	}
+
+	if err := db.Delete(in.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Results(in.ID); !errors.Is(err, project.ErrNotFound) {
+		t.Errorf("results of a deleted project: err = %v", err)
+	}
+	again, _ := db.Create("p", "", state())
+	if list, _ := db.Results(again.ID); len(list) != 0 {
+		t.Errorf("new project has results %+v", list)
+	}
+}
+
+func TestExpireSessions(t *testing.T) {
+	db, _ := openTest(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for k, key := range []string{"old", "recent", "new"} {
+		seen := base.Add(time.Duration(k) * time.Hour)
+		if err := db.SaveSession(key, &Session{Created: base, LastSeen: seen}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := db.ExpireSessions(base.Add(90 * time.Minute))
+	if err != nil || n != 2 {
+		t.Fatalf("expired %d, %v; want 2", n, err)
+	}
+	if _, err := db.Session("recent"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("recent: err = %v", err)
+	}
+	if _, err := db.Session("new"); err != nil {
+		t.Errorf("new: err = %v", err)
+	}
+	if err := db.DeleteSession("new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteSession("new"); err != nil {
+		t.Errorf("deleting twice: %v", err)
+	}
+}
+
+func TestModules(t *testing.T) {
+	db, path := openTest(t)
+	created := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return created }
+	defer func() { now = time.Now }()
+
+	driver := circuit.Subcircuit{
+		Name:  "led-driver",
+		Ports: []string{"in"},
+		Components: []circuit.Component{
+			{ID: "R1", Type: circuit.Resistor, Value: 330, Nodes: []string{"in", "a"}},
+			{ID: "D1", Type: circuit.LED, Nodes: []string{"a", "0"}},
+		},
+	}
+	if err := db.SaveModule(&Module{Subcircuit: driver}); err != nil {
+		t.Fatal(err)
+	}
+	now = func() time.Time { return created.Add(time.Hour) }
+	driver.Description = "330 Ω and an LED"
+	if err := db.SaveModule(&Module{Subcircuit: driver}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveModule(&Module{Subcircuit: circuit.Subcircuit{Ports: []string{"a"}}}); err == nil {
+		t.Error("saved a module without a name")
+	}
+
+	db.Close()
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	m, err := db.Module("led-driver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Created.Equal(created) || !m.Modified.Equal(created.Add(time.Hour)) || m.Description == "" || len(m.Components) != 2 {
+		t.Errorf("module = %+v", m)
+	}
+	if list, err := db.Modules(); err != nil || len(list) != 1 {
+		t.Errorf("Modules() = %v, %v", list, err)
+	}
+	if err := db.DeleteModule("led-driver"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Module("led-driver"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted module: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Package storage persists the server's data: projects with the history
+// of their revisions, the simulation results computed for them, the
+// editing sessions of the people using the server, and the library of
+// subcircuit modules shared between projects.
+//
+// Store is the interface the server is written against; DB implements it
+// on an embedded, file-based database, so everything survives a restart.
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"breadboard-simulator/breadboard"
+	"breadboard-simulator/circuit"
+	"breadboard-simulator/history"
+	"breadboard-simulator/project"
+)
+
+// ErrNotFound is returned for a revision, result or session the store
+// does not have. Missing projects are reported with project.ErrNotFound.
+var ErrNotFound = errors.New("not found")
+
+// Revision is a project's breadboard as it was saved at one time.
+// Revisions are numbered from 1 in the order they were saved; Parent is
+// the revision saved before, or 0 for the first.
+type Revision struct {
+	Number  int              `json:"number"`
+	Parent  int              `json:"parent"`
+	Created time.Time        `json:"created"`
+	State   breadboard.State `json:"state"`
+}
+
+// RevisionInfo summarizes a revision for listings.
+type RevisionInfo struct {
+	Number     int       `json:"number"`
+	Parent     int       `json:"parent"`
+	Created    time.Time `json:"created"`
+	Components int       `json:"components"`
+}
+
+// Result is the output of a simulation run on a project. Revision is the
+// project revision that was simulated, Kind the analysis ("op", "dc",
+// "drain", ...) and Data its output, as the API returned it.
+type Result struct {
+	ID        string          `json:"id"`
+	ProjectID string          `json:"projectId"`
+	Revision  int             `json:"revision"`
+	Kind      string          `json:"kind"`
+	Created   time.Time       `json:"created"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// Session is one client's working state: the breadboard it is editing
+// outside of any project, and the history of its saves.
+type Session struct {
+	State    breadboard.State `json:"state"`
+	History  history.History  `json:"history"`
+	Created  time.Time        `json:"created"`
+	LastSeen time.Time        `json:"lastSeen"`
+}
+
+// Module is a subcircuit saved to the library for reuse across projects.
+// A circuit instantiating it carries its own copy of the definition, so
+// changing the library does not change projects already built with it.
+type Module struct {
+	circuit.Subcircuit
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+}
+
+// Store is the server's persistent storage. Saving or creating a project
+// records a new revision.
+type Store interface {
+	project.Store
+
+	// Revisions lists a project's revisions, oldest first.
+	Revisions(projectID string) ([]RevisionInfo, error)
+	Revision(projectID string, number int) (*Revision, error)
+
+	// AddResult stores a result, assigning its ID and creation time.
+	AddResult(r *Result) error
+	// Results lists a project's results, newest first, without their data.
+	Results(projectID string) ([]Result, error)
+	Result(projectID, id string) (*Result, error)
+
+	Session(key string) (*Session, error)
+	SaveSession(key string, s *Session) error
+	DeleteSession(key string) error
+	// ExpireSessions deletes the sessions last seen before cutoff and
+	// reports how many there were.
+	ExpireSessions(cutoff time.Time) (int, error)
+
+	// Modules lists the module library by name.
+	Modules() ([]Module, error)
+	Module(name string) (*Module, error)
+	// SaveModule adds a module to the library, or replaces the one of its
+	// name, setting its creation and modification times.
+	SaveModule(m *Module) error
+	DeleteModule(name string) error
+
+	Close() error
+}
@@ -0,0 +1,49 @@
+package waveform
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"math/cmplx"
+	"strconv"
+)
+
+// WriteCSV writes one row per point with a header naming the variables.
+// AC results are written as magnitude and phase in degrees, the scale
+// (frequency) as a plain number.
+func WriteCSV(w io.Writer, d *Dataset) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{d.Variables[0].Name}
+	for _, v := range d.Variables[1:] {
+		if d.Complex() {
+			header = append(header, v.Name+" mag", v.Name+" phase")
+		} else {
+			header = append(header, v.Name)
+		}
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for k, row := range d.Real {
+		record := []string{formatFloat(row[0])}
+		for j := 1; j < len(row); j++ {
+			if d.Complex() {
+				z := complex(row[j], d.Imag[k][j])
+				record = append(record, formatFloat(cmplx.Abs(z)), formatFloat(cmplx.Phase(z)*180/math.Pi))
+			} else {
+				record = append(record, formatFloat(row[j]))
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,195 @@
+// Package waveform writes simulation results as waveforms for other tools:
+// CSV for spreadsheets, SPICE raw files (ASCII or binary, as ngspice
+// writes them) for waveform viewers, and VCD for logic analyzers.
+package waveform
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"breadboard-simulator/circuit"
+)
+
+// Analysis kinds, which decide the scale variable.
+const (
+	Transient = "tran" // scale is time
+	AC        = "ac"   // scale is frequency, values are complex
+	DCSweep   = "dc"   // scale is the swept source's value
+)
+
+// Variable is one column of a dataset. Type is "time", "frequency",
+// "voltage", "current" or "notype", as in a raw file's variable list.
+type Variable struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Dataset holds the results of one analysis. The first variable is the
+// scale. Real holds one row per point with a value per variable; AC
+// results add the imaginary parts in Imag, of the same shape.
+type Dataset struct {
+	Title     string      `json:"title"`
+	Analysis  string      `json:"analysis"`
+	Variables []Variable  `json:"variables"`
+	Real      [][]float64 `json:"real"`
+	Imag      [][]float64 `json:"imag,omitempty"`
+	// Stress holds the parts a time-domain run loaded near or beyond
+	// their ratings; the file formats leave it out.
+	Stress []circuit.StressFinding `json:"stress,omitempty"`
+}
+
+// Complex reports whether the dataset has imaginary parts.
+func (d *Dataset) Complex() bool {
+	return d.Imag != nil
+}
+
+// Validate checks that every row matches the variable list.
+func (d *Dataset) Validate() error {
+	switch d.Analysis {
+	case Transient, AC, DCSweep:
+	default:
+		return fmt.Errorf("unknown analysis %q", d.Analysis)
+	}
+	if len(d.Variables) == 0 {
+		return fmt.Errorf("dataset has no variables")
+	}
+	for k, row := range d.Real {
+		if len(row) != len(d.Variables) {
+			return fmt.Errorf("point %d has %d values for %d variables", k, len(row), len(d.Variables))
+		}
+	}
+	if d.Imag != nil {
+		if len(d.Imag) != len(d.Real) {
+			return fmt.Errorf("%d imaginary rows for %d points", len(d.Imag), len(d.Real))
+		}
+		for k, row := range d.Imag {
+			if len(row) != len(d.Variables) {
+				return fmt.Errorf("point %d has %d imaginary values for %d variables", k, len(row), len(d.Variables))
+			}
+		}
+	}
+	return nil
+}
+
+// plotName is the raw file's name for an analysis.
+func (d *Dataset) plotName() string {
+	switch d.Analysis {
+	case Transient:
+		return "Transient Analysis"
+	case AC:
+		return "AC Analysis"
+	}
+	return "DC transfer characteristic"
+}
+
+// solutionVariables lists the node voltages and branch currents of a
+// solution in a stable order, named as ngspice does: v(node), i(part).
+func solutionVariables(sol *circuit.Solution) ([]Variable, []func(*circuit.Solution) float64) {
+	var nodes, parts []string
+	for node := range sol.NodeVoltages {
+		if node != "ground" {
+			nodes = append(nodes, node)
+		}
+	}
+	for id := range sol.Currents {
+		parts = append(parts, id)
+	}
+	sort.Strings(nodes)
+	sort.Strings(parts)
+
+	var vars []Variable
+	var get []func(*circuit.Solution) float64
+	for _, node := range nodes {
+		node := node
+		vars = append(vars, Variable{"v(" + node + ")", "voltage"})
+		get = append(get, func(s *circuit.Solution) float64 { return s.NodeVoltages[node] })
+	}
+	for _, id := range parts {
+		id := id
+		vars = append(vars, Variable{"i(" + id + ")", "current"})
+		get = append(get, func(s *circuit.Solution) float64 { return s.Currents[id] })
+	}
+	return vars, get
+}
+
+// FromSweep builds a DC sweep dataset from the solutions SweepDC returned
+// for the given values of the swept source, which names the scale.
+func FromSweep(title string, scale Variable, values []float64, solutions []*circuit.Solution) *Dataset {
+	d := &Dataset{Title: title, Analysis: DCSweep, Variables: []Variable{scale}}
+	if len(solutions) == 0 {
+		return d
+	}
+	vars, get := solutionVariables(solutions[0])
+	d.Variables = append(d.Variables, vars...)
+	for k, sol := range solutions {
+		row := []float64{values[k]}
+		for _, g := range get {
+			row = append(row, g(sol))
+		}
+		d.Real = append(d.Real, row)
+	}
+	return d
+}
+
+// FromDrain builds a transient dataset from a battery drain simulation:
+// each battery's terminal voltage, current and state of charge over time.
+func FromDrain(title string, res *circuit.DrainResult) *Dataset {
+	d := &Dataset{Title: title, Analysis: Transient, Variables: []Variable{{"time", "time"}}, Stress: res.Stress}
+	if len(res.Samples) == 0 {
+		return d
+	}
+	var ids []string
+	for id := range res.Samples[0].Batteries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		d.Variables = append(d.Variables,
+			Variable{"v(" + id + ")", "voltage"},
+			Variable{"i(" + id + ")", "current"},
+			Variable{"soc(" + id + ")", "notype"})
+	}
+	for _, sample := range res.Samples {
+		row := []float64{sample.Time}
+		for _, id := range ids {
+			b := sample.Batteries[id]
+			row = append(row, b.TerminalVoltage, b.Current, b.StateOfCharge)
+		}
+		d.Real = append(d.Real, row)
+	}
+	return d
+}
+
+// Formats lists the accepted format names.
+var Formats = []string{"csv", "raw", "raw-ascii", "vcd"}
+
+// Write writes d in the named format; vcd applies to the "vcd" format.
+func Write(w io.Writer, d *Dataset, format string, vcd VCDOptions) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	switch format {
+	case "csv":
+		return WriteCSV(w, d)
+	case "raw":
+		return WriteRaw(w, d, true)
+	case "raw-ascii":
+		return WriteRaw(w, d, false)
+	case "vcd":
+		return WriteVCD(w, d, vcd)
+	}
+	return fmt.Errorf("unknown waveform format %q, want one of %s", format, strings.Join(Formats, ", "))
+}
+
+// FileName suggests a file name for the named format.
+func FileName(format string) string {
+	switch format {
+	case "raw", "raw-ascii":
+		return "waveform.raw"
+	case "vcd":
+		return "waveform.vcd"
+	}
+	return "waveform.csv"
+}
@@ -0,0 +1,75 @@
+package waveform
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// now is the date written into raw file headers; tests replace it.
+var now = time.Now
+
+// WriteRaw writes d as a SPICE raw file in the layout ngspice uses, which
+// waveform viewers such as gwave, GAW and LTspice open. Binary files store
+// each point as little-endian float64s, complex values as real and
+// imaginary pairs, scale included.
+func WriteRaw(w io.Writer, d *Dataset, binaryValues bool) error {
+	bw := bufio.NewWriter(w)
+
+	flags := "real"
+	if d.Complex() {
+		flags = "complex"
+	}
+	title := d.Title
+	if title == "" {
+		title = "breadboard circuit"
+	}
+	fmt.Fprintf(bw, "Title: %s\n", title)
+	fmt.Fprintf(bw, "Date: %s\n", now().Format("Mon Jan _2 15:04:05  2006"))
+	fmt.Fprintf(bw, "Plotname: %s\n", d.plotName())
+	fmt.Fprintf(bw, "Flags: %s\n", flags)
+	fmt.Fprintf(bw, "No. Variables: %d\n", len(d.Variables))
+	fmt.Fprintf(bw, "No. Points: %d\n", len(d.Real))
+	fmt.Fprintf(bw, "Variables:\n")
+	for k, v := range d.Variables {
+		fmt.Fprintf(bw, "\t%d\t%s\t%s\n", k, v.Name, v.Type)
+	}
+
+	if binaryValues {
+		fmt.Fprintf(bw, "Binary:\n")
+		buf := make([]byte, 8)
+		put := func(v float64) {
+			binary.LittleEndian.PutUint64(buf, math.Float64bits(v))
+			bw.Write(buf)
+		}
+		for k, row := range d.Real {
+			for j, v := range row {
+				put(v)
+				if d.Complex() {
+					put(d.Imag[k][j])
+				}
+			}
+		}
+		return bw.Flush()
+	}
+
+	fmt.Fprintf(bw, "Values:\n")
+	for k, row := range d.Real {
+		for j, v := range row {
+			prefix := "\t"
+			if j == 0 {
+				prefix = fmt.Sprintf(" %d\t", k)
+			}
+			if d.Complex() {
+				fmt.Fprintf(bw, "%s%.15e,%.15e\n", prefix, v, d.Imag[k][j])
+			} else {
+				fmt.Fprintf(bw, "%s%.15e\n", prefix, v)
+			}
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
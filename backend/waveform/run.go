@@ -0,0 +1,68 @@
+package waveform
+
+import (
+	"context"
+	"fmt"
+
+	"breadboard-simulator/circuit"
+)
+
+// Analysis selects the simulation that produces a dataset: "dc" sweeps
+// Source, a source or a circuit parameter, from Start to Stop in steps of
+// Step, "tran" steps the circuit through time from zero to Stop in steps
+// of Step, and "drain" runs a battery discharge with the Drain options and
+// yields a transient dataset.
+type Analysis struct {
+	Type   string               `json:"type"`
+	Source string               `json:"source,omitempty"`
+	Start  float64              `json:"start,omitempty"`
+	Stop   float64              `json:"stop,omitempty"`
+	Step   float64              `json:"step,omitempty"`
+	Drain  circuit.DrainOptions `json:"drain,omitempty"`
+}
+
+// Run simulates c and collects the results as a dataset.
+func Run(title string, c *circuit.Circuit, a Analysis) (*Dataset, error) {
+	return RunContext(context.Background(), title, c, a)
+}
+
+// RunContext is Run giving up with ctx's error once ctx is done. The
+// simulation reports its progress to ctx; see circuit.WithProgress.
+func RunContext(ctx context.Context, title string, c *circuit.Circuit, a Analysis) (*Dataset, error) {
+	switch a.Type {
+	case DCSweep:
+		values, err := circuit.SweepValues(a.Start, a.Stop, a.Step)
+		if err != nil {
+			return nil, err
+		}
+		solutions, err := circuit.SweepDCContext(ctx, c, a.Source, values)
+		if err != nil {
+			return nil, err
+		}
+		scale := Variable{a.Source, "voltage"}
+		for _, comp := range c.Components {
+			if comp.ID == a.Source && comp.Type == circuit.CurrentSource {
+				scale.Type = "current"
+			}
+		}
+		return FromSweep(title, scale, values, solutions), nil
+	case "drain":
+		res, err := circuit.SimulateDrainContext(ctx, c, a.Drain)
+		if err != nil {
+			return nil, err
+		}
+		return FromDrain(title, res), nil
+	case Transient:
+		times, solutions, stress, err := circuit.SimulateTransient(ctx, c, a.Step, a.Stop)
+		if err != nil {
+			return nil, err
+		}
+		d := FromSweep(title, Variable{"time", "time"}, times, solutions)
+		d.Analysis = Transient
+		d.Stress = stress
+		return d, nil
+	case AC:
+		return nil, fmt.Errorf("the simulator has no %s analysis; send its results as a dataset instead", a.Type)
+	}
+	return nil, fmt.Errorf("unknown analysis %q", a.Type)
+}
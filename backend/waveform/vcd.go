@@ -0,0 +1,138 @@
+package waveform
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// VCDOptions controls how analog voltages become logic levels.
+type VCDOptions struct {
+	// Low and High are the input thresholds in volts: at or below Low a
+	// net reads 0, at or above High it reads 1 and in between x. They
+	// default to TTL's 0.8 V and 2.0 V.
+	Low  float64 `json:"low,omitempty"`
+	High float64 `json:"high,omitempty"`
+	// Timescale is the length of a VCD time unit in seconds, a power of
+	// ten from 1 fs to 1 s. It defaults to 1 ns.
+	Timescale float64 `json:"timescale,omitempty"`
+	// Signals names the voltage variables to dump, such as "v(out)";
+	// empty dumps every voltage.
+	Signals []string `json:"signals,omitempty"`
+}
+
+var timescaleUnits = []struct {
+	unit  string
+	scale float64
+}{{"s", 1}, {"ms", 1e-3}, {"us", 1e-6}, {"ns", 1e-9}, {"ps", 1e-12}, {"fs", 1e-15}}
+
+// timescale renders a power of ten as a VCD timescale such as "10 ns".
+func timescale(seconds float64) (string, error) {
+	for _, u := range timescaleUnits {
+		for _, mult := range []float64{100, 10, 1} {
+			if math.Abs(seconds-mult*u.scale) <= 1e-6*seconds {
+				return fmt.Sprintf("%g %s", mult, u.unit), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("timescale %gs is not 1, 10 or 100 of s, ms, us, ns, ps or fs", seconds)
+}
+
+// vcdID returns the short identifier code for the k-th signal, counting
+// in base 94 over the printable ASCII characters.
+func vcdID(k int) string {
+	var b []byte
+	for {
+		b = append(b, byte('!'+k%94))
+		k /= 94
+		if k == 0 {
+			return string(b)
+		}
+		k--
+	}
+}
+
+// WriteVCD writes the voltages of a transient dataset as digital signals
+// in a Value Change Dump, for logic analyzer views such as GTKWave.
+func WriteVCD(w io.Writer, d *Dataset, opts VCDOptions) error {
+	if d.Analysis != Transient {
+		return fmt.Errorf("VCD needs a transient analysis, not %q", d.Analysis)
+	}
+	if opts.Low == 0 && opts.High == 0 {
+		opts.Low, opts.High = 0.8, 2.0
+	}
+	if opts.Low > opts.High {
+		return fmt.Errorf("low threshold %gV is above high threshold %gV", opts.Low, opts.High)
+	}
+	if opts.Timescale == 0 {
+		opts.Timescale = 1e-9
+	}
+	scale, err := timescale(opts.Timescale)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range opts.Signals {
+		wanted[name] = true
+	}
+	var columns []int
+	for k, v := range d.Variables {
+		if k > 0 && v.Type == "voltage" && (len(wanted) == 0 || wanted[v.Name]) {
+			columns = append(columns, k)
+			delete(wanted, v.Name)
+		}
+	}
+	for name := range wanted {
+		return fmt.Errorf("no voltage %q in the dataset", name)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "$date %s $end\n", now().Format("Mon Jan _2 15:04:05 2006"))
+	fmt.Fprintf(bw, "$version breadboard-simulator $end\n")
+	fmt.Fprintf(bw, "$timescale %s $end\n", scale)
+	fmt.Fprintf(bw, "$scope module circuit $end\n")
+	for k, col := range columns {
+		name := strings.TrimSuffix(strings.TrimPrefix(d.Variables[col].Name, "v("), ")")
+		fmt.Fprintf(bw, "$var wire 1 %s %s $end\n", vcdID(k), strings.Join(strings.Fields(name), "_"))
+	}
+	fmt.Fprintf(bw, "$upscope $end\n$enddefinitions $end\n")
+
+	level := func(v float64) byte {
+		switch {
+		case v >= opts.High:
+			return '1'
+		case v <= opts.Low:
+			return '0'
+		}
+		return 'x'
+	}
+	last := make([]byte, len(columns))
+	lastTime := int64(-1)
+	for p, row := range d.Real {
+		t := int64(math.Round(row[0] / opts.Timescale))
+		var changes []string
+		for k, col := range columns {
+			value := level(row[col])
+			if p == 0 || value != last[k] {
+				changes = append(changes, string(value)+vcdID(k))
+				last[k] = value
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if t != lastTime {
+			fmt.Fprintf(bw, "#%d\n", t)
+			lastTime = t
+		}
+		if p == 0 {
+			fmt.Fprintf(bw, "$dumpvars\n%s\n$end\n", strings.Join(changes, "\n"))
+		} else {
+			fmt.Fprintln(bw, strings.Join(changes, "\n"))
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,186 @@
+package waveform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"breadboard-simulator/circuit"
+)
+
+func init() {
+	now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+}
+
+func sweep(t *testing.T) *Dataset {
+	t.Helper()
+	c := &circuit.Circuit{Components: []circuit.Component{
+		{ID: "V1", Type: circuit.Battery, Value: 0, Nodes: []string{"in", "0"}},
+		{ID: "R1", Type: circuit.Resistor, Value: 1000, Nodes: []string{"in", "out"}},
+		{ID: "R2", Type: circuit.Resistor, Value: 1000, Nodes: []string{"out", "0"}},
+	}}
+	values, err := circuit.SweepValues(0, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sols, err := circuit.SweepDC(c, "V1", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return FromSweep("divider", Variable{"v1", "voltage"}, values, sols)
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, sweep(t), "csv", VCDOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := "v1,v(in),v(out),i(R1),i(R2),i(V1)\n" +
+		"0,0,0,0,0,0\n" +
+		"1,1,0.5,0.0005,0.0005,-0.0005\n" +
+		"2,2,1,0.001,0.001,-0.001\n"
+	if got := roundCSV(buf.String()); got != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// roundCSV trims floating-point noise from the solver's results.
+func roundCSV(s string) string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Split(line, ",")
+		for k, f := range fields {
+			var v float64
+			if _, err := fmt.Sscan(f, &v); err == nil {
+				fields[k] = formatFloat(math.Round(v*1e9) / 1e9)
+			}
+		}
+		out = append(out, strings.Join(fields, ","))
+	}
+	return strings.Join(out, "\n")
+}
+
+func TestWriteCSVComplex(t *testing.T) {
+	d := &Dataset{
+		Analysis:  AC,
+		Variables: []Variable{{"frequency", "frequency"}, {"v(out)", "voltage"}},
+		Real:      [][]float64{{1000, 0}},
+		Imag:      [][]float64{{0, -2}},
+	}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, d); err != nil {
+		t.Fatal(err)
+	}
+	if want := "frequency,v(out) mag,v(out) phase\n1000,2,-90\n"; buf.String() != want {
+		t.Errorf("CSV = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteRaw(t *testing.T) {
+	d := sweep(t)
+	var ascii bytes.Buffer
+	if err := Write(&ascii, d, "raw-ascii", VCDOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Title: divider\nDate: Fri Mar  1 12:00:00  2024\nPlotname: DC transfer characteristic\nFlags: real\n",
+		"No. Variables: 6\nNo. Points: 3\nVariables:\n\t0\tv1\tvoltage\n\t1\tv(in)\tvoltage\n",
+		"Values:\n 0\t0.000000000000000e+00\n",
+		" 2\t2.000000000000000e+00\n\t2.000000000000000e+00\n",
+	} {
+		if !strings.Contains(ascii.String(), want) {
+			t.Errorf("ASCII raw file lacks %q:\n%s", want, ascii.String())
+		}
+	}
+
+	var bin bytes.Buffer
+	if err := Write(&bin, d, "raw", VCDOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	header, values, ok := strings.Cut(bin.String(), "Binary:\n")
+	if !ok || !strings.Contains(header, "Flags: real") {
+		t.Fatalf("binary raw header = %q", header)
+	}
+	if len(values) != 3*6*8 {
+		t.Fatalf("binary raw has %d bytes of values, want %d", len(values), 3*6*8)
+	}
+	// point 1, variable 2 is v(out) at V1 = 1V
+	v := math.Float64frombits(binary.LittleEndian.Uint64([]byte(values[(6+2)*8:])))
+	if math.Abs(v-0.5) > 1e-9 {
+		t.Errorf("v(out) at point 1 = %v, want 0.5", v)
+	}
+}
+
+func TestWriteVCD(t *testing.T) {
+	d := &Dataset{
+		Analysis:  Transient,
+		Variables: []Variable{{"time", "time"}, {"v(clk)", "voltage"}, {"v(q)", "voltage"}, {"i(R1)", "current"}},
+		Real: [][]float64{
+			{0, 0, 5, 0},
+			{1e-6, 5, 5, 0},
+			{2e-6, 1.5, 0.2, 0},
+			{3e-6, 1.4, 0.1, 0},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteVCD(&buf, d, VCDOptions{Timescale: 1e-6}); err != nil {
+		t.Fatal(err)
+	}
+	want := "$timescale 1 us $end\n$scope module circuit $end\n" +
+		"$var wire 1 ! clk $end\n$var wire 1 \" q $end\n$upscope $end\n$enddefinitions $end\n" +
+		"#0\n$dumpvars\n0!\n1\"\n$end\n#1\n1!\n#2\nx!\n0\"\n"
+	if !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("VCD =\n%s\nwant suffix\n%s", buf.String(), want)
+	}
+
+	if err := WriteVCD(&buf, d, VCDOptions{Signals: []string{"v(nope)"}}); err == nil {
+		t.Error("WriteVCD accepted an unknown signal")
+	}
+	if err := WriteVCD(&buf, &Dataset{Analysis: DCSweep, Variables: d.Variables}, VCDOptions{}); err == nil {
+		t.Error("WriteVCD accepted a DC sweep")
+	}
+}
+
+func TestVCDID(t *testing.T) {
+	for k, want := range map[int]string{0: "!", 93: "~", 94: "!!", 95: "\"!"} {
+		if got := vcdID(k); got != want {
+			t.Errorf("vcdID(%d) = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestRunTransient(t *testing.T) {
+	c := &circuit.Circuit{Components: []circuit.Component{
+		{ID: "V1", Type: circuit.Battery, Value: 5, Nodes: []string{"in", "0"}},
+		{ID: "R1", Type: circuit.Resistor, Value: 1000, Nodes: []string{"in", "out"}},
+		{ID: "C1", Type: circuit.Capacitor, Value: 1e-6, Nodes: []string{"out", "0"},
+			Properties: map[string]interface{}{"initialVoltage": 0.0, "voltageRating": 3.0}},
+	}}
+	d, err := Run("rc", c, Analysis{Type: Transient, Step: 1e-4, Stop: 1e-3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if d.Analysis != Transient || d.Variables[0] != (Variable{"time", "time"}) || len(d.Real) != 11 {
+		t.Fatalf("dataset = %+v", d)
+	}
+	out := -1
+	for k, v := range d.Variables {
+		if v.Name == "v(out)" {
+			out = k
+		}
+	}
+	if out < 0 || d.Real[0][out] != 0 || d.Real[10][out] < 3 || d.Real[10][out] > 3.5 {
+		t.Errorf("v(out) = %v at 0 and %v at 1 ms", d.Real[0][out], d.Real[10][out])
+	}
+	// C1 passes its 3 V rating at the last point
+	if len(d.Stress) != 1 || d.Stress[0].FirstExceeded == nil || math.Abs(*d.Stress[0].FirstExceeded-1e-3) > 1e-9 {
+		t.Errorf("stress = %+v", d.Stress)
+	}
+}
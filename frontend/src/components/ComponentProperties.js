@@ -1,6 +1,7 @@
 import React, { useState, useEffect } from 'react';
 import '../css/ComponentProperties.css';
 
+// COMPONENT_PROPERTIES is used until the catalog is loaded from the backend.
 const COMPONENT_PROPERTIES = {
   resistor: [
     { name: 'resistance', label: 'Resistance (Ω)', type: 'number', unit: 'ohm' },
@@ -44,8 +45,56 @@ const COMPONENT_PROPERTIES = {
   ]
 };
 
+const CATALOG_URL = 'http://localhost:8080/api/components';
+
+// catalogFields turns the backend's component catalog into property panel
+// fields.
+const catalogFields = (catalog) => {
+  const fields = {};
+  (catalog.components || []).forEach((part) => {
+    fields[part.type] = part.params.map((param) => ({
+      name: param.name,
+      label: param.label,
+      type: param.type,
+      unit: param.unit,
+      options: param.options,
+    }));
+  });
+  return fields;
+};
+
+let catalogRequest = null;
+
+// loadCatalog fetches the catalog once for every panel.
+const loadCatalog = () => {
+  if (!catalogRequest) {
+    catalogRequest = fetch(CATALOG_URL)
+      .then((response) => (response.ok ? response.json() : Promise.reject(response.status)))
+      .then(catalogFields)
+      .catch((error) => {
+        console.error('Failed to load the component catalog:', error);
+        catalogRequest = null;
+        return null;
+      });
+  }
+  return catalogRequest;
+};
+
 const ComponentProperties = ({ component, onUpdate, onClose }) => {
   const [localProperties, setLocalProperties] = useState(component.properties);
+  const [fields, setFields] = useState(COMPONENT_PROPERTIES);
+
+  useEffect(() => {
+    let active = true;
+    loadCatalog().then((catalog) => {
+      if (active && catalog) {
+        setFields(catalog);
+      }
+    });
+    return () => {
+      active = false;
+    };
+  }, []);
 
   useEffect(() => {
     setLocalProperties(component.properties);
@@ -99,6 +148,15 @@ const ComponentProperties = ({ component, onUpdate, onClose }) => {
             ))}
           </select>
         );
+      case 'boolean':
+        return (
+          <input
+            type="checkbox"
+            name={prop.name}
+            checked={localProperties[prop.name] === true}
+            onChange={(e) => handleChange(prop.name, e.target.checked, 'boolean')}
+          />
+        );
       case 'textarea':
         return (
           <textarea
@@ -120,7 +178,7 @@ const ComponentProperties = ({ component, onUpdate, onClose }) => {
   };
 
   const renderProperties = () => {
-    const properties = COMPONENT_PROPERTIES[component.type] || [];
+    const properties = fields[component.type] || COMPONENT_PROPERTIES[component.type] || [];
     return properties.map((prop) => (
       <label key={prop.name}>
         {prop.label}: